@@ -1,14 +1,114 @@
 package apperrors
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+)
+
+// Error is a domain error that knows how it is shown to clients. Code is a
+// stable identifier clients can rely on, Status the HTTP status it maps to and
+// Message a human-readable explanation. Details are added to the response.
+type Error struct {
+	Code    string
+	Status  int
+	Message string
+	Details map[string]interface{}
+	// Err is the underlying cause. It is logged but never shown to clients.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors by code, so an error returned by WithDetails, WithMessage
+// or Wrap still matches the sentinel it was derived from.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDetails returns a copy of e with details added.
+func (e *Error) WithDetails(details map[string]interface{}) *Error {
+	c := *e
+	c.Details = make(map[string]interface{}, len(e.Details)+len(details))
+	for k, v := range e.Details {
+		c.Details[k] = v
+	}
+	for k, v := range details {
+		c.Details[k] = v
+	}
+	return &c
+}
+
+// WithMessage returns a copy of e with a more specific message.
+func (e *Error) WithMessage(message string) *Error {
+	c := *e
+	c.Message = message
+	return &c
+}
+
+// Wrap returns a copy of e caused by err.
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+func New(status int, code string, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+func BadRequest(code string, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+func Unauthorized(code string, message string) *Error {
+	return New(http.StatusUnauthorized, code, message)
+}
+
+func Forbidden(code string, message string) *Error {
+	return New(http.StatusForbidden, code, message)
+}
+
+func NotFound(code string, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+func Conflict(code string, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+func TooManyRequests(code string, message string) *Error {
+	return New(http.StatusTooManyRequests, code, message)
+}
 
 var (
-	ErrNotFound       = errors.New("not found")
-	ErrUnauthorized   = errors.New("unauthorized")
-	ErrBadRequest     = errors.New("bad request")
-	ErrForbidden      = errors.New("forbidden")
-	ErrConflict       = errors.New("conflict")
-	ErrInternalServer = errors.New("internal server error")
-	ErrInvalidInput   = errors.New("invalid input")
-	ErrAlreadyExists  = errors.New("already exists")
+	ErrNotFound        = NotFound("not_found", "not found")
+	ErrUnauthorized    = Unauthorized("unauthorized", "unauthorized")
+	ErrBadRequest      = BadRequest("bad_request", "bad request")
+	ErrForbidden       = Forbidden("forbidden", "forbidden")
+	ErrConflict        = Conflict("conflict", "conflict")
+	ErrInternalServer  = New(http.StatusInternalServerError, "internal_error", "internal server error")
+	ErrInvalidInput    = BadRequest("invalid_input", "invalid input")
+	ErrAlreadyExists   = Conflict("already_exists", "already exists")
+	ErrValidation      = BadRequest("validation_failed", "request validation failed")
+	ErrTooManyRequests = TooManyRequests("rate_limited", "rate limit exceeded, try again later")
 )
+
+// From returns err as an *Error. Errors that are not domain errors become
+// ErrInternalServer wrapping err.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return ErrInternalServer.Wrap(err)
+}
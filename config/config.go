@@ -1,35 +1,347 @@
 package config
 
 import (
-	"log"
-	"os"
+	"go-rest-api/logging"
+	"go-rest-api/ratelimit"
+	"go-rest-api/tracing"
+	"go-rest-api/utils"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
-	DatabaseURL string
-	JWTSecret   string
+	AppEnv                  string
+	Port                    int
+	TrustedProxies          []string
+	DatabaseURL             string
+	DatabaseMaxOpenConns    int
+	DatabaseMaxIdleConns    int
+	DatabaseConnMaxLifetime time.Duration
+	MigrateOnStart          bool
+	JWTSecret               string
+	JWTSigningAlgorithm     string
+	JWTKeyRotationInterval  time.Duration
+	TokenTTL                time.Duration
+	BcryptCost              int
+	CORS                    CORSConfig
+	Email                   EmailConfig
+	OIDCProviders           []OIDCProviderConfig
+	RateLimitStore          string
+	RedisURL                string
+	RateLimitPublic         RateLimitConfig
+	RateLimitProtected      RateLimitConfig
+	RateLimitAdmin          RateLimitConfig
+	Geocoder                string
+	GeocoderPlacesFile      string
+	LogLevel                slog.Level
+	LogFormat               string
+	LogRedact               bool
+	TracingExporter         string
+	MetricsAddr             string
+	ShutdownTimeout         time.Duration
+	ShutdownDelay           time.Duration
+
+	settings map[string]setting
+}
+
+// CORSConfig controls which browser origins may call the API.
+type CORSConfig struct {
+	AllowedOrigins   []utils.OriginPattern
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// EmailConfig selects how transactional emails are sent.
+type EmailConfig struct {
+	Sender       string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+}
+
+// RateLimitConfig is the rate limit of one route group.
+type RateLimitConfig struct {
+	Policy   ratelimit.Policy
+	Identity ratelimit.Identity
 }
 
-func LoadConfig() *Config {
-	err := godotenv.Load()
+// OIDCProviderConfig describes an OpenID Connect provider users can sign in with.
+type OIDCProviderConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// LoadConfig reads the configuration. Each setting is named after its environment
+// variable and taken from the first source that sets it:
+//
+//  1. environment variables
+//  2. the .env file in the working directory
+//  3. configFile, a YAML or TOML file where nested keys are joined with
+//     underscores (database.max_open_conns sets DATABASE_MAX_OPEN_CONNS)
+//  4. built-in defaults
+//
+// All invalid settings are reported together in the returned error.
+func LoadConfig(configFile string) (*Config, error) {
+	l, err := newLoader(configFile, ".env")
 	if err != nil {
-		log.Println("Warning: .env file not found, reading from environment variables")
+		return nil, err
+	}
+
+	appEnv := l.oneOf("APP_ENV", "production", "development", "production")
+	cfg := &Config{
+		AppEnv:                  appEnv,
+		Port:                    l.int("PORT", 3000, 1, 65535),
+		TrustedProxies:          l.list("TRUSTED_PROXIES", ""),
+		DatabaseURL:             l.url("DATABASE_URL", ""),
+		DatabaseMaxOpenConns:    l.int("DATABASE_MAX_OPEN_CONNS", 5, 1, 1000),
+		DatabaseMaxIdleConns:    l.int("DATABASE_MAX_IDLE_CONNS", 5, 0, 1000),
+		DatabaseConnMaxLifetime: l.duration("DATABASE_CONN_MAX_LIFETIME", 0, 0),
+		// Off by default: schema changes are applied with the migrate command and
+		// the server only checks the schema version.
+		MigrateOnStart:         l.bool("MIGRATE_ON_START", false),
+		JWTSigningAlgorithm:    l.oneOf("JWT_SIGNING_ALG", "HS256", "HS256", "RS256", "EdDSA"),
+		JWTSecret:              l.secret("JWT_SECRET"),
+		JWTKeyRotationInterval: l.duration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour, time.Hour),
+		TokenTTL:               l.duration("TOKEN_TTL", 2*time.Hour, time.Minute),
+		BcryptCost:             l.int("BCRYPT_COST", 14, 10, bcrypt.MaxCost),
+		CORS:                   loadCORS(l),
+		Email:                  loadEmail(l, appEnv),
+		OIDCProviders:          loadOIDCProviders(l),
+		RateLimitStore:         l.oneOf("RATE_LIMIT_STORE", "memory", "memory", "redis"),
+		RedisURL:               l.url("REDIS_URL", ""),
+		RateLimitPublic:        loadRateLimit(l, "PUBLIC", "60/1m", ratelimit.IdentityIP),
+		RateLimitProtected:     loadRateLimit(l, "PROTECTED", "120/1m", ratelimit.IdentityAPIKey),
+		RateLimitAdmin:         loadRateLimit(l, "ADMIN", "300/1m", ratelimit.IdentityUser),
+		Geocoder:               l.oneOf("GEOCODER", "none", "none", "static"),
+		GeocoderPlacesFile:     l.string("GEOCODER_PLACES_FILE", ""),
+		LogFormat:              l.oneOf("LOG_FORMAT", "json", "json", "text"),
+		// Redaction can be turned off for local development, e.g. to read
+		// verification tokens printed by the log email sender.
+		LogRedact:       l.bool("LOG_REDACT", true),
+		TracingExporter: l.oneOf("TRACING_EXPORTER", tracing.ExporterNone, tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout),
+		// Serve /metrics without authentication on this address, such as :9090, instead
+		// of to admins on the main port.
+		MetricsAddr: l.string("METRICS_ADDR", ""),
+		// How long shutdown waits for in-flight requests and background jobs.
+		ShutdownTimeout: l.duration("SHUTDOWN_TIMEOUT", 30*time.Second, time.Second),
+		// How long /readyz reports 503 before the listener closes, so load balancers
+		// notice and stop sending requests first.
+		ShutdownDelay: l.duration("SHUTDOWN_DELAY", 5*time.Second, 0),
 	}
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("FATAL: DATABASE_URL environment variable is not set.")
+	level := l.string("LOG_LEVEL", "info")
+	if cfg.LogLevel, err = logging.ParseLevel(level); err != nil {
+		l.errorf("LOG_LEVEL", "must be one of debug, info, warn, error, got %q", level)
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("FATAL: JWT_SECRET environment variable is not set.")
+	if cfg.DatabaseURL == "" {
+		l.errorf("DATABASE_URL", "is required")
+	}
+	if cfg.DatabaseMaxIdleConns > cfg.DatabaseMaxOpenConns {
+		l.errorf("DATABASE_MAX_IDLE_CONNS", "must not be greater than DATABASE_MAX_OPEN_CONNS (%d)", cfg.DatabaseMaxOpenConns)
+	}
+	// With asymmetric signing the secret is optional and only used to accept
+	// HS256 tokens issued before the switch.
+	if cfg.JWTSecret == "" && cfg.JWTSigningAlgorithm == "HS256" {
+		l.errorf("JWT_SECRET", "is required when JWT_SIGNING_ALG is HS256")
+	}
+	if cfg.RateLimitStore == "redis" && cfg.RedisURL == "" {
+		l.errorf("REDIS_URL", "is required when RATE_LIMIT_STORE is redis")
 	}
+	// Client IPs are only taken from X-Forwarded-For and X-Real-IP when the request
+	// comes from one of these addresses or CIDR ranges.
+	for _, proxy := range cfg.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			l.errorf("TRUSTED_PROXIES", "must be IP addresses or CIDR ranges such as 10.0.0.0/8, got %q", proxy)
+		}
+	}
+	if cfg.MetricsAddr != "" {
+		if _, port, err := net.SplitHostPort(cfg.MetricsAddr); err != nil || port == "" {
+			l.errorf("METRICS_ADDR", "must be an address such as :9090 or 127.0.0.1:9090, got %q", cfg.MetricsAddr)
+		} else if port == strconv.Itoa(cfg.Port) {
+			l.errorf("METRICS_ADDR", "must not use the API port %d", cfg.Port)
+		}
+	}
+	if cfg.Geocoder == "static" && cfg.GeocoderPlacesFile == "" {
+		l.errorf("GEOCODER_PLACES_FILE", "is required when GEOCODER is static")
+	}
+
+	l.checkFileKeys()
+	if err := l.err(); err != nil {
+		return nil, err
+	}
+	cfg.settings = l.settings
+	return cfg, nil
+}
+
+// LoadDatabaseURL reads only DATABASE_URL, from the same sources as LoadConfig,
+// for commands such as migrate that don't need the rest of the configuration.
+func LoadDatabaseURL(configFile string) (string, error) {
+	l, err := newLoader(configFile, ".env")
+	if err != nil {
+		return "", err
+	}
+	databaseURL := l.url("DATABASE_URL", "")
+	if databaseURL == "" {
+		l.errorf("DATABASE_URL", "is required")
+	}
+	return databaseURL, l.err()
+}
+
+// Print writes the effective settings and where each came from. Secrets and
+// passwords in URLs are redacted.
+func (c *Config) Print(w io.Writer) error {
+	return printSettings(w, c.settings)
+}
+
+// Headers clients may need to read: request IDs for support, rate limit state
+// and pagination links.
+const defaultExposedHeaders = "Content-Length,X-Request-ID,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After,Link,X-Total-Count"
+
+// loadCORS reads CORS_ALLOWED_ORIGINS (exact origins, https://*.example.com
+// wildcards or "*"), CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS,
+// CORS_EXPOSED_HEADERS, CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE.
+func loadCORS(l *loader) CORSConfig {
+	cors := CORSConfig{
+		AllowedMethods:   l.list("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE,OPTIONS"),
+		AllowedHeaders:   l.list("CORS_ALLOWED_HEADERS", "Origin,Content-Type,Authorization,X-API-Key,X-Request-ID"),
+		ExposedHeaders:   l.list("CORS_EXPOSED_HEADERS", defaultExposedHeaders),
+		AllowCredentials: l.bool("CORS_ALLOW_CREDENTIALS", false),
+		MaxAge:           l.duration("CORS_MAX_AGE", 12*time.Hour, 0),
+	}
+
+	anyOrigin := false
+	for _, origin := range l.list("CORS_ALLOWED_ORIGINS", "*") {
+		pattern, err := utils.ParseOriginPattern(origin)
+		if err != nil {
+			l.errorf("CORS_ALLOWED_ORIGINS", "%v", err)
+			continue
+		}
+		anyOrigin = anyOrigin || pattern.Any()
+		cors.AllowedOrigins = append(cors.AllowedOrigins, pattern)
+	}
+	// Browsers refuse credentialed responses for "*", and reflecting any origin
+	// with credentials would let every site act as the user.
+	if anyOrigin && cors.AllowCredentials {
+		l.errorf("CORS_ALLOW_CREDENTIALS", `cannot be true when CORS_ALLOWED_ORIGINS is "*", list the allowed origins instead`)
+	}
+
+	for _, method := range cors.AllowedMethods {
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			l.errorf("CORS_ALLOWED_METHODS", "unknown method %q", method)
+		}
+	}
+	for key, headers := range map[string][]string{
+		"CORS_ALLOWED_HEADERS": cors.AllowedHeaders,
+		"CORS_EXPOSED_HEADERS": cors.ExposedHeaders,
+	} {
+		for _, header := range headers {
+			if !validHeaderName.MatchString(header) {
+				l.errorf(key, "invalid header name %q", header)
+			}
+		}
+	}
+	return cors
+}
+
+var validHeaderName = regexp.MustCompile(`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`)
+
+// loadEmail reads EMAIL_SENDER (smtp, or log in development), EMAIL_FROM and, for
+// smtp, SMTP_HOST, SMTP_PORT, SMTP_USERNAME and SMTP_PASSWORD.
+func loadEmail(l *loader, appEnv string) EmailConfig {
+	defaultSender := "smtp"
+	if appEnv == "development" {
+		defaultSender = "log"
+	}
+	email := EmailConfig{Sender: l.oneOf("EMAIL_SENDER", defaultSender, "smtp", "log")}
+	if email.Sender == "log" {
+		// The log sender writes verification tokens to the logs instead of
+		// delivering them.
+		if appEnv != "development" {
+			l.errorf("EMAIL_SENDER", "can only be log when APP_ENV is development")
+		}
+		return email
+	}
+
+	email.SMTPHost = l.string("SMTP_HOST", "")
+	email.SMTPPort = l.int("SMTP_PORT", 587, 1, 65535)
+	email.SMTPUsername = l.string("SMTP_USERNAME", "")
+	email.SMTPPassword = l.secret("SMTP_PASSWORD")
+	email.From = l.string("EMAIL_FROM", "")
+	if email.SMTPHost == "" {
+		l.errorf("SMTP_HOST", "is required when EMAIL_SENDER is smtp")
+	}
+	if email.From == "" {
+		l.errorf("EMAIL_FROM", "is required when EMAIL_SENDER is smtp")
+	} else if _, err := mail.ParseAddress(email.From); err != nil {
+		l.errorf("EMAIL_FROM", "must be an email address such as \"Event Booking <no-reply@example.com>\", got %q", email.From)
+	}
+	return email
+}
+
+// loadRateLimit reads RATE_LIMIT_<GROUP> (a policy such as "60/1m" or "off") and
+// RATE_LIMIT_<GROUP>_BY (ip, user or api_key).
+func loadRateLimit(l *loader, group string, defaultPolicy string, defaultIdentity ratelimit.Identity) RateLimitConfig {
+	key := "RATE_LIMIT_" + group
+	policy, err := ratelimit.ParsePolicy(l.string(key, defaultPolicy))
+	if err != nil {
+		l.errorf(key, "%v", err)
+	}
+
+	identity, err := ratelimit.ParseIdentity(l.string(key+"_BY", string(defaultIdentity)))
+	if err != nil {
+		l.errorf(key+"_BY", "%v", err)
+	}
+	return RateLimitConfig{Policy: policy, Identity: identity}
+}
+
+// loadOIDCProviders reads the providers listed in OIDC_PROVIDERS (comma-separated names).
+// Each provider NAME is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL and optionally OIDC_<NAME>_SCOPES.
+func loadOIDCProviders(l *loader) []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range l.list("OIDC_PROVIDERS", "") {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
 
-	return &Config{
-		DatabaseURL: dbURL,
-		JWTSecret:   jwtSecret,
+		provider := OIDCProviderConfig{
+			Name:         name,
+			IssuerURL:    l.string(prefix+"ISSUER", ""),
+			ClientID:     l.string(prefix+"CLIENT_ID", ""),
+			ClientSecret: l.secret(prefix + "CLIENT_SECRET"),
+			RedirectURL:  l.string(prefix+"REDIRECT_URL", ""),
+			Scopes:       strings.Fields(strings.ReplaceAll(l.string(prefix+"SCOPES", "openid email profile"), ",", " ")),
+		}
+		for _, required := range []struct{ key, value string }{
+			{"ISSUER", provider.IssuerURL},
+			{"CLIENT_ID", provider.ClientID},
+			{"REDIRECT_URL", provider.RedirectURL},
+		} {
+			if required.value == "" {
+				l.errorf(prefix+required.key, "is required for OIDC provider %q", name)
+			}
+		}
+		providers = append(providers, provider)
 	}
+	return providers
 }
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	sourceEnv     = "environment"
+	sourceDotEnv  = ".env"
+	sourceFile    = "file"
+	sourceDefault = "default"
+)
+
+// layer is one source of settings, keyed by environment variable name.
+type layer struct {
+	name   string
+	lookup func(key string) (string, bool)
+}
+
+// setting records the effective value of a key for --print-config.
+type setting struct {
+	key    string
+	value  string
+	source string
+	kind   settingKind
+}
+
+type settingKind int
+
+const (
+	plainSetting settingKind = iota
+	secretSetting
+	urlSetting // may embed a password
+)
+
+// loader reads settings from its layers in order of precedence, records where
+// each value came from and collects validation errors so they can be reported
+// together.
+type loader struct {
+	layers   []layer
+	fileKeys map[string]bool
+	settings map[string]setting
+	errs     []string
+}
+
+func newLoader(configFile string, dotEnvFile string) (*loader, error) {
+	l := &loader{settings: make(map[string]setting)}
+	l.layers = append(l.layers, layer{name: sourceEnv, lookup: os.LookupEnv})
+
+	dotEnv, err := godotenv.Read(dotEnvFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", dotEnvFile, err)
+	}
+	if err == nil {
+		l.layers = append(l.layers, mapLayer(sourceDotEnv, dotEnv))
+	}
+
+	if configFile != "" {
+		values, err := readConfigFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		l.fileKeys = make(map[string]bool, len(values))
+		for key := range values {
+			l.fileKeys[key] = true
+		}
+		l.layers = append(l.layers, mapLayer(sourceFile+" "+configFile, values))
+	}
+	return l, nil
+}
+
+func mapLayer(name string, values map[string]string) layer {
+	return layer{name: name, lookup: func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}}
+}
+
+// readConfigFile reads a YAML or TOML file and flattens it into environment
+// variable names, so `database: {max_open_conns: 10}` sets DATABASE_MAX_OPEN_CONNS.
+// Lists are joined with commas.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var tree map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, node interface{}, values map[string]string) {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			name := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+			if prefix != "" {
+				name = prefix + "_" + name
+			}
+			flatten(name, child, values)
+		}
+	case []interface{}:
+		items := make([]string, 0, len(node))
+		for _, item := range node {
+			items = append(items, fmt.Sprint(item))
+		}
+		values[prefix] = strings.Join(items, ",")
+	case nil:
+		values[prefix] = ""
+	default:
+		values[prefix] = fmt.Sprint(node)
+	}
+}
+
+func (l *loader) lookup(key string, def string, kind settingKind) string {
+	value, source := def, sourceDefault
+	for _, layer := range l.layers {
+		if v, ok := layer.lookup(key); ok && v != "" {
+			value, source = v, layer.name
+			break
+		}
+	}
+	l.settings[key] = setting{key: key, value: value, source: source, kind: kind}
+	return value
+}
+
+func (l *loader) errorf(key string, format string, args ...interface{}) {
+	l.errs = append(l.errs, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *loader) string(key string, def string) string {
+	return l.lookup(key, def, plainSetting)
+}
+
+func (l *loader) secret(key string) string {
+	return l.lookup(key, "", secretSetting)
+}
+
+func (l *loader) url(key string, def string) string {
+	return l.lookup(key, def, urlSetting)
+}
+
+func (l *loader) list(key string, def string) []string {
+	var items []string
+	for _, item := range strings.Split(l.string(key, def), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (l *loader) oneOf(key string, def string, allowed ...string) string {
+	value := l.string(key, def)
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	l.errorf(key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	return def
+}
+
+func (l *loader) int(key string, def int, min int, max int) int {
+	raw := l.string(key, strconv.Itoa(def))
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min || value > max {
+		l.errorf(key, "must be a whole number between %d and %d, got %q", min, max, raw)
+		return def
+	}
+	return value
+}
+
+func (l *loader) duration(key string, def time.Duration, min time.Duration) time.Duration {
+	raw := l.string(key, def.String())
+	value, err := time.ParseDuration(raw)
+	if err != nil || value < min {
+		l.errorf(key, "must be a duration of at least %s such as 30s or 2h, got %q", min, raw)
+		return def
+	}
+	return value
+}
+
+func (l *loader) bool(key string, def bool) bool {
+	raw := l.string(key, strconv.FormatBool(def))
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		l.errorf(key, "must be true or false, got %q", raw)
+		return def
+	}
+	return value
+}
+
+// checkFileKeys reports settings in the config file that were never read,
+// which are almost always typos.
+func (l *loader) checkFileKeys() {
+	for key := range l.fileKeys {
+		if _, ok := l.settings[key]; !ok {
+			l.errorf(key, "unknown setting in config file")
+		}
+	}
+}
+
+func (l *loader) err() error {
+	if len(l.errs) == 0 {
+		return nil
+	}
+	sort.Strings(l.errs)
+	return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(l.errs, "\n  - "))
+}
+
+// printSettings writes the effective settings, one per line with their source,
+// with secrets and URL passwords redacted.
+func printSettings(w io.Writer, settings map[string]setting) error {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := settings[key]
+		if _, err := fmt.Fprintf(w, "%s=%s  # %s\n", s.key, s.redacted(), s.source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s setting) redacted() string {
+	switch {
+	case s.value == "":
+		return ""
+	case s.kind == secretSetting:
+		return "[REDACTED]"
+	case s.kind == urlSetting:
+		u, err := url.Parse(s.value)
+		if err != nil {
+			return "[REDACTED]"
+		}
+		return u.Redacted()
+	}
+	return s.value
+}
@@ -0,0 +1,94 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"go-rest-api/migrations"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationsDir is the directory of the migration files inside migrations.Files.
+const migrationsDir = "migrations"
+
+// NewMigrator returns a golang-migrate instance that applies the migrations
+// embedded in the binary to the database.
+func NewMigrator(databaseURL string) (*migrate.Migrate, error) {
+	src, err := iofs.New(migrations.Files, migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not open migrations: %w", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not create migrate instance: %w", err)
+	}
+	return m, nil
+}
+
+// LatestMigrationVersion returns the version of the newest embedded migration, which
+// is the schema version a fully migrated database is expected to have.
+func LatestMigrationVersion() (uint, error) {
+	src, err := iofs.New(migrations.Files, migrationsDir)
+	if err != nil {
+		return 0, fmt.Errorf("could not open migrations: %w", err)
+	}
+	defer src.Close()
+	return lastVersion(src)
+}
+
+func lastVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if err != nil {
+		return 0, fmt.Errorf("could not read migrations: %w", err)
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("could not read migrations: %w", err)
+		}
+		version = next
+	}
+}
+
+// SchemaStatus is the database's schema version compared with the embedded migrations.
+type SchemaStatus struct {
+	Version uint // 0 if no migration has been applied
+	Dirty   bool
+	Latest  uint
+}
+
+// ReadSchemaStatus reads the schema version of the database m migrates.
+func ReadSchemaStatus(m *migrate.Migrate) (SchemaStatus, error) {
+	latest, err := LatestMigrationVersion()
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	version, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return SchemaStatus{}, fmt.Errorf("could not read schema version: %w", err)
+	}
+	return SchemaStatus{Version: version, Dirty: dirty, Latest: latest}, nil
+}
+
+// Check returns an error explaining how to fix the schema unless it is at the
+// newest migration and not dirty.
+func (s SchemaStatus) Check() error {
+	switch {
+	case s.Dirty:
+		return fmt.Errorf("schema version %d is dirty, a migration failed part-way: repair the database by hand, then run `migrate force <version>` with the version it now matches", s.Version)
+	case s.Version == 0:
+		return errors.New("no migrations have been applied: run `migrate up`")
+	case s.Version < s.Latest:
+		return fmt.Errorf("schema version %d is behind this build's version %d: run `migrate up`", s.Version, s.Latest)
+	case s.Version > s.Latest:
+		return fmt.Errorf("schema version %d is newer than this build's version %d: deploy a newer build, or roll back with the newer build's `migrate goto %d`", s.Version, s.Latest, s.Latest)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/request"
+	"go-rest-api/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type APIKeyController struct {
+	apiKeyService services.APIKeyService
+}
+
+func NewAPIKeyController(apiKeyService services.APIKeyService) *APIKeyController {
+	return &APIKeyController{apiKeyService: apiKeyService}
+}
+
+// Create an API key that acts as the current user
+func (a *APIKeyController) CreateMyKey(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req request.CreateAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	key := newAPIKey(req, userID, userID)
+	a.create(c, key)
+}
+
+// List the current user's API keys
+func (a *APIKeyController) GetMyKeys(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	keys, err := a.apiKeyService.GetUserKeys(c, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// Revoke one of the current user's API keys
+func (a *APIKeyController) RevokeMyKey(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	id, ok := uuidParam(c, "id", "API key")
+	if !ok {
+		return
+	}
+
+	err := a.apiKeyService.RevokeUserKey(c, id, userID)
+	a.respondRevoked(c, err)
+}
+
+// Create an API key owned by an organization (admin only)
+func (a *APIKeyController) CreateOrganizationKey(c *gin.Context) {
+	adminID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req request.CreateOrganizationAPIKeyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	key := newAPIKey(req.CreateAPIKeyRequest, req.UserID, adminID)
+	key.Organization = &req.Organization
+	a.create(c, key)
+}
+
+// List organization API keys, optionally filtered by ?organization= (admin only)
+func (a *APIKeyController) GetOrganizationKeys(c *gin.Context) {
+	keys, err := a.apiKeyService.GetOrganizationKeys(c, c.Query("organization"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// Revoke an organization API key (admin only)
+func (a *APIKeyController) RevokeOrganizationKey(c *gin.Context) {
+	id, ok := uuidParam(c, "id", "API key")
+	if !ok {
+		return
+	}
+
+	err := a.apiKeyService.RevokeOrganizationKey(c, id)
+	a.respondRevoked(c, err)
+}
+
+func (a *APIKeyController) create(c *gin.Context, key *model.APIKey) {
+	secret, err := a.apiKeyService.Create(c, key)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// The secret is only returned once; only its hash is stored.
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": secret})
+}
+
+func (a *APIKeyController) respondRevoked(c *gin.Context, err error) {
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func newAPIKey(req request.CreateAPIKeyRequest, userID uuid.UUID, createdBy uuid.UUID) *model.APIKey {
+	return &model.APIKey{
+		Name:               req.Name,
+		Scopes:             req.Scopes,
+		UserID:             userID,
+		CreatedBy:          &createdBy,
+		RateLimitPerMinute: req.RateLimitPerMinute,
+		ExpiresAt:          req.ExpiresAt,
+	}
+}
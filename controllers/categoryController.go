@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/request"
+	"go-rest-api/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryController struct {
+	categoryService services.CategoryService
+}
+
+func NewCategoryController(categoryService services.CategoryService) *CategoryController {
+	return &CategoryController{categoryService: categoryService}
+}
+
+// List all categories with their number of upcoming events
+func (c *CategoryController) GetCategories(ctx *gin.Context) {
+	categories, err := c.categoryService.GetCategories(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, categories)
+}
+
+// Create a category (admin only)
+func (c *CategoryController) CreateCategory(ctx *gin.Context) {
+	var req request.CategoryRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	category := newCategory(req)
+	if err := c.categoryService.CreateCategory(ctx, category); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Category created successfully!", "category": category})
+}
+
+// Replace a category's name, slug and parent (admin only)
+func (c *CategoryController) UpdateCategory(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id", "category")
+	if !ok {
+		return
+	}
+
+	var req request.CategoryRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	category := newCategory(req)
+	category.Id = id
+	if err := c.categoryService.UpdateCategory(ctx, category); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Category updated successfully!", "category": category})
+}
+
+// Delete a category without events or subcategories (admin only)
+func (c *CategoryController) DeleteCategory(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id", "category")
+	if !ok {
+		return
+	}
+
+	if err := c.categoryService.DeleteCategory(ctx, id); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully!"})
+}
+
+func newCategory(req request.CategoryRequest) *model.Category {
+	return &model.Category{Name: req.Name, Slug: req.Slug, ParentId: req.ParentID}
+}
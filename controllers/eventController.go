@@ -1,16 +1,14 @@
 package controllers
 
 import (
-	"database/sql"
-	"errors"
+	"go-rest-api/apperrors"
+	"go-rest-api/geo"
 	"go-rest-api/model"
 	"go-rest-api/services"
-	"go-rest-api/utils"
-	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 type EventController struct {
@@ -22,75 +20,157 @@ func NewEventController(eventService services.EventService) *EventController {
 }
 
 func (c *EventController) CreateEvent(ctx *gin.Context) {
-	userIDVal, exists := ctx.Get("userId")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context. Authentication issue."})
+	userID, _, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
-	userID := userIDVal.(uuid.UUID)
 
 	var event model.Event
-	err := ctx.ShouldBindJSON(&event)
-	if err != nil {
-		validationErrors := utils.GetValidationErrors(err)
-		if validationErrors != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"errors": validationErrors})
-			return
-		}
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	if !bindJSON(ctx, &event) {
 		return
 	}
 
 	event.UserIds = userID
-	err = c.eventService.CreateEvent(ctx, &event)
+	err := c.eventService.CreateEvent(ctx, &event)
 	if err != nil {
-		log.Printf("Error creating event: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
+		ctx.Error(err)
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "Event created successfully!", "event": event})
 }
 
+// GetAllEvents lists events, filtered by the same query parameters as SearchEvents.
 func (c *EventController) GetAllEvents(ctx *gin.Context) {
+	search, ok := searchQuery(ctx)
+	if !ok {
+		return
+	}
+	if !search.IsEmpty() {
+		events, err := c.eventService.SearchEvents(ctx, search)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		ctx.JSON(http.StatusOK, events)
+		return
+	}
+
 	events, err := c.eventService.GetAllEvents(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, events)
 }
 
+// Radius of nearby searches in km when none is given, and the largest allowed.
+const (
+	defaultSearchRadiusKm = 25
+	maxSearchRadiusKm     = 500
+)
+
+// searchQuery reads the event filters from the query parameters.
+func searchQuery(ctx *gin.Context) (model.EventSearch, bool) {
+	search := model.EventSearch{
+		Keyword:  ctx.Query("keyword"),
+		Category: ctx.Query("category"),
+		Location: ctx.Query("location"),
+		Speaker:  strings.TrimSpace(ctx.Query("speaker")),
+	}
+	var ok bool
+	if search.StartDate, ok = dateQuery(ctx, "startDate"); !ok {
+		return search, false
+	}
+	if search.EndDate, ok = dateQuery(ctx, "endDate"); !ok {
+		return search, false
+	}
+
+	// Tags can be repeated (tags=a&tags=b) or comma-separated (tags=a,b)
+	for _, value := range ctx.QueryArray("tags") {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				search.Tags = append(search.Tags, tag)
+			}
+		}
+	}
+	switch ctx.DefaultQuery("tag_match", "any") {
+	case "any":
+	case "all":
+		search.MatchAllTags = true
+	default:
+		ctx.Error(apperrors.ErrInvalidInput.WithMessage("tag_match must be any or all"))
+		return search, false
+	}
+
+	lat, ok := floatQuery(ctx, "lat", -90, 90)
+	if !ok {
+		return search, false
+	}
+	lng, ok := floatQuery(ctx, "lng", -180, 180)
+	if !ok {
+		return search, false
+	}
+	radius, ok := floatQuery(ctx, "radius", 0, maxSearchRadiusKm)
+	if !ok {
+		return search, false
+	}
+	if (lat == nil) != (lng == nil) || (radius != nil && lat == nil) {
+		ctx.Error(apperrors.ErrInvalidInput.WithMessage("lat and lng must be given together, and are required with radius"))
+		return search, false
+	}
+	if lat != nil {
+		search.Near = &geo.Point{Latitude: *lat, Longitude: *lng}
+		search.RadiusKm = defaultSearchRadiusKm
+		if radius != nil {
+			search.RadiusKm = *radius
+		}
+	}
+	return search, true
+}
+
 func (c *EventController) SearchEvents(ctx *gin.Context) {
-	keyword := ctx.Query("keyword")
-	startDate := ctx.Query("startDate") // Expected format: YYYY-MM-DD
-	endDate := ctx.Query("endDate")     // Expected format: YYYY-MM-DD
+	search, ok := searchQuery(ctx)
+	if !ok {
+		return
+	}
 
-	events, err := c.eventService.GetEventsByCriteria(ctx, keyword, startDate, endDate)
+	events, err := c.eventService.SearchEvents(ctx, search)
 	if err != nil {
-		log.Printf("Error searching events: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search events"})
+		ctx.Error(err)
 		return
 	}
 
 	if len(events) == 0 {
-		ctx.JSON(http.StatusOK, gin.H{"message": "No events found matching your criteria", "events": []model.Event{}})
+		ctx.JSON(http.StatusOK, gin.H{"message": "No events found matching your criteria", "events": []model.EventSearchResult{}})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, events)
 }
 
-func (c *EventController) GetEventsByCategory(ctx *gin.Context) {
-	category := ctx.Param("category")
-	if category == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Category parameter is required"})
+// GetEventFacets counts the events matching the search filters by category, tag,
+// location and month.
+func (c *EventController) GetEventFacets(ctx *gin.Context) {
+	search, ok := searchQuery(ctx)
+	if !ok {
+		return
+	}
+
+	facets, err := c.eventService.GetEventFacets(ctx, search)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
+	ctx.JSON(http.StatusOK, facets)
+}
+
+func (c *EventController) GetEventsByCategory(ctx *gin.Context) {
+	category := ctx.Param("category")
 
 	events, err := c.eventService.GetEventsByCategory(ctx, category)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events by category"})
+		ctx.Error(err)
 		return
 	}
 
@@ -98,66 +178,39 @@ func (c *EventController) GetEventsByCategory(ctx *gin.Context) {
 }
 
 func (c *EventController) GetEventByID(ctx *gin.Context) {
-	id := ctx.Param("id")
-	eventID, err := uuid.Parse(id)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
 		return
 	}
 
 	event, err := c.eventService.GetEventByID(ctx, eventID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
-		}
+		ctx.Error(err)
 		return
 	}
 	ctx.JSON(http.StatusOK, event)
 }
 
 func (c *EventController) UpdateEvent(ctx *gin.Context) {
-	userIDVal, exists := ctx.Get("userId")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context. Authentication issue."})
+	userID, userRole, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
-	userID := userIDVal.(uuid.UUID)
-	userRoleVal, exists := ctx.Get("userRole")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found in context. Authentication issue."})
-		return
-	}
-	userRole := userRoleVal.(string)
 
-	id := ctx.Param("id")
-	eventID, err := uuid.Parse(id)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
 		return
 	}
 
 	var event model.Event
-	err = ctx.ShouldBindJSON(&event)
-	if err != nil {
-		validationErrors := utils.GetValidationErrors(err)
-		if validationErrors != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"errors": validationErrors})
-			return
-		}
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	if !bindJSON(ctx, &event) {
 		return
 	}
 
 	event.Id = eventID
-	err = c.eventService.UpdateEvent(ctx, &event, userID, userRole)
+	err := c.eventService.UpdateEvent(ctx, &event, userID, userRole)
 	if err != nil {
-		if err.Error() == "unauthorized: you don't have permission to update this event" {
-			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
-		}
+		ctx.Error(err)
 		return
 	}
 
@@ -165,35 +218,19 @@ func (c *EventController) UpdateEvent(ctx *gin.Context) {
 }
 
 func (c *EventController) DeleteEvent(ctx *gin.Context) {
-	userIDVal, exists := ctx.Get("userId")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context. Authentication issue."})
+	userID, userRole, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
-	userID := userIDVal.(uuid.UUID)
-	userRoleVal, exists := ctx.Get("userRole")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found in context. Authentication issue."})
-		return
-	}
-	userRole := userRoleVal.(string)
 
-	id := ctx.Param("id")
-	eventID, err := uuid.Parse(id)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
 		return
 	}
 
-	err = c.eventService.DeleteEvent(ctx, eventID, userID, userRole)
+	err := c.eventService.DeleteEvent(ctx, eventID, userID, userRole)
 	if err != nil {
-		if err.Error() == "unauthorized: you don't have permission to delete this event" {
-			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-		} else if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
-		}
+		ctx.Error(err)
 		return
 	}
 
@@ -201,33 +238,24 @@ func (c *EventController) DeleteEvent(ctx *gin.Context) {
 }
 
 func (c *EventController) RegisterForEvent(ctx *gin.Context) {
-	userIDVal, exists := ctx.Get("userId")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context. Authentication issue."})
+	userID, _, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
-	userID := userIDVal.(uuid.UUID)
 
-	id := ctx.Param("id")
-	eventID, err := uuid.Parse(id)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
 		return
 	}
 
-	err = c.eventService.RegisterForEvent(ctx, eventID, userID)
+	waitlistEntry, err := c.eventService.RegisterForEvent(ctx, eventID, userID)
 	if err != nil {
-		// Check for specific errors from the service, like "event is full, user added to waitlist"
-		if err.Error() == "event is full, user added to waitlist" {
-			ctx.JSON(http.StatusAccepted, gin.H{"message": err.Error()}) // 202 Accepted might be suitable
-		} else if errors.Is(err, services.ErrAlreadyRegistered) {
-			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-		} else if errors.Is(err, services.ErrEventNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		} else {
-			log.Printf("Error registering for event %d by user %d: %v", eventID, userID, err)
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register for event"})
-		}
+		ctx.Error(err)
+		return
+	}
+
+	if waitlistEntry != nil {
+		ctx.JSON(http.StatusAccepted, gin.H{"message": "event is full, user added to waitlist", "waitlist_entry": waitlistEntry})
 		return
 	}
 
@@ -235,22 +263,21 @@ func (c *EventController) RegisterForEvent(ctx *gin.Context) {
 }
 
 func (c *EventController) CancelEventRegistration(ctx *gin.Context) {
-	userIDVal, exists := ctx.Get("userId")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context. Authentication issue."})
+	userID, _, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
-	userID := userIDVal.(uuid.UUID)
-	id := ctx.Param("id")
-	eventID, err := uuid.Parse(id)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
 		return
 	}
 
-	err = c.eventService.CancelEventRegistration(ctx, eventID, userID)
+	// The service keeps the context for background work, so pass the request's
+	// context rather than the gin.Context, which is reused after the handler returns.
+	err := c.eventService.CancelEventRegistration(ctx.Request.Context(), eventID, userID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel event registration"})
+		ctx.Error(err)
 		return
 	}
 
@@ -258,17 +285,14 @@ func (c *EventController) CancelEventRegistration(ctx *gin.Context) {
 }
 
 func (c *EventController) GetRegisteredEvents(ctx *gin.Context) {
-	userIDVal, exists := ctx.Get("userId")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context. Authentication issue."})
+	userID, _, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
-	userID := userIDVal.(uuid.UUID)
 
 	events, err := c.eventService.GetRegisteredEvents(ctx, userID)
 	if err != nil {
-		log.Printf("Error getting registered events for user %d: %v", userID, err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve registered events"})
+		ctx.Error(err)
 		return
 	}
 
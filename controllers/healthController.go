@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"go-rest-api/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthController struct {
+	healthService services.HealthService
+}
+
+func NewHealthController(healthService services.HealthService) *HealthController {
+	return &HealthController{healthService: healthService}
+}
+
+// Livez reports that the process is up and serving requests. It checks no
+// dependencies, so a database outage doesn't get the instance restarted.
+func (h *HealthController) Livez(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"status": services.HealthStatusUp})
+}
+
+// Readyz reports whether the instance can serve traffic, with the status of each
+// dependency. It responds 503 if any of them is down.
+func (h *HealthController) Readyz(c *gin.Context) {
+	report := h.healthService.Ready(c)
+
+	status := http.StatusOK
+	if report.Status != services.HealthStatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
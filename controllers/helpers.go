@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"go-rest-api/apperrors"
+	"go-rest-api/services"
+	"go-rest-api/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// bindJSON binds the request body into obj. On failure it records a validation
+// or invalid input error for the error middleware and returns false.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err != nil {
+		validationErrors := utils.GetValidationErrors(err)
+		if validationErrors != nil {
+			c.Error(apperrors.ErrValidation.WithDetails(map[string]interface{}{"errors": validationErrors}))
+			return false
+		}
+		c.Error(apperrors.ErrInvalidInput.Wrap(err))
+		return false
+	}
+	return true
+}
+
+// currentUser returns the ID and role set by the auth middleware.
+func currentUser(c *gin.Context) (uuid.UUID, string, bool) {
+	userIDVal, exists := c.Get("userId")
+	if !exists {
+		c.Error(apperrors.ErrUnauthorized.WithMessage("user ID not found in context"))
+		return uuid.Nil, "", false
+	}
+	return userIDVal.(uuid.UUID), c.GetString("userRole"), true
+}
+
+// credentials returns the proof of identity for an account change: the password from
+// the request body, and when and how the caller signed in, as set by the auth middleware.
+func credentials(c *gin.Context, password string) services.Credentials {
+	return services.Credentials{
+		Password:    password,
+		ClientIP:    c.ClientIP(),
+		AuthTime:    c.GetTime("authTime"),
+		AuthMethods: c.GetStringSlice("authMethods"),
+	}
+}
+
+// uuidParam parses the path parameter name as a UUID. what names the resource in the error message.
+func uuidParam(c *gin.Context, name string, what string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		c.Error(apperrors.ErrInvalidInput.WithMessage("invalid " + what + " ID format"))
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// dateQuery returns the query parameter name, which must be a YYYY-MM-DD date if set.
+func dateQuery(c *gin.Context, name string) (string, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return "", true
+	}
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		c.Error(apperrors.ErrInvalidInput.WithMessage(name + " must be a date in YYYY-MM-DD format"))
+		return "", false
+	}
+	return value, true
+}
+
+// floatQuery returns the query parameter name, which must be a number between min
+// and max if set, or nil if it isn't set.
+func floatQuery(c *gin.Context, name string, min, max float64) (*float64, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || !(number >= min && number <= max) {
+		c.Error(apperrors.ErrInvalidInput.WithMessage(name + " must be a number between " +
+			strconv.FormatFloat(min, 'f', -1, 64) + " and " + strconv.FormatFloat(max, 'f', -1, 64)))
+		return nil, false
+	}
+	return &number, true
+}
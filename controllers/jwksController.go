@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"go-rest-api/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSController struct {
+	keys *utils.KeySet
+}
+
+func NewJWKSController(keys *utils.KeySet) *JWKSController {
+	return &JWKSController{keys: keys}
+}
+
+// Publish the public token verification keys
+func (j *JWKSController) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, j.keys.JWKS())
+}
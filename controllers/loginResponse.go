@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/response"
+	"go-rest-api/services"
+	"go-rest-api/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondWithLogin completes a successful first login step by authMethod. It issues an access
+// token, or a short-lived two-factor token when the user still has to verify or enrol a second
+// factor.
+func respondWithLogin(c *gin.Context, user *model.User, authMethod string, keys *utils.KeySet, mfaService services.MFAService) {
+	step, err := mfaService.LoginStep(c, user)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	switch step {
+	case services.MFAStepVerify:
+		mfaToken, err := utils.GenerateMFAToken(user.Id.String(), utils.TokenPurposeMFAVerify, []string{authMethod}, keys)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": mfaToken})
+		return
+	case services.MFAStepEnroll:
+		mfaToken, err := utils.GenerateMFAToken(user.Id.String(), utils.TokenPurposeMFAEnroll, []string{authMethod}, keys)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"mfa_enrollment_required": true, "mfa_token": mfaToken})
+		return
+	}
+
+	respondWithToken(c, user, []string{authMethod}, keys, nil)
+}
+
+// respondWithToken issues an access token for user, who signed in by authMethods; extra
+// fields are added to the response.
+func respondWithToken(c *gin.Context, user *model.User, authMethods []string, keys *utils.KeySet, extra gin.H) {
+	userResponse := response.UserResponse{
+		Id:    user.Id,
+		Email: user.Email,
+		Role:  user.Role,
+	}
+
+	token, err := utils.GenerateToken(user.Email, user.Id.String(), user.Role, authMethods, keys)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	body := gin.H{"user": userResponse, "token": token}
+	for k, v := range extra {
+		body[k] = v
+	}
+	c.JSON(http.StatusOK, body)
+}
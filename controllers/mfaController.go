@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"errors"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"go-rest-api/request"
+	"go-rest-api/services"
+	"go-rest-api/utils"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MFAController struct {
+	mfaService        services.MFAService
+	userService       services.UserService
+	loginGuardService services.LoginGuardService
+	keys              *utils.KeySet
+}
+
+func NewMFAController(mfaService services.MFAService, userService services.UserService, loginGuardService services.LoginGuardService, keys *utils.KeySet) *MFAController {
+	return &MFAController{
+		mfaService:        mfaService,
+		userService:       userService,
+		loginGuardService: loginGuardService,
+		keys:              keys,
+	}
+}
+
+// Get the two-factor status of the current user
+func (m *MFAController) GetStatus(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	status, err := m.mfaService.GetStatus(c, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Start enrolling an authenticator app for the current user
+func (m *MFAController) BeginEnrollment(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	m.beginEnrollment(c, userID)
+}
+
+// Confirm enrollment with a code from the authenticator app
+func (m *MFAController) ConfirmEnrollment(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req request.MFACodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	recoveryCodes, err := m.mfaService.ConfirmEnrollment(c, userID, req.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": recoveryCodes})
+}
+
+// Turn off two-factor authentication, confirmed with a code or recovery code
+func (m *MFAController) Disable(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req request.MFACodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	err := m.mfaService.Disable(c, userID, req.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// Replace all recovery codes, confirmed with a current code
+func (m *MFAController) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req request.MFACodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	recoveryCodes, err := m.mfaService.RegenerateRecoveryCodes(c, userID, req.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// Second login step: exchange the mfa_token and a code for an access token
+func (m *MFAController) VerifyLogin(c *gin.Context) {
+	var req request.MFALoginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.Error(apperrors.ErrInvalidInput.WithMessage("code or recovery_code is required"))
+		return
+	}
+
+	user, token, ok := m.userFromMFAToken(c, req.MFAToken, utils.TokenPurposeMFAVerify)
+	if !ok {
+		return
+	}
+
+	err := m.checkCode(c, user, func() error {
+		return m.mfaService.VerifyLogin(c, user.Id, req.Code, req.RecoveryCode)
+	})
+	if err == nil {
+		err = m.mfaService.UseToken(c, token)
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondWithToken(c, user, token.AuthMethods, m.keys, nil)
+}
+
+// Enrollment during login, for users whose role requires two-factor authentication
+func (m *MFAController) BeginLoginEnrollment(c *gin.Context) {
+	var req request.MFAEnrollLoginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, _, ok := m.userFromMFAToken(c, req.MFAToken, utils.TokenPurposeMFAEnroll)
+	if !ok {
+		return
+	}
+	m.beginEnrollment(c, user.Id)
+}
+
+// Confirm enrollment during login and issue the access token
+func (m *MFAController) ConfirmLoginEnrollment(c *gin.Context) {
+	var req request.MFAConfirmLoginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, token, ok := m.userFromMFAToken(c, req.MFAToken, utils.TokenPurposeMFAEnroll)
+	if !ok {
+		return
+	}
+
+	var recoveryCodes []string
+	err := m.checkCode(c, user, func() (err error) {
+		recoveryCodes, err = m.mfaService.ConfirmEnrollment(c, user.Id, req.Code)
+		return err
+	})
+	if err == nil {
+		err = m.mfaService.UseToken(c, token)
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondWithToken(c, user, token.AuthMethods, m.keys, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// List the two-factor policy per role (admin only)
+func (m *MFAController) GetPolicies(c *gin.Context) {
+	policies, err := m.mfaService.GetPolicies(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// Require or stop requiring two-factor authentication for a role (admin only)
+func (m *MFAController) SetPolicy(c *gin.Context) {
+	var policy model.MFAPolicy
+	if !bindJSON(c, &policy) {
+		return
+	}
+
+	err := m.mfaService.SetPolicy(c, &policy)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policy": policy})
+}
+
+func (m *MFAController) beginEnrollment(c *gin.Context, userID uuid.UUID) {
+	enrollment, err := m.mfaService.BeginEnrollment(c, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// checkCode runs verify, which checks a two-factor code during login. Wrong codes
+// count as failed logins so they are throttled like passwords.
+func (m *MFAController) checkCode(c *gin.Context, user *model.User, verify func() error) error {
+	if err := m.loginGuardService.Check(c, user.Email, c.ClientIP()); err != nil {
+		return err
+	}
+
+	err := verify()
+	if errors.Is(err, services.ErrInvalidMFACode) {
+		if recordErr := m.loginGuardService.RecordFailure(c, user.Email, c.ClientIP()); recordErr != nil {
+			slog.ErrorContext(c, "failed to record failed two-factor attempt", "user_id", user.Id, "error", recordErr)
+		}
+	}
+	return err
+}
+
+// userFromMFAToken returns the user of the mfa_token and its claims. The token is
+// only marked as used once the step it allows has succeeded, see MFAService.UseToken.
+func (m *MFAController) userFromMFAToken(c *gin.Context, mfaToken string, purpose string) (*model.User, *utils.MFATokenClaims, bool) {
+	token, err := utils.ValidateMFAToken(mfaToken, purpose, m.keys)
+	if err != nil {
+		c.Error(services.ErrInvalidMFAToken)
+		return nil, nil, false
+	}
+	userID, err := uuid.Parse(token.UserID)
+	if err != nil {
+		c.Error(services.ErrInvalidMFAToken)
+		return nil, nil, false
+	}
+
+	user, err := m.userService.GetUserByID(c, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			err = services.ErrInvalidMFAToken
+		}
+		c.Error(err)
+		return nil, nil, false
+	}
+	return user, token, true
+}
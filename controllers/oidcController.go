@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"go-rest-api/apperrors"
+	"go-rest-api/services"
+	"go-rest-api/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCController struct {
+	oidcService services.OIDCService
+	mfaService  services.MFAService
+	keys        *utils.KeySet
+}
+
+func NewOIDCController(oidcService services.OIDCService, mfaService services.MFAService, keys *utils.KeySet) *OIDCController {
+	return &OIDCController{
+		oidcService: oidcService,
+		mfaService:  mfaService,
+		keys:        keys,
+	}
+}
+
+// List the configured identity providers
+func (o *OIDCController) GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": o.oidcService.Providers()})
+}
+
+// Redirect the user agent to the provider's authorization endpoint
+func (o *OIDCController) Login(c *gin.Context) {
+	authURL, err := o.oidcService.AuthorizationURL(c, c.Param("provider"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Handle the provider's redirect back and issue our own token
+func (o *OIDCController) Callback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.Error(services.ErrOIDCLoginFailed.WithMessage("login was rejected by identity provider").WithDetails(map[string]interface{}{"reason": providerErr}))
+		return
+	}
+
+	state := c.Query("state")
+	code := c.Query("code")
+	if state == "" || code == "" {
+		c.Error(apperrors.ErrInvalidInput.WithMessage("state and code query parameters are required"))
+		return
+	}
+
+	user, err := o.oidcService.HandleCallback(c, c.Param("provider"), state, code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondWithLogin(c, user, utils.AuthMethodOIDC, o.keys, o.mfaService)
+}
+
+// List the external identities linked to the current user
+func (o *OIDCController) GetMyIdentities(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	identities, err := o.oidcService.GetIdentities(c, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"identities": identities})
+}
@@ -1,16 +1,11 @@
 package controllers
 
 import (
-	"database/sql"
-	"errors"
 	"go-rest-api/model"
 	"go-rest-api/services"
-	"go-rest-api/utils"
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 type ReviewController struct {
@@ -22,41 +17,25 @@ func NewReviewController(reviewService services.ReviewService) *ReviewController
 }
 
 func (c *ReviewController) CreateReview(ctx *gin.Context) {
-	userIDVal, exists := ctx.Get("userId")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+	userID, _, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
-	userID := userIDVal.(uuid.UUID)
 
-	eventIDStr := ctx.Param("id")
-	eventID, err := uuid.Parse(eventIDStr)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID format"})
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
 		return
 	}
 
 	var review model.Review
-	if err := ctx.ShouldBindJSON(&review); err != nil {
-		validationErrors := utils.GetValidationErrors(err)
-		if validationErrors != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"errors": validationErrors})
-			return
-		}
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	if !bindJSON(ctx, &review) {
 		return
 	}
 
 	review.EventID = eventID // Set EventID from path parameter
 
 	if err := c.reviewService.CreateReview(ctx.Request.Context(), &review, userID); err != nil {
-		log.Printf("Error creating review: %v", err)
-		// Handle specific errors from service, e.g., already reviewed, not registered
-		if err.Error() == "you have already reviewed this event" || err.Error() == "user not registered for this event, cannot review" || err.Error() == "event not found" {
-			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
-		}
+		ctx.Error(err)
 		return
 	}
 
@@ -64,21 +43,14 @@ func (c *ReviewController) CreateReview(ctx *gin.Context) {
 }
 
 func (c *ReviewController) GetReviewsForEvent(ctx *gin.Context) {
-	eventIDStr := ctx.Param("id")
-	eventID, err := uuid.Parse(eventIDStr)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID format"})
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
 		return
 	}
 
 	reviews, err := c.reviewService.GetReviewsForEvent(ctx.Request.Context(), eventID)
 	if err != nil {
-		log.Printf("Error getting reviews for event %d: %v", eventID, err)
-		if errors.Is(err, sql.ErrNoRows) || err.Error() == "event not found" { // Check for specific "not found" cases
-			ctx.JSON(http.StatusNotFound, gin.H{"error": "Event not found or no reviews available"})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reviews"})
-		}
+		ctx.Error(err)
 		return
 	}
 
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"go-rest-api/apperrors"
+	"go-rest-api/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SecurityController struct {
+	loginGuardService services.LoginGuardService
+}
+
+func NewSecurityController(loginGuardService services.LoginGuardService) *SecurityController {
+	return &SecurityController{loginGuardService: loginGuardService}
+}
+
+// Unlock a user's account after a lockout (admin only)
+func (s *SecurityController) UnlockUser(c *gin.Context) {
+	adminID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	userID, ok := uuidParam(c, "id", "user")
+	if !ok {
+		return
+	}
+
+	err := s.loginGuardService.UnlockUser(c, userID, adminID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User account unlocked"})
+}
+
+// List recent audit events, optionally filtered by ?type= (admin only)
+func (s *SecurityController) GetAuditEvents(c *gin.Context) {
+	limit := 100
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			c.Error(apperrors.ErrInvalidInput.WithMessage("limit must be between 1 and 1000"))
+			return
+		}
+		limit = parsed
+	}
+
+	events, err := s.loginGuardService.GetAuditEvents(c, c.Query("type"), limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
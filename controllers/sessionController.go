@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/request"
+	"go-rest-api/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionController struct {
+	sessionService services.SessionService
+}
+
+func NewSessionController(sessionService services.SessionService) *SessionController {
+	return &SessionController{sessionService: sessionService}
+}
+
+// List an event's sessions by start time
+func (c *SessionController) GetSessions(ctx *gin.Context) {
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
+		return
+	}
+
+	sessions, err := c.sessionService.GetSessions(ctx, eventID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+// Add a session to an event (owner or admin only)
+func (c *SessionController) CreateSession(ctx *gin.Context) {
+	userID, userRole, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
+		return
+	}
+
+	var req request.CreateSessionRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	session := &model.Session{
+		EventID:    eventID,
+		Title:      &req.Title,
+		Track:      req.Track,
+		Speaker:    req.Speaker,
+		Room:       req.Room,
+		StartTime:  &req.StartTime,
+		EndTime:    &req.EndTime,
+		Capacity:   req.Capacity,
+		SpeakerIDs: req.SpeakerIDs,
+	}
+	if err := c.sessionService.CreateSession(ctx, session, userID, userRole); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Session created successfully!", "session": session})
+}
+
+// Change the given fields of a session (owner or admin only)
+func (c *SessionController) UpdateSession(ctx *gin.Context) {
+	userID, userRole, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
+		return
+	}
+	sessionID, ok := uuidParam(ctx, "sessionId", "session")
+	if !ok {
+		return
+	}
+
+	var session model.Session
+	if !bindJSON(ctx, &session) {
+		return
+	}
+
+	session.Id, session.EventID = sessionID, eventID
+	if err := c.sessionService.UpdateSession(ctx, &session, userID, userRole); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Session updated successfully!", "session": session})
+}
+
+// Delete a session and its registrations (owner or admin only)
+func (c *SessionController) DeleteSession(ctx *gin.Context) {
+	userID, userRole, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
+		return
+	}
+	sessionID, ok := uuidParam(ctx, "sessionId", "session")
+	if !ok {
+		return
+	}
+
+	if err := c.sessionService.DeleteSession(ctx, eventID, sessionID, userID, userRole); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Session deleted successfully!"})
+}
+
+func (c *SessionController) RegisterForSession(ctx *gin.Context) {
+	userID, _, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
+		return
+	}
+	sessionID, ok := uuidParam(ctx, "sessionId", "session")
+	if !ok {
+		return
+	}
+
+	if err := c.sessionService.RegisterForSession(ctx, eventID, sessionID, userID); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Successfully registered for the session"})
+}
+
+func (c *SessionController) CancelSessionRegistration(ctx *gin.Context) {
+	userID, _, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
+		return
+	}
+	sessionID, ok := uuidParam(ctx, "sessionId", "session")
+	if !ok {
+		return
+	}
+
+	if err := c.sessionService.CancelSessionRegistration(ctx, eventID, sessionID, userID); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Successfully cancelled session registration"})
+}
+
+// List the sessions the user registered for, across events
+func (c *SessionController) GetRegisteredSessions(ctx *gin.Context) {
+	userID, _, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	sessions, err := c.sessionService.GetRegisteredSessions(ctx, userID)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, sessions)
+}
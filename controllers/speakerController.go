@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/request"
+	"go-rest-api/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SpeakerController struct {
+	speakerService services.SpeakerService
+}
+
+func NewSpeakerController(speakerService services.SpeakerService) *SpeakerController {
+	return &SpeakerController{speakerService: speakerService}
+}
+
+// List speakers by name, optionally only those whose name contains ?name=
+func (c *SpeakerController) GetSpeakers(ctx *gin.Context) {
+	speakers, err := c.speakerService.GetSpeakers(ctx, ctx.Query("name"))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, speakers)
+}
+
+func (c *SpeakerController) GetSpeaker(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id", "speaker")
+	if !ok {
+		return
+	}
+
+	speaker, err := c.speakerService.GetSpeaker(ctx, id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, speaker)
+}
+
+// List a speaker's upcoming events, including those where they speak in a session
+func (c *SpeakerController) GetSpeakerEvents(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id", "speaker")
+	if !ok {
+		return
+	}
+
+	events, err := c.speakerService.GetSpeakerEvents(ctx, id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, events)
+}
+
+func (c *SpeakerController) CreateSpeaker(ctx *gin.Context) {
+	userID, _, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	var req request.CreateSpeakerRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	speaker := &model.Speaker{
+		Name:     &req.Name,
+		Bio:      req.Bio,
+		PhotoURL: req.PhotoURL,
+		Links:    req.Links,
+		UserID:   userID,
+	}
+	if err := c.speakerService.CreateSpeaker(ctx, speaker); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Speaker created successfully!", "speaker": speaker})
+}
+
+// Change the given fields of a speaker (creator or admin only)
+func (c *SpeakerController) UpdateSpeaker(ctx *gin.Context) {
+	userID, userRole, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	id, ok := uuidParam(ctx, "id", "speaker")
+	if !ok {
+		return
+	}
+
+	var speaker model.Speaker
+	if !bindJSON(ctx, &speaker) {
+		return
+	}
+
+	speaker.Id = id
+	if err := c.speakerService.UpdateSpeaker(ctx, &speaker, userID, userRole); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Speaker updated successfully!", "speaker": speaker})
+}
+
+// Delete a speaker and remove them from their events and sessions (creator or admin only)
+func (c *SpeakerController) DeleteSpeaker(ctx *gin.Context) {
+	userID, userRole, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	id, ok := uuidParam(ctx, "id", "speaker")
+	if !ok {
+		return
+	}
+
+	if err := c.speakerService.DeleteSpeaker(ctx, id, userID, userRole); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Speaker deleted successfully!"})
+}
@@ -1,52 +1,39 @@
 package controllers
 
 import (
-	"errors"
-	"go-rest-api/apperrors"
 	"go-rest-api/model"
+	"go-rest-api/request"
 	"go-rest-api/response"
 	"go-rest-api/services"
 	"go-rest-api/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 type UserController struct {
 	userService services.UserService
-	jwtSecret   string
+	mfaService  services.MFAService
+	keys        *utils.KeySet
 }
 
-func NewUserController(userService services.UserService, jwtSecret string) *UserController {
+func NewUserController(userService services.UserService, mfaService services.MFAService, keys *utils.KeySet) *UserController {
 	return &UserController{
 		userService: userService,
-		jwtSecret:   jwtSecret,
+		mfaService:  mfaService,
+		keys:        keys,
 	}
 }
 
 func (u *UserController) RegisterUser(c *gin.Context) {
 	var user model.User
-	err := c.ShouldBindJSON(&user)
-	if err != nil {
-		validationErrors := utils.GetValidationErrors(err)
-		if validationErrors != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"errors": validationErrors})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	if !bindJSON(c, &user) {
 		return
 	}
 
-	err = u.userService.CreateUser(c, &user)
+	err := u.userService.CreateUser(c, &user)
 	if err != nil {
-		if errors.Is(err, apperrors.ErrAlreadyExists) {
-			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
-		} else if errors.Is(err, apperrors.ErrInvalidInput) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: email and password are required"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
-		}
+		c.Error(err)
 		return
 	}
 
@@ -61,45 +48,22 @@ func (u *UserController) RegisterUser(c *gin.Context) {
 
 func (u *UserController) LoginUser(c *gin.Context) {
 	var user model.User
-	err := c.ShouldBindJSON(&user)
-	if err != nil {
-		validationErrors := utils.GetValidationErrors(err)
-		if validationErrors != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"errors": validationErrors})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-		return
-	}
-
-	err = u.userService.ValidateUser(c, &user)
-	if err != nil {
-		if errors.Is(err, apperrors.ErrUnauthorized) || errors.Is(err, apperrors.ErrNotFound) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
-		}
+	if !bindJSON(c, &user) {
 		return
 	}
-	userResponse := response.UserResponse{
-		Id:    user.Id,
-		Email: user.Email,
-		Role:  user.Role,
-	}
 
-	token, err := utils.GenerateToken(user.Email, user.Id.String(), user.Role, u.jwtSecret)
+	err := u.userService.ValidateUser(c, &user, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		c.Error(err)
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"user": userResponse, "token": token})
+	respondWithLogin(c, &user, utils.AuthMethodPassword, u.keys, u.mfaService)
 }
 
 func (u *UserController) GetAllUser(c *gin.Context) {
 	users, err := u.userService.GetAllUsers(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		c.Error(err)
 		return
 	}
 
@@ -116,19 +80,13 @@ func (u *UserController) GetAllUser(c *gin.Context) {
 }
 
 func (u *UserController) GetUserByID(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+	id, ok := uuidParam(c, "id", "user")
+	if !ok {
 		return
 	}
 	user, err := u.userService.GetUserByID(c, id)
 	if err != nil {
-		if errors.Is(err, apperrors.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
-		}
+		c.Error(err)
 		return
 	}
 	userResponse := response.UserResponse{
@@ -140,37 +98,20 @@ func (u *UserController) GetUserByID(c *gin.Context) {
 }
 
 func (u *UserController) UpdateUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+	id, ok := uuidParam(c, "id", "user")
+	if !ok {
 		return
 	}
 
 	var user model.User
-	err = c.ShouldBindJSON(&user)
-	if err != nil {
-		validationErrors := utils.GetValidationErrors(err)
-		if validationErrors != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"errors": validationErrors})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	if !bindJSON(c, &user) {
 		return
 	}
 	user.Id = id
 
-	err = u.userService.UpdateUser(c, &user)
+	err := u.userService.UpdateUser(c, &user)
 	if err != nil {
-		if errors.Is(err, apperrors.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		} else if errors.Is(err, apperrors.ErrInvalidInput) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
-		} else if errors.Is(err, apperrors.ErrAlreadyExists) {
-			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-		}
+		c.Error(err)
 		return
 	}
 
@@ -183,21 +124,150 @@ func (u *UserController) UpdateUser(c *gin.Context) {
 }
 
 func (u *UserController) DeleteUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := uuid.Parse(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+	id, ok := uuidParam(c, "id", "user")
+	if !ok {
 		return
 	}
 
-	err = u.userService.DeleteUser(c, id)
+	err := u.userService.DeleteUser(c, id)
 	if err != nil {
-		if errors.Is(err, apperrors.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
-		}
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusNoContent, gin.H{"message": "User deleted successfully"})
 }
+
+func (u *UserController) GetProfile(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	user, err := u.userService.GetUserByID(c, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": newProfileResponse(user)})
+}
+
+func (u *UserController) UpdateProfile(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req request.UpdateProfileRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := u.userService.UpdateProfile(c, &model.User{
+		Id:          userID,
+		DisplayName: req.DisplayName,
+		AvatarURL:   req.AvatarURL,
+		Preferences: req.Preferences,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": newProfileResponse(user)})
+}
+
+func (u *UserController) ChangePassword(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req request.ChangePasswordRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	err := u.userService.ChangePassword(c, userID, credentials(c, req.CurrentPassword), req.NewPassword)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+func (u *UserController) RequestEmailChange(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req request.ChangeEmailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	err := u.userService.RequestEmailChange(c, userID, req.NewEmail, credentials(c, req.Password))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification token sent to the new email address"})
+}
+
+func (u *UserController) VerifyEmailChange(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req request.VerifyEmailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	user, err := u.userService.ConfirmEmailChange(c, userID, req.Token)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": newProfileResponse(user)})
+}
+
+func (u *UserController) DeleteAccount(c *gin.Context) {
+	userID, _, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req request.DeleteAccountRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	err := u.userService.DeleteAccount(c, userID, credentials(c, req.Password))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func newProfileResponse(user *model.User) response.ProfileResponse {
+	preferences := user.Preferences
+	if preferences == nil {
+		preferences = model.UserPreferences{}
+	}
+	return response.ProfileResponse{
+		Id:           user.Id,
+		Email:        user.Email,
+		Role:         user.Role,
+		DisplayName:  user.DisplayName,
+		AvatarURL:    user.AvatarURL,
+		Preferences:  preferences,
+		PendingEmail: user.PendingEmail,
+	}
+}
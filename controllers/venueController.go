@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"go-rest-api/model"
+	"go-rest-api/request"
+	"go-rest-api/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VenueController struct {
+	venueService services.VenueService
+}
+
+func NewVenueController(venueService services.VenueService) *VenueController {
+	return &VenueController{venueService: venueService}
+}
+
+func (c *VenueController) GetVenues(ctx *gin.Context) {
+	venues, err := c.venueService.GetVenues(ctx)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, venues)
+}
+
+func (c *VenueController) GetVenue(ctx *gin.Context) {
+	id, ok := uuidParam(ctx, "id", "venue")
+	if !ok {
+		return
+	}
+
+	venue, err := c.venueService.GetVenue(ctx, id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, venue)
+}
+
+func (c *VenueController) CreateVenue(ctx *gin.Context) {
+	userID, _, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	var req request.CreateVenueRequest
+	if !bindJSON(ctx, &req) {
+		return
+	}
+
+	venue := &model.Venue{
+		Name:          &req.Name,
+		Address:       &req.Address,
+		Latitude:      req.Latitude,
+		Longitude:     req.Longitude,
+		Capacity:      req.Capacity,
+		Accessibility: req.Accessibility,
+		TimeZone:      &req.TimeZone,
+		UserID:        userID,
+	}
+	if err := c.venueService.CreateVenue(ctx, venue); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"message": "Venue created successfully!", "venue": venue})
+}
+
+// Change the given fields of a venue (creator or admin only)
+func (c *VenueController) UpdateVenue(ctx *gin.Context) {
+	userID, userRole, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	id, ok := uuidParam(ctx, "id", "venue")
+	if !ok {
+		return
+	}
+
+	var venue model.Venue
+	if !bindJSON(ctx, &venue) {
+		return
+	}
+
+	venue.Id = id
+	if err := c.venueService.UpdateVenue(ctx, &venue, userID, userRole); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Venue updated successfully!", "venue": venue})
+}
+
+// Delete a venue; its events stay without one (creator or admin only)
+func (c *VenueController) DeleteVenue(ctx *gin.Context) {
+	userID, userRole, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	id, ok := uuidParam(ctx, "id", "venue")
+	if !ok {
+		return
+	}
+
+	if err := c.venueService.DeleteVenue(ctx, id, userID, userRole); err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Venue deleted successfully!"})
+}
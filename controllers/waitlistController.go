@@ -1,14 +1,12 @@
 package controllers
 
 import (
-	"errors"
+	"go-rest-api/apperrors"
 	"go-rest-api/model"
 	"go-rest-api/services"
-	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 type WaitlistController struct {
@@ -25,32 +23,19 @@ func NewWaitlistController(waitlistService services.WaitlistService, eventServic
 
 // Join the waitlist for an event
 func (c *WaitlistController) JoinWaitlist(ctx *gin.Context) {
-	userIDVal, exists := ctx.Get("userId")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+	userID, _, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
-	userID := userIDVal.(uuid.UUID)
 
-	eventIDStr := ctx.Param("id")
-	eventID, err := uuid.Parse(eventIDStr)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID format"})
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
 		return
 	}
 
 	entry, err := c.waitlistService.JoinWaitlist(ctx.Request.Context(), eventID, userID)
 	if err != nil {
-		log.Printf("Error joining waitlist for event %d by user %d: %v", eventID, userID, err)
-		if errors.Is(err, services.ErrEventNotFull) ||
-			errors.Is(err, services.ErrAlreadyRegistered) ||
-			errors.Is(err, services.ErrAlreadyOnWaitlist) ||
-			errors.Is(err, services.ErrEventNotFound) ||
-			errors.Is(err, services.ErrWaitlistNotEnabled) {
-			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join waitlist"})
-		}
+		ctx.Error(err)
 		return
 	}
 
@@ -59,28 +44,19 @@ func (c *WaitlistController) JoinWaitlist(ctx *gin.Context) {
 
 // Leave the waitlist for an event
 func (c *WaitlistController) LeaveWaitlist(ctx *gin.Context) {
-	userIDVal, exists := ctx.Get("userId")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
+	userID, _, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
-	userID := userIDVal.(uuid.UUID)
 
-	eventIDStr := ctx.Param("id")
-	eventID, err := uuid.Parse(eventIDStr)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID format"})
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
 		return
 	}
 
-	err = c.waitlistService.LeaveWaitlist(ctx.Request.Context(), eventID, userID)
+	err := c.waitlistService.LeaveWaitlist(ctx.Request.Context(), eventID, userID)
 	if err != nil {
-		log.Printf("Error leaving waitlist for event %d by user %d: %v", eventID, userID, err)
-		if errors.Is(err, services.ErrUserNotOnWaitlist) || errors.Is(err, services.ErrEventNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave waitlist"})
-		}
+		ctx.Error(err)
 		return
 	}
 
@@ -89,33 +65,20 @@ func (c *WaitlistController) LeaveWaitlist(ctx *gin.Context) {
 
 // Get the waitlist for an event (admin/owner only)
 func (c *WaitlistController) GetWaitlistForEvent(ctx *gin.Context) {
-	// Get current user ID and role from context
-	userIDVal, exists := ctx.Get("userId")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found in context"})
-		return
-	}
-	userID := userIDVal.(uuid.UUID)
-
-	userRoleVal, exists := ctx.Get("userRole")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found in context"})
+	userID, userRole, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
-	userRole := userRoleVal.(string)
 
-	eventIDStr := ctx.Param("id")
-	eventID, err := uuid.Parse(eventIDStr)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID format"})
+	eventID, ok := uuidParam(ctx, "id", "event")
+	if !ok {
 		return
 	}
 
 	// Fetch the event to get the owner's ID
 	event, err := c.eventService.GetEventByID(ctx.Request.Context(), eventID)
 	if err != nil {
-		log.Printf("Error fetching event %d for authorization check: %v", eventID, err)
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		ctx.Error(err)
 		return
 	}
 
@@ -124,18 +87,13 @@ func (c *WaitlistController) GetWaitlistForEvent(ctx *gin.Context) {
 	isOwner := event.UserIds == userID
 
 	if !isAdmin && !isOwner {
-		ctx.JSON(http.StatusForbidden, gin.H{"error": "Access denied: you must be an admin or the event owner to view the waitlist"})
+		ctx.Error(apperrors.ErrForbidden.WithMessage("you must be an admin or the event owner to view the waitlist"))
 		return
 	}
 
 	entries, err := c.waitlistService.GetWaitlistForEvent(ctx.Request.Context(), eventID)
 	if err != nil {
-		log.Printf("Error getting waitlist for event %d: %v", eventID, err)
-		if errors.Is(err, services.ErrEventNotFound) {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve waitlist"})
-		}
+		ctx.Error(err)
 		return
 	}
 
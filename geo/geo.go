@@ -0,0 +1,35 @@
+// Package geo handles event coordinates: distances and geocoding of locations.
+package geo
+
+import "math"
+
+// Point is a position in decimal degrees.
+type Point struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// EarthRadiusKm is the mean radius of the earth used for distances.
+const EarthRadiusKm = 6371.0
+
+// BoundingBox returns the latitude and longitude ranges that contain every point
+// within radiusKm of p, for filtering with an index before computing distances.
+// wrapsLongitude is true when the circle crosses the antimeridian or a pole; the
+// longitude range then covers everything and shouldn't be used.
+func BoundingBox(p Point, radiusKm float64) (minLat, maxLat, minLng, maxLng float64, wrapsLongitude bool) {
+	deltaLat := radiusKm / EarthRadiusKm * 180 / math.Pi
+	minLat, maxLat = p.Latitude-deltaLat, p.Latitude+deltaLat
+	if minLat <= -90 || maxLat >= 90 {
+		return math.Max(minLat, -90), math.Min(maxLat, 90), -180, 180, true
+	}
+
+	// Degrees of longitude get shorter towards the poles; use the widest
+	// latitude of the box so the range is never too narrow.
+	widest := math.Max(math.Abs(minLat), math.Abs(maxLat)) * math.Pi / 180
+	deltaLng := deltaLat / math.Cos(widest)
+	minLng, maxLng = p.Longitude-deltaLng, p.Longitude+deltaLng
+	if minLng < -180 || maxLng > 180 {
+		return minLat, maxLat, -180, 180, true
+	}
+	return minLat, maxLat, minLng, maxLng, false
+}
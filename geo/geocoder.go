@@ -0,0 +1,74 @@
+package geo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned by a Geocoder that doesn't know an address.
+var ErrNotFound = errors.New("address not found")
+
+// Geocoder looks up the coordinates of a free-text address.
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (Point, error)
+}
+
+type staticGeocoder struct {
+	places map[string]Point
+}
+
+// NewStaticGeocoder returns an offline Geocoder that knows the given places.
+// Names are matched ignoring case and extra spaces. An address that isn't listed
+// matches the place named by its trailing comma-separated parts, so with "Berlin,
+// Germany" listed, "Hall 3, Messe Berlin, Berlin, Germany" gets Berlin's coordinates.
+func NewStaticGeocoder(places map[string]Point) Geocoder {
+	normalized := make(map[string]Point, len(places))
+	for name, point := range places {
+		normalized[strings.Join(addressParts(name), ", ")] = point
+	}
+	return &staticGeocoder{places: normalized}
+}
+
+// LoadStaticGeocoder reads the places for NewStaticGeocoder from a JSON file
+// mapping names to {"latitude": ..., "longitude": ...}.
+func LoadStaticGeocoder(path string) (Geocoder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read geocoder places: %w", err)
+	}
+	var places map[string]Point
+	if err := json.Unmarshal(data, &places); err != nil {
+		return nil, fmt.Errorf("failed to parse geocoder places %s: %w", path, err)
+	}
+	for name, point := range places {
+		if point.Latitude < -90 || point.Latitude > 90 || point.Longitude < -180 || point.Longitude > 180 {
+			return nil, fmt.Errorf("geocoder place %q in %s has invalid coordinates", name, path)
+		}
+	}
+	return NewStaticGeocoder(places), nil
+}
+
+func (g *staticGeocoder) Geocode(ctx context.Context, address string) (Point, error) {
+	parts := addressParts(address)
+	for i := range parts {
+		if point, ok := g.places[strings.Join(parts[i:], ", ")]; ok {
+			return point, nil
+		}
+	}
+	return Point{}, ErrNotFound
+}
+
+// addressParts splits an address at commas into lower-case parts with single spaces.
+func addressParts(address string) []string {
+	var parts []string
+	for _, part := range strings.Split(strings.ToLower(address), ",") {
+		if part = strings.Join(strings.Fields(part), " "); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+	// JWTs and API keys that end up in messages or error strings.
+	tokenPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*|ebk_[0-9a-f]+_[A-Za-z0-9_\-]+`)
+)
+
+// sensitiveKeys are attribute keys whose values are never logged.
+var sensitiveKeys = map[string]bool{
+	"token":         true,
+	"password":      true,
+	"secret":        true,
+	"authorization": true,
+	"api_key":       true,
+	"code":          true,
+	"recovery_code": true,
+}
+
+// redactAttr removes secrets and masks email addresses. Keys in sensitiveKeys or
+// ending in _token, _secret or _password are replaced entirely; emails anywhere in
+// a string, including the message, are reduced to their first letter and domain.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	if sensitiveKeys[key] || strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_secret") || strings.HasSuffix(key, "_password") {
+		return slog.String(a.Key, redacted)
+	}
+
+	if a.Value.Kind() == slog.KindString || a.Value.Kind() == slog.KindAny {
+		var s string
+		if a.Value.Kind() == slog.KindString {
+			s = a.Value.String()
+		} else if err, ok := a.Value.Any().(error); ok {
+			s = err.Error()
+		} else {
+			return a
+		}
+		if masked := Redact(s); masked != s {
+			return slog.String(a.Key, masked)
+		}
+	}
+	return a
+}
+
+// Redact masks email addresses and removes tokens from s.
+func Redact(s string) string {
+	s = tokenPattern.ReplaceAllString(s, redacted)
+	return emailPattern.ReplaceAllString(s, "$1***@$2")
+}
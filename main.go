@@ -1,73 +1,265 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"go-rest-api/config"
 	"go-rest-api/connection"
 	"go-rest-api/controllers"
+	"go-rest-api/geo"
 	"go-rest-api/helper"
+	"go-rest-api/logging"
+	"go-rest-api/metrics"
 	"go-rest-api/middleware"
+	"go-rest-api/ratelimit"
 	"go-rest-api/repository"
 	"go-rest-api/services"
+	"go-rest-api/tracing"
+	"go-rest-api/utils"
+	"go-rest-api/worker"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+	// Time zone names of venues are validated without relying on the system's zoneinfo
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
 
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Parse()
+
+	if flag.Arg(0) == "migrate" {
+		os.Exit(runMigrateCommand(*configFile, flag.Args()[1:]))
+	}
+
 	// Load configuration
-	cfg := config.LoadConfig()
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		helper.PanicIfError(cfg.Print(os.Stdout))
+		return
+	}
+	utils.TokenTTL = cfg.TokenTTL
+	utils.BcryptCost = cfg.BcryptCost
+
+	// Cancelled on SIGINT or SIGTERM to start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Structured logging; records carry the request ID from the context
+	logger, err := logging.New(os.Stdout, logging.Options{Level: cfg.LogLevel, Format: cfg.LogFormat, Redact: cfg.LogRedact})
+	helper.PanicIfError(err)
+	slog.SetDefault(logger)
+
+	// Tracing: spans for requests, service methods and SQL queries
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter)
+	helper.PanicIfError(err)
+	defer shutdownTracing(context.Background())
 
 	// Initialize the database connection
-	db, err := connection.DbConnect(cfg.DatabaseURL)
+	db, err := connection.DbConnect(cfg.DatabaseURL, connection.PoolOptions{
+		MaxOpenConns:    cfg.DatabaseMaxOpenConns,
+		MaxIdleConns:    cfg.DatabaseMaxIdleConns,
+		ConnMaxLifetime: cfg.DatabaseConnMaxLifetime,
+	})
 	helper.PanicIfError(err)
 	defer db.Close()
+	metrics.RegisterDB(db, "postgres")
 
-	// Configure CORS
+	// Refuse to start on a schema this build wasn't written for; readiness keeps
+	// checking the same version afterwards
+	expectedMigrationVersion, err := prepareSchema(cfg.DatabaseURL, cfg.MigrateOnStart)
+	if err != nil {
+		slog.Error("database schema is not ready", "error", err)
+		os.Exit(1)
+	}
+
+	// Rate limit buckets are kept in memory, or in Redis to share them between instances
+	rateLimitStore := ratelimit.NewMemoryStore()
+	if cfg.RateLimitStore == "redis" {
+		redisOptions, err := redis.ParseURL(cfg.RedisURL)
+		helper.PanicIfError(err)
+		redisClient := redis.NewClient(redisOptions)
+		defer redisClient.Close()
+		rateLimitStore = ratelimit.NewRedisStore(redisClient, "event-booking:ratelimit:")
+	}
+
+	// Coordinates for events created without them are looked up from their location
+	var geocoder geo.Geocoder
+	if cfg.Geocoder == "static" {
+		geocoder, err = geo.LoadStaticGeocoder(cfg.GeocoderPlacesFile)
+		helper.PanicIfError(err)
+	}
+
+	// Verification emails go through SMTP; the log sender is only allowed in development
+	emailSender := services.NewLogEmailSender()
+	if cfg.Email.Sender == "smtp" {
+		emailSender = services.NewSMTPEmailSender(cfg.Email.SMTPHost, cfg.Email.SMTPPort, cfg.Email.SMTPUsername, cfg.Email.SMTPPassword, cfg.Email.From)
+	}
 
 	// --- Dependency Injection ---
 	// Initialize the repository
 	eventRepo := repository.NewEventRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	venueRepo := repository.NewVenueRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	speakerRepo := repository.NewSpeakerRepository(db)
 	userRepo := repository.NewUserRepository(db)
 	reviewRepo := repository.NewReviewRepository(db)
 	waitlistRepo := repository.NewWaitlistRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	signingKeyRepo := repository.NewSigningKeyRepository(db)
+	loginThrottleRepo := repository.NewLoginThrottleRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	healthRepo := repository.NewHealthRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+
+	// Background jobs started by requests; waited for (or saved) on shutdown
+	jobs := worker.NewGroup(jobRepo)
+
+	// Token signing keys: a shared HS256 secret, or rotated asymmetric keys published via JWKS
+	var keySet *utils.KeySet
+	if cfg.JWTSigningAlgorithm == utils.AlgorithmHS256 {
+		keySet = utils.NewHMACKeySet(cfg.JWTSecret)
+	} else {
+		keySet = utils.NewKeySet()
+		var legacyKeys []utils.SigningKey
+		if cfg.JWTSecret != "" {
+			legacyKeys = append(legacyKeys, utils.NewHMACKey(cfg.JWTSecret, time.Now().Add(utils.TokenTTL)))
+		}
+		keyRotationService := services.NewKeyRotationService(signingKeyRepo, keySet, cfg.JWTSigningAlgorithm, cfg.JWTKeyRotationInterval, legacyKeys...)
+		err = keyRotationService.Refresh(context.Background())
+		helper.PanicIfError(err)
+		go keyRotationService.Run(ctx)
+	}
 
 	// Initialize the service
 	waitlistService := services.NewWaitlistService(waitlistRepo, eventRepo, userRepo)
-	eventService := services.NewEventService(eventRepo, waitlistService) // Pass waitlistService to EventService
-	userService := services.NewUserService(userRepo)
-	reviewService := services.NewReviewService(reviewRepo, eventRepo)
+	eventService := services.NewEventService(eventRepo, categoryRepo, venueRepo, speakerRepo, waitlistService, geocoder, jobs) // Pass waitlistService to EventService
+	categoryService := services.NewCategoryService(categoryRepo)
+	venueService := services.NewVenueService(venueRepo, eventRepo, geocoder)
+	sessionService := services.NewSessionService(sessionRepo, eventRepo, speakerRepo)
+	speakerService := services.NewSpeakerService(speakerRepo, eventRepo)
+	loginGuardService := services.NewLoginGuardService(loginThrottleRepo, auditRepo, userRepo, services.DefaultLoginPolicy())
+	go loginGuardService.Run(ctx)
+	userService := services.NewUserService(userRepo, emailSender, loginGuardService, identityRepo)
+	reviewService := services.NewReviewService(reviewRepo, eventRepo, jobs)
+	oidcService := services.NewOIDCService(identityRepo, userRepo, cfg.OIDCProviders)
+	mfaService := services.NewMFAService(mfaRepo, userRepo)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userRepo)
+	healthService := services.NewHealthService(healthRepo, expectedMigrationVersion)
 
 	// Initialize the controller
 	eventController := controllers.NewEventController(eventService)
-	userController := controllers.NewUserController(userService, cfg.JWTSecret)
+	categoryController := controllers.NewCategoryController(categoryService)
+	venueController := controllers.NewVenueController(venueService)
+	sessionController := controllers.NewSessionController(sessionService)
+	speakerController := controllers.NewSpeakerController(speakerService)
+	userController := controllers.NewUserController(userService, mfaService, keySet)
 	reviewController := controllers.NewReviewController(reviewService)
 	waitlistController := controllers.NewWaitlistController(waitlistService, eventService) // Add WaitlistController
+	oidcController := controllers.NewOIDCController(oidcService, mfaService, keySet)
+	jwksController := controllers.NewJWKSController(keySet)
+	securityController := controllers.NewSecurityController(loginGuardService)
+	mfaController := controllers.NewMFAController(mfaService, userService, loginGuardService, keySet)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	healthController := controllers.NewHealthController(healthService)
+
+	router := gin.New()
+	// Let handlers pass the gin.Context to services so its request ID reaches the logs
+	router.ContextWithFallback = true
+	// Only trust forwarded client IPs from the configured proxies. Login throttling and
+	// IP rate limits would otherwise use whatever X-Forwarded-For a client sends.
+	err = router.SetTrustedProxies(cfg.TrustedProxies)
+	helper.PanicIfError(err)
+
+	// Start a span per request, continuing the caller's trace from the traceparent header
+	router.Use(middleware.Tracing("event-booking"))
+
+	// Tag each request with an ID and log it once it has been handled
+	router.Use(middleware.RequestID())
+	router.Use(middleware.RequestLogger())
+	router.Use(middleware.Metrics())
 
-	router := gin.Default()
+	// Render errors from handlers and middleware as problem+json
+	router.Use(middleware.ErrorHandler())
+	router.Use(middleware.Recovery())
+	router.NoRoute(middleware.NotFoundHandler)
 
 	// Use CORS middleware
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CORSMiddleware(cfg.CORS))
 
-	// Healthcheck endpoint to verify server status
+	// Liveness and readiness probes for the orchestrator
+	router.GET("/livez", healthController.Livez)
+	router.GET("/readyz", healthController.Readyz)
+
+	// Healthcheck endpoint to verify server status (kept for existing clients, see /livez)
 	router.GET("/healthcheck", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Server is running!",
 		})
 	})
 
+	// Prometheus metrics: HTTP latency per route, DB pool stats and booking counters.
+	// Without a separate listener they are only served to admins.
+	if cfg.MetricsAddr == "" {
+		router.GET("/metrics",
+			middleware.AuthMiddleware(keySet, apiKeyService),
+			middleware.AuthorizeRole("admin"),
+			middleware.RateLimit(rateLimitStore, "admin", cfg.RateLimitAdmin.Policy, cfg.RateLimitAdmin.Identity),
+			gin.WrapH(metrics.Handler()))
+	}
+
 	// --- Route Definitions ---
 
+	// Public keys for verifying tokens issued by this API
+	router.GET("/.well-known/jwks.json", jwksController.GetJWKS)
+
 	// Public routes
-	router.GET("/events", eventController.GetAllEvents)
-	router.GET("/events/search", eventController.SearchEvents)
-	router.GET("/events/category/:category", eventController.GetEventsByCategory)
-	router.GET("/events/:id", eventController.GetEventByID)
-	router.POST("/users/register", userController.RegisterUser)
-	router.POST("/users/login", userController.LoginUser)
+	publicRoutes := router.Group("/")
+	publicRoutes.Use(middleware.RateLimit(rateLimitStore, "public", cfg.RateLimitPublic.Policy, cfg.RateLimitPublic.Identity))
+	{
+		publicRoutes.GET("/events", eventController.GetAllEvents)
+		publicRoutes.GET("/events/search", eventController.SearchEvents)
+		publicRoutes.GET("/events/facets", eventController.GetEventFacets)
+		publicRoutes.GET("/events/category/:category", eventController.GetEventsByCategory)
+		publicRoutes.GET("/events/:id", eventController.GetEventByID)
+		publicRoutes.GET("/events/:id/reviews", reviewController.GetReviewsForEvent)
+		publicRoutes.GET("/events/:id/sessions", sessionController.GetSessions)
+		publicRoutes.GET("/categories", categoryController.GetCategories)
+		publicRoutes.GET("/venues", venueController.GetVenues)
+		publicRoutes.GET("/venues/:id", venueController.GetVenue)
+		publicRoutes.GET("/speakers", speakerController.GetSpeakers)
+		publicRoutes.GET("/speakers/:id", speakerController.GetSpeaker)
+		publicRoutes.GET("/speakers/:id/events", speakerController.GetSpeakerEvents)
+		publicRoutes.POST("/users/register", userController.RegisterUser)
+		publicRoutes.POST("/users/login", userController.LoginUser)
+		publicRoutes.POST("/users/login/2fa", mfaController.VerifyLogin)
+		publicRoutes.POST("/users/login/2fa/enroll", mfaController.BeginLoginEnrollment)
+		publicRoutes.POST("/users/login/2fa/enroll/confirm", mfaController.ConfirmLoginEnrollment)
+		publicRoutes.GET("/auth/oidc/providers", oidcController.GetProviders)
+		publicRoutes.GET("/auth/oidc/:provider/login", oidcController.Login)
+		publicRoutes.GET("/auth/oidc/:provider/callback", oidcController.Callback)
+	}
 
 	protectedRoutes := router.Group("/")
-	protectedRoutes.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	protectedRoutes.Use(middleware.AuthMiddleware(keySet, apiKeyService))
+	protectedRoutes.Use(middleware.RateLimit(rateLimitStore, "protected", cfg.RateLimitProtected.Policy, cfg.RateLimitProtected.Identity))
 	{
 		protectedRoutes.POST("/events", eventController.CreateEvent)
 		protectedRoutes.PATCH("/events/:id", eventController.UpdateEvent)
@@ -78,27 +270,133 @@ func main() {
 
 		protectedRoutes.POST("/events/:id/reviews", reviewController.CreateReview)
 
+		// Agenda routes (Protected)
+		protectedRoutes.POST("/events/:id/sessions", sessionController.CreateSession)
+		protectedRoutes.PATCH("/events/:id/sessions/:sessionId", sessionController.UpdateSession)
+		protectedRoutes.DELETE("/events/:id/sessions/:sessionId", sessionController.DeleteSession)
+		protectedRoutes.POST("/events/:id/sessions/:sessionId/register", sessionController.RegisterForSession)
+		protectedRoutes.DELETE("/events/:id/sessions/:sessionId/register", sessionController.CancelSessionRegistration)
+
+		protectedRoutes.POST("/venues", venueController.CreateVenue)
+		protectedRoutes.PATCH("/venues/:id", venueController.UpdateVenue)
+		protectedRoutes.DELETE("/venues/:id", venueController.DeleteVenue)
+
+		protectedRoutes.POST("/speakers", speakerController.CreateSpeaker)
+		protectedRoutes.PATCH("/speakers/:id", speakerController.UpdateSpeaker)
+		protectedRoutes.DELETE("/speakers/:id", speakerController.DeleteSpeaker)
+
 		// Waitlist routes (Protected)
 		protectedRoutes.POST("/events/:id/waitlist", waitlistController.JoinWaitlist)
 		protectedRoutes.DELETE("/events/:id/waitlist", waitlistController.LeaveWaitlist)
 		protectedRoutes.GET("/events/:id/waitlist", waitlistController.GetWaitlistForEvent)
+
+		// Self-service profile routes (Protected)
+		protectedRoutes.GET("/me", userController.GetProfile)
+		protectedRoutes.GET("/me/sessions", sessionController.GetRegisteredSessions)
+		protectedRoutes.PATCH("/me", userController.UpdateProfile)
+		protectedRoutes.GET("/me/identities", oidcController.GetMyIdentities)
 	}
-	// Public route for getting reviews for an event
-	router.GET("/events/:id/reviews", reviewController.GetReviewsForEvent)
 
+	// Credential management needs an interactive login, not an API key
+	accountRoutes := protectedRoutes.Group("/me")
+	accountRoutes.Use(middleware.RejectAPIKeys())
+	{
+		accountRoutes.DELETE("", userController.DeleteAccount)
+		accountRoutes.PUT("/password", userController.ChangePassword)
+		accountRoutes.POST("/email", userController.RequestEmailChange)
+		accountRoutes.POST("/email/verify", userController.VerifyEmailChange)
+		accountRoutes.GET("/2fa", mfaController.GetStatus)
+		accountRoutes.POST("/2fa/enroll", mfaController.BeginEnrollment)
+		accountRoutes.POST("/2fa/confirm", mfaController.ConfirmEnrollment)
+		accountRoutes.DELETE("/2fa", mfaController.Disable)
+		accountRoutes.POST("/2fa/recovery-codes", mfaController.RegenerateRecoveryCodes)
+		accountRoutes.GET("/api-keys", apiKeyController.GetMyKeys)
+		accountRoutes.POST("/api-keys", apiKeyController.CreateMyKey)
+		accountRoutes.DELETE("/api-keys/:id", apiKeyController.RevokeMyKey)
+	}
 	adminRoutes := router.Group("/admin")
-	adminRoutes.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	adminRoutes.Use(middleware.AuthMiddleware(keySet, apiKeyService))
 	adminRoutes.Use(middleware.AuthorizeRole("admin"))
+	adminRoutes.Use(middleware.RateLimit(rateLimitStore, "admin", cfg.RateLimitAdmin.Policy, cfg.RateLimitAdmin.Identity))
 	{
 		adminRoutes.GET("/users", userController.GetAllUser)
 		adminRoutes.GET("/users/:id", userController.GetUserByID)
 		adminRoutes.PUT("/users/:id", userController.UpdateUser)
 		adminRoutes.DELETE("/users/:id", userController.DeleteUser)
+		adminRoutes.POST("/users/:id/unlock", securityController.UnlockUser)
+		adminRoutes.GET("/audit-events", securityController.GetAuditEvents)
+		adminRoutes.GET("/mfa-policies", mfaController.GetPolicies)
+		adminRoutes.PUT("/mfa-policies", mfaController.SetPolicy)
+		adminRoutes.GET("/api-keys", middleware.RejectAPIKeys(), apiKeyController.GetOrganizationKeys)
+		adminRoutes.POST("/api-keys", middleware.RejectAPIKeys(), apiKeyController.CreateOrganizationKey)
+		adminRoutes.DELETE("/api-keys/:id", middleware.RejectAPIKeys(), apiKeyController.RevokeOrganizationKey)
+		adminRoutes.POST("/categories", categoryController.CreateCategory)
+		adminRoutes.PUT("/categories/:id", categoryController.UpdateCategory)
+		adminRoutes.DELETE("/categories/:id", categoryController.DeleteCategory)
 
 	}
 
-	// Start the server on port 3000
-	err = router.Run(":3000")
-	helper.PanicIfError(err)
+	// Run jobs left over from the previous shutdown
+	if err := jobs.Resume(ctx); err != nil {
+		slog.Error("failed to resume background jobs", "error", err)
+	}
+
+	// Start the server on the configured port
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Port),
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	serverErr := make(chan error, 2)
+	go func() {
+		slog.Info("server listening", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// Metrics on their own listener, to be reachable only from the monitoring network
+	var metricsServer *http.Server
+	if cfg.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:              cfg.MetricsAddr,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			slog.Info("metrics listening", "addr", metricsServer.Addr)
+			serverErr <- metricsServer.ListenAndServe()
+		}()
+	}
+
+	select {
+	case err := <-serverErr:
+		helper.PanicIfError(err)
+	case <-ctx.Done():
+	}
+
+	// Stop routing new traffic here, let in-flight requests complete, then wait for
+	// background jobs. Whatever hasn't finished by the deadline is saved for the next start.
+	slog.Info("shutting down", "delay", cfg.ShutdownDelay, "timeout", cfg.ShutdownTimeout)
+	stop()
+	healthService.StartDraining()
+	// Keep serving until load balancers have seen /readyz fail
+	time.Sleep(cfg.ShutdownDelay)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server did not shut down cleanly", "error", err)
+	}
+	if err := jobs.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("background jobs did not finish", "error", err)
+	}
+	// Stopped last so the shutdown can still be watched
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			slog.Error("metrics server did not shut down cleanly", "error", err)
+		}
+	}
+	slog.Info("server stopped")
 
 }
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"go-rest-api/apperrors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "event_booking"
+
+// Outcomes used as the result label of the domain counters. Rejected means the
+// request was refused for a client reason (full event, duplicate, not found...),
+// failed means something went wrong on our side.
+const (
+	ResultSuccess    = "success"
+	ResultWaitlisted = "waitlisted"
+	ResultRejected   = "rejected"
+	ResultFailed     = "failed"
+)
+
+// Registry holds all metrics exposed at /metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of HTTP requests currently being handled.",
+	})
+
+	Registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Event registration attempts by result (success, waitlisted, rejected, failed).",
+	}, []string{"result"})
+
+	Cancellations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cancellations_total",
+		Help:      "Registration cancellations by result.",
+	}, []string{"result"})
+
+	WaitlistJoins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "waitlist_joins_total",
+		Help:      "Waitlist join attempts by result.",
+	}, []string{"result"})
+
+	WaitlistPromotions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "waitlist_promotions_total",
+		Help:      "Users moved from a waitlist to a registration by result.",
+	}, []string{"result"})
+
+	ReviewsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reviews_created_total",
+		Help:      "Review submissions by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		Registrations,
+		Cancellations,
+		WaitlistJoins,
+		WaitlistPromotions,
+		ReviewsCreated,
+	)
+}
+
+// RegisterDB exports the connection pool statistics of db (open, in use and idle
+// connections, waits and closed connections) under the given database name.
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Result maps the error returned by an operation to a result label.
+func Result(err error) string {
+	if err == nil {
+		return ResultSuccess
+	}
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) && appErr.Status < http.StatusInternalServerError {
+		return ResultRejected
+	}
+	return ResultFailed
+}
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"go-rest-api/services"
 	"go-rest-api/utils"
 	"net/http"
 	"strings"
@@ -9,12 +12,20 @@ import (
 	"github.com/google/uuid"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+var errInvalidToken = apperrors.Unauthorized("invalid_token", "invalid token")
+
+func AuthMiddleware(keys *utils.KeySet, apiKeyService services.APIKeyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// API keys can be sent in the X-API-Key header or as a bearer token
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKeyService, apiKey)
+			return
+		}
+
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
+			abortWithError(c, apperrors.Unauthorized("missing_token", "authorization token is required"))
 			return
 		}
 
@@ -24,24 +35,80 @@ func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			token = strings.TrimPrefix(authHeader, "Bearer ")
 		}
 
+		if strings.HasPrefix(token, services.APIKeyPrefix) {
+			authenticateAPIKey(c, apiKeyService, token)
+			return
+		}
+
 		// Validate the token
-		userIdStr, role, err := utils.ValidateToken(token, jwtSecret)
+		claims, err := utils.ValidateToken(token, keys)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			abortWithError(c, errInvalidToken)
 			return
 		}
 
-		userId, err := uuid.Parse(userIdStr)
+		userId, err := uuid.Parse(claims.UserID)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+			abortWithError(c, errInvalidToken)
 			return
 		}
 
-		// Set the user ID and role in the context for use in handlers
+		// Set the user ID and role in the context for use in handlers, and when and
+		// how the user signed in for changes that need a recent login
 		c.Set("userId", userId)
-		c.Set("userRole", role)
+		c.Set("userRole", claims.Role)
+		c.Set("authTime", claims.IssuedAt)
+		c.Set("authMethods", claims.AuthMethods)
 
 		// Continue to the next handler
 		c.Next()
 	}
 }
+
+func authenticateAPIKey(c *gin.Context, apiKeyService services.APIKeyService, rawKey string) {
+	key, err := apiKeyService.Authenticate(c, rawKey)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	// Read-only keys may only make safe requests
+	requiredScope := model.APIKeyScopeWrite
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		requiredScope = model.APIKeyScopeRead
+	}
+	if !key.HasScope(requiredScope) {
+		abortWithError(c, apperrors.Forbidden("missing_api_key_scope", "API key is missing the "+requiredScope+" scope"))
+		return
+	}
+
+	// A key only gets admin rights if it has the admin scope, even when its user is an admin
+	role := "user"
+	if key.HasScope(model.APIKeyScopeAdmin) && key.UserRole == "admin" {
+		role = "admin"
+	}
+
+	c.Set("userId", key.UserID)
+	c.Set("userRole", role)
+	c.Set("apiKeyId", key.Id)
+	c.Set("apiKeyRateLimit", key.RateLimitPerMinute)
+	c.Next()
+}
+
+// RejectAPIKeys limits a route to users who signed in interactively, for
+// example to stop a leaked key from changing the password or creating more keys.
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("apiKeyId"); exists {
+			abortWithError(c, apperrors.Forbidden("api_key_not_allowed", "this endpoint cannot be used with an API key"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// abortWithError stops the handler chain and leaves err for ErrorHandler to render.
+func abortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"go-rest-api/apperrors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler renders the last error added with c.Error as an RFC 7807
+// problem+json response. Errors that are not *apperrors.Error are logged and
+// returned as a generic 500 so internal details don't leak to clients.
+// It must be registered before Recovery and the handlers, so it renders their
+// errors and panics, and after middleware such as the request logger and metrics
+// that need to see the final status.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		appErr := apperrors.From(c.Errors.Last().Err)
+		if appErr.Status >= http.StatusInternalServerError {
+			slog.ErrorContext(c.Request.Context(), "request failed",
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"error", c.Errors.Last().Err,
+			)
+		}
+		renderProblem(c, appErr)
+	}
+}
+
+func renderProblem(c *gin.Context, appErr *apperrors.Error) {
+	problem := gin.H{
+		"type":     "about:blank",
+		"title":    http.StatusText(appErr.Status),
+		"status":   appErr.Status,
+		"detail":   appErr.Message,
+		"instance": c.Request.URL.Path,
+		"code":     appErr.Code,
+	}
+	for k, v := range appErr.Details {
+		if _, reserved := problem[k]; !reserved {
+			problem[k] = v
+		}
+	}
+
+	if retryAfter, ok := appErr.Details["retry_after"].(int); ok {
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+	}
+	c.Header("Content-Type", "application/problem+json")
+	c.JSON(appErr.Status, problem)
+}
+
+// NotFoundHandler reports unknown routes as a problem.
+func NotFoundHandler(c *gin.Context) {
+	c.Error(apperrors.NotFound("route_not_found", "no route matches "+c.Request.Method+" "+c.Request.URL.Path))
+}
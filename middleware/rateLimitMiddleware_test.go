@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"encoding/json"
+	"go-rest-api/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newRateLimitedRouter serves GET /ping limited by policy. Requests are signed in as
+// the user and API key given in the X-User and X-Key headers, if any.
+func newRateLimitedRouter(store ratelimit.Store, policy ratelimit.Policy, identity ratelimit.Identity) *gin.Engine {
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.Use(func(c *gin.Context) {
+		if userID, err := uuid.Parse(c.GetHeader("X-User")); err == nil {
+			c.Set("userId", userID)
+		}
+		if keyID, err := uuid.Parse(c.GetHeader("X-Key")); err == nil {
+			c.Set("apiKeyId", keyID)
+			c.Set("apiKeyRateLimit", 1)
+		}
+	})
+	router.Use(RateLimit(store, "test", policy, identity))
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return router
+}
+
+func get(router *gin.Engine, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	for name, store := range map[string]ratelimit.Store{
+		"memory": ratelimit.NewMemoryStore(),
+		"redis":  ratelimit.NewRedisStore(client, "test:"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			router := newRateLimitedRouter(store, ratelimit.Policy{Limit: 2, Period: time.Minute}, ratelimit.IdentityIP)
+
+			w := get(router, nil)
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+			assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+			assert.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+			assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+			assert.Empty(t, w.Header().Get("Retry-After"))
+
+			w = get(router, nil)
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+			w = get(router, nil)
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+			assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+			assert.Equal(t, "30", w.Header().Get("Retry-After"))
+			var problem map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.EqualValues(t, 30, problem["retry_after"])
+		})
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	router := newRateLimitedRouter(ratelimit.NewMemoryStore(), ratelimit.Policy{}, ratelimit.IdentityIP)
+	for i := 0; i < 10; i++ {
+		w := get(router, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitCountsAPIKeysWithAnyIdentity(t *testing.T) {
+	for _, identity := range []ratelimit.Identity{ratelimit.IdentityIP, ratelimit.IdentityUser, ratelimit.IdentityAPIKey} {
+		t.Run(string(identity), func(t *testing.T) {
+			router := newRateLimitedRouter(ratelimit.NewMemoryStore(), ratelimit.Policy{Limit: 100, Period: time.Minute}, identity)
+			user, key := uuid.NewString(), uuid.NewString()
+
+			// The key's own limit of 1 per minute applies instead of the group's 100
+			w := get(router, map[string]string{"X-User": user, "X-Key": key})
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+			w = get(router, map[string]string{"X-User": user, "X-Key": key})
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+			// The owner without the key isn't affected
+			w = get(router, map[string]string{"X-User": user})
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, "100", w.Header().Get("RateLimit-Limit"))
+		})
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	userID := uuid.New()
+	keyID := uuid.New()
+	groupPolicy := ratelimit.Policy{Limit: 100, Period: time.Minute}
+
+	newContext := func(user, key bool, keyLimit int) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.RemoteAddr = "192.0.2.1:1234"
+		if user {
+			c.Set("userId", userID)
+		}
+		if key {
+			c.Set("apiKeyId", keyID)
+			c.Set("apiKeyRateLimit", keyLimit)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name       string
+		c          *gin.Context
+		identity   ratelimit.Identity
+		wantKey    string
+		wantPolicy ratelimit.Policy
+	}{
+		{"anonymous by ip", newContext(false, false, 0), ratelimit.IdentityIP, "g:ip:192.0.2.1", groupPolicy},
+		{"anonymous by user", newContext(false, false, 0), ratelimit.IdentityUser, "g:ip:192.0.2.1", groupPolicy},
+		{"user by ip", newContext(true, false, 0), ratelimit.IdentityIP, "g:ip:192.0.2.1", groupPolicy},
+		{"user by user", newContext(true, false, 0), ratelimit.IdentityUser, "g:user:" + userID.String(), groupPolicy},
+		{"user by api_key", newContext(true, false, 0), ratelimit.IdentityAPIKey, "g:user:" + userID.String(), groupPolicy},
+		{"key with limit by ip", newContext(true, true, 10), ratelimit.IdentityIP, "apikey:" + keyID.String(), ratelimit.Policy{Limit: 10, Period: time.Minute}},
+		{"key with limit by user", newContext(true, true, 10), ratelimit.IdentityUser, "apikey:" + keyID.String(), ratelimit.Policy{Limit: 10, Period: time.Minute}},
+		{"key with limit by api_key", newContext(true, true, 10), ratelimit.IdentityAPIKey, "apikey:" + keyID.String(), ratelimit.Policy{Limit: 10, Period: time.Minute}},
+		{"key without limit", newContext(true, true, 0), ratelimit.IdentityUser, "g:apikey:" + keyID.String(), groupPolicy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, policy := rateLimitKey(tt.c, "g", groupPolicy, tt.identity)
+			assert.Equal(t, tt.wantKey, key)
+			assert.Equal(t, tt.wantPolicy, policy)
+		})
+	}
+}
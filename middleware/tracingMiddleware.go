@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Tracing starts a server span for each request, named after its route
+// template. Incoming W3C traceparent headers are honoured so the span joins the
+// caller's trace. Metrics scrapes and health probes are not traced.
+func Tracing(service string) gin.HandlerFunc {
+	return otelgin.Middleware(service, otelgin.WithFilter(func(r *http.Request) bool {
+		return !untracedPaths[r.URL.Path]
+	}))
+}
+
+var untracedPaths = map[string]bool{
+	"/metrics":     true,
+	"/livez":       true,
+	"/readyz":      true,
+	"/healthcheck": true,
+}
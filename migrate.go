@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go-rest-api/config"
+	"go-rest-api/connection"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+const migrateUsage = `Usage: %[1]s [--config <file>] migrate <command>
+
+Changes the database schema using the migrations built into this binary.
+
+Commands:
+  up               apply all pending migrations
+  down <n>         roll back the last n migrations
+  goto <version>   migrate up or down to version
+  version          print the current and the newest schema version
+  force <version>  set the version and clear the dirty flag without running
+                   any migration, after the database was repaired by hand.
+                   Asks for confirmation unless -yes is given; -1 means no
+                   migration applied.
+
+Flags:
+`
+
+// runMigrateCommand runs `migrate <command>` and returns the exit code.
+func runMigrateCommand(configFile string, args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "don't ask for confirmation")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), migrateUsage, filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	commandArgs, err := parseInterspersed(flags, args)
+	if err != nil {
+		return 2
+	}
+	if len(commandArgs) == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	databaseURL, err := config.LoadDatabaseURL(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	m, err := connection.NewMigrator(databaseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer m.Close()
+
+	// Stop after the running migration on Ctrl-C rather than leaving it half applied.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		m.GracefulStop <- true
+	}()
+
+	if err := migrateCommand(m, commandArgs, *yes, os.Stdin, os.Stdout); err != nil {
+		if errors.Is(err, errUsage) {
+			flags.Usage()
+			return 2
+		}
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		return 1
+	}
+	return 0
+}
+
+var errUsage = errors.New("invalid usage")
+
+func migrateCommand(m *migrate.Migrate, args []string, yes bool, in io.Reader, out io.Writer) error {
+	command, arg := args[0], ""
+	switch {
+	case len(args) == 2:
+		arg = args[1]
+	case len(args) > 2:
+		return errUsage
+	}
+
+	var err error
+	switch command {
+	case "up":
+		if arg != "" {
+			return errUsage
+		}
+		err = m.Up()
+	case "down":
+		n, convErr := strconv.Atoi(arg)
+		if convErr != nil || n < 1 {
+			return errUsage
+		}
+		err = m.Steps(-n)
+	case "goto":
+		version, convErr := strconv.ParseUint(arg, 10, 0)
+		if convErr != nil {
+			return errUsage
+		}
+		err = m.Migrate(uint(version))
+	case "force":
+		version, convErr := strconv.Atoi(arg)
+		if convErr != nil || version < -1 {
+			return errUsage
+		}
+		if !yes && !confirmForce(in, out, version) {
+			return errors.New("aborted")
+		}
+		err = m.Force(version)
+	case "version":
+		if arg != "" {
+			return errUsage
+		}
+	default:
+		return errUsage
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Fprintln(out, "no change")
+		err = nil
+	}
+	var dirtyErr migrate.ErrDirty
+	if errors.As(err, &dirtyErr) {
+		return connection.SchemaStatus{Version: uint(dirtyErr.Version), Dirty: true}.Check()
+	}
+	if err != nil {
+		return err
+	}
+	return printSchemaStatus(m, out)
+}
+
+func printSchemaStatus(m *migrate.Migrate, out io.Writer) error {
+	status, err := connection.ReadSchemaStatus(m)
+	if err != nil {
+		return err
+	}
+	version := "none"
+	if status.Version > 0 {
+		version = strconv.FormatUint(uint64(status.Version), 10)
+	}
+	fmt.Fprintf(out, "version: %s\ndirty: %t\nlatest: %d\n", version, status.Dirty, status.Latest)
+	return nil
+}
+
+// confirmForce asks the user to type the version back, since forcing the wrong
+// version makes later migrations run against a schema they weren't written for.
+func confirmForce(in io.Reader, out io.Writer, version int) bool {
+	fmt.Fprintf(out, "This marks the schema as version %d and clears the dirty flag without running any migration.\n", version)
+	fmt.Fprintf(out, "Only do this after repairing the database so it matches version %d.\n", version)
+	fmt.Fprintf(out, "Type %d to confirm: ", version)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "no confirmation read; pass -yes to force non-interactively")
+		return false
+	}
+	return strings.TrimSpace(answer) == strconv.Itoa(version)
+}
+
+// parseInterspersed parses flags appearing anywhere among the arguments, so both
+// `migrate -yes force 3` and `migrate force 3 -yes` work, and returns the rest.
+// Negative numbers such as the version -1 are arguments. All flags are booleans,
+// so each can be parsed on its own.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for _, arg := range args {
+		if _, err := strconv.Atoi(arg); err == nil || !strings.HasPrefix(arg, "-") {
+			rest = append(rest, arg)
+			continue
+		}
+		if err := flags.Parse([]string{arg}); err != nil {
+			return nil, err
+		}
+	}
+	return rest, nil
+}
+
+// prepareSchema checks that the schema is at the newest embedded migration and
+// returns that version. With migrateOnStart it first applies pending migrations,
+// but it never forces a dirty version.
+func prepareSchema(databaseURL string, migrateOnStart bool) (uint, error) {
+	m, err := connection.NewMigrator(databaseURL)
+	if err != nil {
+		return 0, err
+	}
+	defer m.Close()
+
+	if migrateOnStart {
+		err := m.Up()
+		switch {
+		case errors.Is(err, migrate.ErrNoChange):
+		case err != nil:
+			return 0, fmt.Errorf("failed to run database migrations: %w", err)
+		default:
+			slog.Info("database migrations ran successfully")
+		}
+	}
+
+	status, err := connection.ReadSchemaStatus(m)
+	if err != nil {
+		return 0, err
+	}
+	if err := status.Check(); err != nil {
+		return 0, err
+	}
+	slog.Info("database schema is up to date", "version", status.Version)
+	return status.Latest, nil
+}
@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL migrations so the binary can run and check
+// them without the source tree.
+package migrations
+
+import "embed"
+
+// Files holds the migration files under migrations/, named
+// <version>_<title>.up.sql and <version>_<title>.down.sql.
+//
+//go:embed migrations/*.sql
+var Files embed.FS
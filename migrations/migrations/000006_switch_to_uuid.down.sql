@@ -1,6 +1,10 @@
--- This migration is destructive and cannot be perfectly reversed.
--- This 'down' migration will attempt to restore the schema to its previous state,
--- but all data will be lost.
+-- migrations/000006_switch_to_uuid.down.sql
+
+-- Switches ids back to SERIAL integers while keeping all rows. Users and events get
+-- their original integer ids back from the mapping tables written by the up migration;
+-- rows created since then, or every row if the database was upgraded by the earlier
+-- destructive version of this migration, get new ids after the highest mapped one.
+-- Registrations, reviews and waitlist entries are renumbered, nothing references them.
 
 -- Drop the new foreign key constraints
 ALTER TABLE events DROP CONSTRAINT IF EXISTS fk_events_users;
@@ -11,40 +15,99 @@ ALTER TABLE reviews DROP CONSTRAINT IF EXISTS fk_reviews_users;
 ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS fk_waitlist_entries_events;
 ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS fk_waitlist_entries_users;
 
--- Alter tables back to using SERIAL integer primary keys
--- Alter 'users' table
-ALTER TABLE users DROP COLUMN IF EXISTS id;
-ALTER TABLE users ADD COLUMN id SERIAL PRIMARY KEY;
-
--- Alter 'events' table
-ALTER TABLE events DROP COLUMN IF EXISTS id;
-ALTER TABLE events ADD COLUMN id SERIAL PRIMARY KEY;
-ALTER TABLE events DROP COLUMN IF EXISTS user_id;
-ALTER TABLE events ADD COLUMN user_id INTEGER;
-
--- Alter 'registrations' table
-ALTER TABLE registrations DROP COLUMN IF EXISTS id;
-ALTER TABLE registrations ADD COLUMN id SERIAL PRIMARY KEY;
-ALTER TABLE registrations DROP COLUMN IF EXISTS event_id;
-ALTER TABLE registrations ADD COLUMN event_id INTEGER;
-ALTER TABLE registrations DROP COLUMN IF EXISTS user_id;
-ALTER TABLE registrations ADD COLUMN user_id INTEGER;
-
--- Alter 'reviews' table
-ALTER TABLE reviews DROP COLUMN IF EXISTS id;
-ALTER TABLE reviews ADD COLUMN id SERIAL PRIMARY KEY;
-ALTER TABLE reviews DROP COLUMN IF EXISTS event_id;
-ALTER TABLE reviews ADD COLUMN event_id INTEGER;
-ALTER TABLE reviews DROP COLUMN IF EXISTS user_id;
-ALTER TABLE reviews ADD COLUMN user_id INTEGER;
-
--- Alter 'waitlist_entries' table
-ALTER TABLE waitlist_entries DROP COLUMN IF EXISTS id;
-ALTER TABLE waitlist_entries ADD COLUMN id SERIAL PRIMARY KEY;
-ALTER TABLE waitlist_entries DROP COLUMN IF EXISTS event_id;
-ALTER TABLE waitlist_entries ADD COLUMN event_id INTEGER;
-ALTER TABLE waitlist_entries DROP COLUMN IF EXISTS user_id;
-ALTER TABLE waitlist_entries ADD COLUMN user_id INTEGER;
+-- Complete the mapping with the rows that have no integer id yet
+CREATE TABLE IF NOT EXISTS uuid_migration_user_ids (
+    old_id INTEGER PRIMARY KEY,
+    new_id UUID NOT NULL UNIQUE DEFAULT gen_random_uuid()
+);
+CREATE TABLE IF NOT EXISTS uuid_migration_event_ids (
+    old_id INTEGER PRIMARY KEY,
+    new_id UUID NOT NULL UNIQUE DEFAULT gen_random_uuid()
+);
+DELETE FROM uuid_migration_user_ids m WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = m.new_id);
+DELETE FROM uuid_migration_event_ids m WHERE NOT EXISTS (SELECT 1 FROM events e WHERE e.id = m.new_id);
+INSERT INTO uuid_migration_user_ids (old_id, new_id)
+SELECT (SELECT COALESCE(max(old_id), 0) FROM uuid_migration_user_ids) + row_number() OVER (ORDER BY u.id), u.id
+FROM users u WHERE NOT EXISTS (SELECT 1 FROM uuid_migration_user_ids m WHERE m.new_id = u.id);
+INSERT INTO uuid_migration_event_ids (old_id, new_id)
+SELECT (SELECT COALESCE(max(old_id), 0) FROM uuid_migration_event_ids) + row_number() OVER (ORDER BY e.id), e.id
+FROM events e WHERE NOT EXISTS (SELECT 1 FROM uuid_migration_event_ids m WHERE m.new_id = e.id);
+
+-- Add the integer columns and fill them from the mapping
+ALTER TABLE users ADD COLUMN old_id INTEGER;
+UPDATE users u SET old_id = m.old_id FROM uuid_migration_user_ids m WHERE m.new_id = u.id;
+
+ALTER TABLE events ADD COLUMN old_id INTEGER;
+ALTER TABLE events ADD COLUMN old_user_id INTEGER;
+UPDATE events e SET old_id = m.old_id FROM uuid_migration_event_ids m WHERE m.new_id = e.id;
+UPDATE events e SET old_user_id = m.old_id FROM uuid_migration_user_ids m WHERE m.new_id = e.user_id;
+
+ALTER TABLE registrations ADD COLUMN old_id SERIAL;
+ALTER TABLE registrations ADD COLUMN old_event_id INTEGER;
+ALTER TABLE registrations ADD COLUMN old_user_id INTEGER;
+UPDATE registrations r SET old_event_id = m.old_id FROM uuid_migration_event_ids m WHERE m.new_id = r.event_id;
+UPDATE registrations r SET old_user_id = m.old_id FROM uuid_migration_user_ids m WHERE m.new_id = r.user_id;
+
+ALTER TABLE reviews ADD COLUMN old_id SERIAL;
+ALTER TABLE reviews ADD COLUMN old_event_id INTEGER;
+ALTER TABLE reviews ADD COLUMN old_user_id INTEGER;
+UPDATE reviews r SET old_event_id = m.old_id FROM uuid_migration_event_ids m WHERE m.new_id = r.event_id;
+UPDATE reviews r SET old_user_id = m.old_id FROM uuid_migration_user_ids m WHERE m.new_id = r.user_id;
+
+ALTER TABLE waitlist_entries ADD COLUMN old_id SERIAL;
+ALTER TABLE waitlist_entries ADD COLUMN old_event_id INTEGER;
+ALTER TABLE waitlist_entries ADD COLUMN old_user_id INTEGER;
+UPDATE waitlist_entries w SET old_event_id = m.old_id FROM uuid_migration_event_ids m WHERE m.new_id = w.event_id;
+UPDATE waitlist_entries w SET old_user_id = m.old_id FROM uuid_migration_user_ids m WHERE m.new_id = w.user_id;
+
+-- Replace the UUID columns with the integer ones
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_pkey CASCADE;
+ALTER TABLE users DROP COLUMN id;
+ALTER TABLE users RENAME COLUMN old_id TO id;
+CREATE SEQUENCE users_id_seq OWNED BY users.id;
+SELECT setval('users_id_seq', COALESCE((SELECT max(id) FROM users), 0) + 1, false);
+ALTER TABLE users ALTER COLUMN id SET DEFAULT nextval('users_id_seq');
+ALTER TABLE users ADD PRIMARY KEY (id);
+
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_pkey CASCADE;
+ALTER TABLE events DROP COLUMN id;
+ALTER TABLE events DROP COLUMN user_id;
+ALTER TABLE events RENAME COLUMN old_id TO id;
+ALTER TABLE events RENAME COLUMN old_user_id TO user_id;
+CREATE SEQUENCE events_id_seq OWNED BY events.id;
+SELECT setval('events_id_seq', COALESCE((SELECT max(id) FROM events), 0) + 1, false);
+ALTER TABLE events ALTER COLUMN id SET DEFAULT nextval('events_id_seq');
+ALTER TABLE events ADD PRIMARY KEY (id);
+
+ALTER TABLE registrations DROP CONSTRAINT IF EXISTS registrations_pkey CASCADE;
+ALTER TABLE registrations DROP COLUMN id;
+ALTER TABLE registrations DROP COLUMN event_id;
+ALTER TABLE registrations DROP COLUMN user_id;
+ALTER TABLE registrations RENAME COLUMN old_id TO id;
+ALTER TABLE registrations RENAME COLUMN old_event_id TO event_id;
+ALTER TABLE registrations RENAME COLUMN old_user_id TO user_id;
+ALTER SEQUENCE registrations_old_id_seq RENAME TO registrations_id_seq;
+ALTER TABLE registrations ADD PRIMARY KEY (id);
+
+ALTER TABLE reviews DROP CONSTRAINT IF EXISTS reviews_pkey CASCADE;
+ALTER TABLE reviews DROP COLUMN id;
+ALTER TABLE reviews DROP COLUMN event_id;
+ALTER TABLE reviews DROP COLUMN user_id;
+ALTER TABLE reviews RENAME COLUMN old_id TO id;
+ALTER TABLE reviews RENAME COLUMN old_event_id TO event_id;
+ALTER TABLE reviews RENAME COLUMN old_user_id TO user_id;
+ALTER SEQUENCE reviews_old_id_seq RENAME TO reviews_id_seq;
+ALTER TABLE reviews ADD PRIMARY KEY (id);
+
+ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS waitlist_entries_pkey CASCADE;
+ALTER TABLE waitlist_entries DROP COLUMN id;
+ALTER TABLE waitlist_entries DROP COLUMN event_id;
+ALTER TABLE waitlist_entries DROP COLUMN user_id;
+ALTER TABLE waitlist_entries RENAME COLUMN old_id TO id;
+ALTER TABLE waitlist_entries RENAME COLUMN old_event_id TO event_id;
+ALTER TABLE waitlist_entries RENAME COLUMN old_user_id TO user_id;
+ALTER SEQUENCE waitlist_entries_old_id_seq RENAME TO waitlist_entries_id_seq;
+ALTER TABLE waitlist_entries ADD PRIMARY KEY (id);
 
 -- Re-create the original foreign key constraints
 ALTER TABLE events ADD CONSTRAINT events_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -53,4 +116,17 @@ ALTER TABLE registrations ADD CONSTRAINT registrations_user_id_fkey FOREIGN KEY
 ALTER TABLE reviews ADD CONSTRAINT reviews_event_id_fkey FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE;
 ALTER TABLE reviews ADD CONSTRAINT reviews_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
 ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_event_id_fkey FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE;
-ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
\ No newline at end of file
+ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- Restore the NOT NULL and unique constraints the integer schema had. This fails, and
+-- changes nothing, if rows without an event or user or duplicates were added since.
+ALTER TABLE registrations ALTER COLUMN event_id SET NOT NULL, ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE registrations ADD CONSTRAINT registrations_event_id_user_id_key UNIQUE (event_id, user_id);
+ALTER TABLE reviews ALTER COLUMN event_id SET NOT NULL, ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE reviews ADD CONSTRAINT reviews_event_id_user_id_key UNIQUE (event_id, user_id);
+ALTER TABLE waitlist_entries ALTER COLUMN event_id SET NOT NULL, ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE waitlist_entries ADD CONSTRAINT waitlist_entries_event_id_user_id_key UNIQUE (event_id, user_id);
+
+DROP TABLE IF EXISTS uuid_migration_user_ids;
+DROP TABLE IF EXISTS uuid_migration_event_ids;
+DROP TABLE IF EXISTS uuid_migration_report;
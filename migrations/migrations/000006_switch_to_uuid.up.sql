@@ -1,6 +1,47 @@
+-- migrations/000006_switch_to_uuid.up.sql
+
+-- Switches every id from SERIAL integers to UUIDs while keeping all rows. Each old
+-- integer id is mapped to a new UUID and foreign keys are rewritten through that
+-- mapping. The row counts before and after are recorded in uuid_migration_report,
+-- and the migration fails if any row or reference would be lost.
+--
+-- The file is sent as a single query string, which PostgreSQL runs in one
+-- transaction: if any statement fails nothing is changed, and after fixing the cause
+-- `migrate force 5` followed by `migrate up` retries it.
+
 -- Enable the pgcrypto extension to generate UUIDs
 CREATE EXTENSION IF NOT EXISTS "pgcrypto";
 
+-- Verification report: rows per table before and after the switch, and the number of
+-- foreign keys that could not be mapped (must be 0).
+CREATE TABLE IF NOT EXISTS uuid_migration_report (
+    table_name TEXT PRIMARY KEY,
+    rows_before BIGINT NOT NULL,
+    rows_after BIGINT,
+    unmapped_references BIGINT NOT NULL DEFAULT 0,
+    migrated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+DELETE FROM uuid_migration_report;
+INSERT INTO uuid_migration_report (table_name, rows_before)
+SELECT 'users', count(*) FROM users
+UNION ALL SELECT 'events', count(*) FROM events
+UNION ALL SELECT 'registrations', count(*) FROM registrations
+UNION ALL SELECT 'reviews', count(*) FROM reviews
+UNION ALL SELECT 'waitlist_entries', count(*) FROM waitlist_entries;
+
+-- Old integer id to new UUID, kept so ids stored outside the database (links, exports)
+-- can still be translated after the upgrade.
+CREATE TABLE IF NOT EXISTS uuid_migration_user_ids (
+    old_id INTEGER PRIMARY KEY,
+    new_id UUID NOT NULL UNIQUE DEFAULT gen_random_uuid()
+);
+CREATE TABLE IF NOT EXISTS uuid_migration_event_ids (
+    old_id INTEGER PRIMARY KEY,
+    new_id UUID NOT NULL UNIQUE DEFAULT gen_random_uuid()
+);
+INSERT INTO uuid_migration_user_ids (old_id) SELECT id FROM users ON CONFLICT (old_id) DO NOTHING;
+INSERT INTO uuid_migration_event_ids (old_id) SELECT id FROM events ON CONFLICT (old_id) DO NOTHING;
+
 -- Drop existing foreign key constraints
 ALTER TABLE events DROP CONSTRAINT IF EXISTS events_user_id_fkey;
 ALTER TABLE registrations DROP CONSTRAINT IF EXISTS registrations_event_id_fkey;
@@ -10,47 +51,84 @@ ALTER TABLE reviews DROP CONSTRAINT IF EXISTS reviews_user_id_fkey;
 ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS waitlist_entries_event_id_fkey;
 ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS waitlist_entries_user_id_fkey;
 
--- Alter tables to use UUIDs
--- Note: This migration is destructive and will result in data loss.
+-- Add the UUID columns next to the integer ones and fill them from the mapping.
+-- Rows that nothing references get a fresh UUID from the column default.
+ALTER TABLE users ADD COLUMN new_id UUID;
+UPDATE users u SET new_id = m.new_id FROM uuid_migration_user_ids m WHERE m.old_id = u.id;
+
+ALTER TABLE events ADD COLUMN new_id UUID;
+ALTER TABLE events ADD COLUMN new_user_id UUID;
+UPDATE events e SET new_id = m.new_id FROM uuid_migration_event_ids m WHERE m.old_id = e.id;
+UPDATE events e SET new_user_id = m.new_id FROM uuid_migration_user_ids m WHERE m.old_id = e.user_id;
+
+ALTER TABLE registrations ADD COLUMN new_id UUID DEFAULT gen_random_uuid();
+ALTER TABLE registrations ADD COLUMN new_event_id UUID;
+ALTER TABLE registrations ADD COLUMN new_user_id UUID;
+UPDATE registrations r SET new_event_id = m.new_id FROM uuid_migration_event_ids m WHERE m.old_id = r.event_id;
+UPDATE registrations r SET new_user_id = m.new_id FROM uuid_migration_user_ids m WHERE m.old_id = r.user_id;
+
+ALTER TABLE reviews ADD COLUMN new_id UUID DEFAULT gen_random_uuid();
+ALTER TABLE reviews ADD COLUMN new_event_id UUID;
+ALTER TABLE reviews ADD COLUMN new_user_id UUID;
+UPDATE reviews r SET new_event_id = m.new_id FROM uuid_migration_event_ids m WHERE m.old_id = r.event_id;
+UPDATE reviews r SET new_user_id = m.new_id FROM uuid_migration_user_ids m WHERE m.old_id = r.user_id;
 
--- Alter 'users' table
+ALTER TABLE waitlist_entries ADD COLUMN new_id UUID DEFAULT gen_random_uuid();
+ALTER TABLE waitlist_entries ADD COLUMN new_event_id UUID;
+ALTER TABLE waitlist_entries ADD COLUMN new_user_id UUID;
+UPDATE waitlist_entries w SET new_event_id = m.new_id FROM uuid_migration_event_ids m WHERE m.old_id = w.event_id;
+UPDATE waitlist_entries w SET new_user_id = m.new_id FROM uuid_migration_user_ids m WHERE m.old_id = w.user_id;
+
+-- References whose integer value had no matching row, which would become NULL.
+UPDATE uuid_migration_report SET unmapped_references = CASE table_name
+    WHEN 'events' THEN (SELECT count(*) FROM events WHERE user_id IS NOT NULL AND new_user_id IS NULL)
+    WHEN 'registrations' THEN (SELECT count(*) FROM registrations WHERE new_event_id IS NULL OR new_user_id IS NULL)
+    WHEN 'reviews' THEN (SELECT count(*) FROM reviews WHERE new_event_id IS NULL OR new_user_id IS NULL)
+    WHEN 'waitlist_entries' THEN (SELECT count(*) FROM waitlist_entries WHERE new_event_id IS NULL OR new_user_id IS NULL)
+    ELSE 0
+END;
+
+-- Replace the integer columns with the UUID ones
 ALTER TABLE users DROP CONSTRAINT IF EXISTS users_pkey CASCADE;
-ALTER TABLE users DROP COLUMN IF EXISTS id;
-ALTER TABLE users ADD COLUMN id UUID PRIMARY KEY DEFAULT gen_random_uuid();
+ALTER TABLE users DROP COLUMN id;
+ALTER TABLE users RENAME COLUMN new_id TO id;
+ALTER TABLE users ALTER COLUMN id SET DEFAULT gen_random_uuid();
+ALTER TABLE users ADD PRIMARY KEY (id);
 
--- Alter 'events' table
 ALTER TABLE events DROP CONSTRAINT IF EXISTS events_pkey CASCADE;
-ALTER TABLE events DROP COLUMN IF EXISTS id;
-ALTER TABLE events ADD COLUMN id UUID PRIMARY KEY DEFAULT gen_random_uuid();
-ALTER TABLE events DROP COLUMN IF EXISTS user_id;
-ALTER TABLE events ADD COLUMN user_id UUID;
+ALTER TABLE events DROP COLUMN id;
+ALTER TABLE events DROP COLUMN user_id;
+ALTER TABLE events RENAME COLUMN new_id TO id;
+ALTER TABLE events RENAME COLUMN new_user_id TO user_id;
+ALTER TABLE events ALTER COLUMN id SET DEFAULT gen_random_uuid();
+ALTER TABLE events ADD PRIMARY KEY (id);
 
--- Alter 'registrations' table
 ALTER TABLE registrations DROP CONSTRAINT IF EXISTS registrations_pkey CASCADE;
-ALTER TABLE registrations DROP COLUMN IF EXISTS id;
-ALTER TABLE registrations ADD COLUMN id UUID PRIMARY KEY DEFAULT gen_random_uuid();
-ALTER TABLE registrations DROP COLUMN IF EXISTS event_id;
-ALTER TABLE registrations ADD COLUMN event_id UUID;
-ALTER TABLE registrations DROP COLUMN IF EXISTS user_id;
-ALTER TABLE registrations ADD COLUMN user_id UUID;
-
--- Alter 'reviews' table
+ALTER TABLE registrations DROP COLUMN id;
+ALTER TABLE registrations DROP COLUMN event_id;
+ALTER TABLE registrations DROP COLUMN user_id;
+ALTER TABLE registrations RENAME COLUMN new_id TO id;
+ALTER TABLE registrations RENAME COLUMN new_event_id TO event_id;
+ALTER TABLE registrations RENAME COLUMN new_user_id TO user_id;
+ALTER TABLE registrations ADD PRIMARY KEY (id);
+
 ALTER TABLE reviews DROP CONSTRAINT IF EXISTS reviews_pkey CASCADE;
-ALTER TABLE reviews DROP COLUMN IF EXISTS id;
-ALTER TABLE reviews ADD COLUMN id UUID PRIMARY KEY DEFAULT gen_random_uuid();
-ALTER TABLE reviews DROP COLUMN IF EXISTS event_id;
-ALTER TABLE reviews ADD COLUMN event_id UUID;
-ALTER TABLE reviews DROP COLUMN IF EXISTS user_id;
-ALTER TABLE reviews ADD COLUMN user_id UUID;
-
--- Alter 'waitlist_entries' table
+ALTER TABLE reviews DROP COLUMN id;
+ALTER TABLE reviews DROP COLUMN event_id;
+ALTER TABLE reviews DROP COLUMN user_id;
+ALTER TABLE reviews RENAME COLUMN new_id TO id;
+ALTER TABLE reviews RENAME COLUMN new_event_id TO event_id;
+ALTER TABLE reviews RENAME COLUMN new_user_id TO user_id;
+ALTER TABLE reviews ADD PRIMARY KEY (id);
+
 ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS waitlist_entries_pkey CASCADE;
-ALTER TABLE waitlist_entries DROP COLUMN IF EXISTS id;
-ALTER TABLE waitlist_entries ADD COLUMN id UUID PRIMARY KEY DEFAULT gen_random_uuid();
-ALTER TABLE waitlist_entries DROP COLUMN IF EXISTS event_id;
-ALTER TABLE waitlist_entries ADD COLUMN event_id UUID;
-ALTER TABLE waitlist_entries DROP COLUMN IF EXISTS user_id;
-ALTER TABLE waitlist_entries ADD COLUMN user_id UUID;
+ALTER TABLE waitlist_entries DROP COLUMN id;
+ALTER TABLE waitlist_entries DROP COLUMN event_id;
+ALTER TABLE waitlist_entries DROP COLUMN user_id;
+ALTER TABLE waitlist_entries RENAME COLUMN new_id TO id;
+ALTER TABLE waitlist_entries RENAME COLUMN new_event_id TO event_id;
+ALTER TABLE waitlist_entries RENAME COLUMN new_user_id TO user_id;
+ALTER TABLE waitlist_entries ADD PRIMARY KEY (id);
 
 -- Re-create foreign key constraints
 ALTER TABLE events ADD CONSTRAINT fk_events_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -59,4 +137,28 @@ ALTER TABLE registrations ADD CONSTRAINT fk_registrations_users FOREIGN KEY (use
 ALTER TABLE reviews ADD CONSTRAINT fk_reviews_events FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE;
 ALTER TABLE reviews ADD CONSTRAINT fk_reviews_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
 ALTER TABLE waitlist_entries ADD CONSTRAINT fk_waitlist_entries_events FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE;
-ALTER TABLE waitlist_entries ADD CONSTRAINT fk_waitlist_entries_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
\ No newline at end of file
+ALTER TABLE waitlist_entries ADD CONSTRAINT fk_waitlist_entries_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- Verify: every table kept its rows and every reference was mapped. Raising an
+-- exception rolls back the whole migration and leaves the integer schema untouched.
+UPDATE uuid_migration_report SET rows_after = CASE table_name
+    WHEN 'users' THEN (SELECT count(*) FROM users)
+    WHEN 'events' THEN (SELECT count(*) FROM events)
+    WHEN 'registrations' THEN (SELECT count(*) FROM registrations)
+    WHEN 'reviews' THEN (SELECT count(*) FROM reviews)
+    WHEN 'waitlist_entries' THEN (SELECT count(*) FROM waitlist_entries)
+END;
+
+DO $$
+DECLARE
+    r RECORD;
+BEGIN
+    FOR r IN SELECT * FROM uuid_migration_report ORDER BY table_name LOOP
+        RAISE NOTICE 'uuid migration: % rows before %, after %, unmapped references %',
+            r.table_name, r.rows_before, r.rows_after, r.unmapped_references;
+        IF r.rows_after IS DISTINCT FROM r.rows_before OR r.unmapped_references <> 0 THEN
+            RAISE EXCEPTION 'uuid migration failed verification for %: % rows before, % after, % unmapped references',
+                r.table_name, r.rows_before, r.rows_after, r.unmapped_references;
+        END IF;
+    END LOOP;
+END $$;
@@ -0,0 +1,8 @@
+-- migrations/000007_add_profile_to_users.down.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verification_expires_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verification_token;
+ALTER TABLE users DROP COLUMN IF EXISTS pending_email;
+ALTER TABLE users DROP COLUMN IF EXISTS preferences;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar_url;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
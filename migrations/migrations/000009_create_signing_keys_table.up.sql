@@ -0,0 +1,12 @@
+-- migrations/000009_create_signing_keys_table.up.sql
+
+-- Asymmetric keys used to sign access tokens. Keys are rotated on a schedule and
+-- kept until every token they signed has expired.
+CREATE TABLE IF NOT EXISTS signing_keys (
+    kid TEXT PRIMARY KEY,
+    algorithm TEXT NOT NULL,
+    private_key TEXT NOT NULL, -- PKCS#8 PEM
+    activates_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,24 @@
+-- migrations/000010_create_login_throttles_and_audit_events.up.sql
+
+-- Failed login tracking, keyed by account email or client IP.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope TEXT NOT NULL, -- 'account' or 'ip'
+    key TEXT NOT NULL,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP WITH TIME ZONE,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (scope, key)
+);
+
+-- Security relevant events such as account lockouts.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_type TEXT NOT NULL,
+    user_id UUID,
+    actor_id UUID,
+    ip TEXT,
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_type_created_at ON audit_events (event_type, created_at DESC);
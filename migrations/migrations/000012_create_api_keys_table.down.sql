@@ -0,0 +1,3 @@
+-- migrations/000012_create_api_keys_table.down.sql
+
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,25 @@
+-- migrations/000012_create_api_keys_table.up.sql
+
+-- Long-lived credentials for machine clients. Only a hash of the secret is stored;
+-- the prefix is part of the key and is used to look it up.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL UNIQUE,
+    key_hash TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '', -- Space separated, e.g. 'read write'
+    user_id UUID NOT NULL, -- The user the key acts as
+    organization TEXT, -- Set for keys owned by an organization and managed by admins
+    created_by UUID,
+    rate_limit_per_minute INTEGER NOT NULL DEFAULT 60,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_api_keys_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_api_keys_created_by FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT chk_api_keys_rate_limit CHECK (rate_limit_per_minute > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_organization ON api_keys (organization) WHERE organization IS NOT NULL;
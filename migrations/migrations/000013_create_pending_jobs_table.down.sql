@@ -0,0 +1,3 @@
+-- migrations/000013_create_pending_jobs_table.down.sql
+
+DROP TABLE IF EXISTS pending_jobs;
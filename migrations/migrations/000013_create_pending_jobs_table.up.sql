@@ -0,0 +1,11 @@
+-- migrations/000013_create_pending_jobs_table.up.sql
+
+-- Background jobs that had not finished when the server shut down. They are
+-- taken and run again on the next start.
+CREATE TABLE IF NOT EXISTS pending_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_type TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    request_id TEXT, -- The request that started the job, for log correlation
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,25 @@
+-- migrations/000014_add_integrity_constraints_and_indexes.down.sql
+
+-- Rows removed as invalid or duplicate and the capacity repair are not undone.
+
+DROP INDEX IF EXISTS idx_oidc_auth_requests_expires_at;
+DROP INDEX IF EXISTS idx_audit_events_created_at;
+DROP INDEX IF EXISTS idx_waitlist_entries_event_id_created_at;
+DROP INDEX IF EXISTS idx_reviews_event_id_created_at;
+DROP INDEX IF EXISTS idx_events_datetime;
+DROP INDEX IF EXISTS idx_events_category;
+DROP INDEX IF EXISTS idx_user_identities_user_id;
+DROP INDEX IF EXISTS idx_waitlist_entries_user_id;
+DROP INDEX IF EXISTS idx_reviews_user_id;
+DROP INDEX IF EXISTS idx_registrations_user_id;
+DROP INDEX IF EXISTS idx_events_user_id;
+
+ALTER TABLE waitlist_entries DROP CONSTRAINT IF EXISTS uq_waitlist_entries_event_user;
+ALTER TABLE reviews DROP CONSTRAINT IF EXISTS uq_reviews_event_user;
+ALTER TABLE registrations DROP CONSTRAINT IF EXISTS uq_registrations_event_user;
+
+ALTER TABLE waitlist_entries ALTER COLUMN event_id DROP NOT NULL, ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE reviews ALTER COLUMN event_id DROP NOT NULL, ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE registrations ALTER COLUMN event_id DROP NOT NULL, ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE events DROP CONSTRAINT IF EXISTS chk_events_capacity;
@@ -0,0 +1,61 @@
+-- migrations/000014_add_integrity_constraints_and_indexes.up.sql
+
+-- Registering used to decrement events.capacity and cancelling incremented it, so the
+-- column held the seats left rather than the event's size. Add the current
+-- registrations back; capacity is no longer changed by registrations.
+UPDATE events e
+SET capacity = e.capacity + (SELECT count(*) FROM registrations r WHERE r.event_id = e.id)
+WHERE e.capacity IS NOT NULL;
+UPDATE events SET capacity = 0 WHERE capacity < 0;
+ALTER TABLE events ADD CONSTRAINT chk_events_capacity CHECK (capacity >= 0);
+
+-- 000006 dropped the NOT NULL and unique constraints on registrations, reviews and
+-- waitlist entries. Remove the rows they would have rejected, keeping the earliest of
+-- any duplicates, and restore them.
+DELETE FROM registrations WHERE event_id IS NULL OR user_id IS NULL;
+DELETE FROM reviews WHERE event_id IS NULL OR user_id IS NULL;
+DELETE FROM waitlist_entries WHERE event_id IS NULL OR user_id IS NULL;
+
+DELETE FROM registrations WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY event_id, user_id ORDER BY id) AS n FROM registrations
+    ) d WHERE n > 1
+);
+DELETE FROM reviews WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY event_id, user_id ORDER BY created_at NULLS LAST, id) AS n FROM reviews
+    ) d WHERE n > 1
+);
+DELETE FROM waitlist_entries WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY event_id, user_id ORDER BY created_at NULLS LAST, id) AS n FROM waitlist_entries
+    ) d WHERE n > 1
+);
+
+ALTER TABLE registrations ALTER COLUMN event_id SET NOT NULL, ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE reviews ALTER COLUMN event_id SET NOT NULL, ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE waitlist_entries ALTER COLUMN event_id SET NOT NULL, ALTER COLUMN user_id SET NOT NULL;
+
+-- Also serve the lookups by event, and the per-event counts and existence checks
+ALTER TABLE registrations ADD CONSTRAINT uq_registrations_event_user UNIQUE (event_id, user_id);
+ALTER TABLE reviews ADD CONSTRAINT uq_reviews_event_user UNIQUE (event_id, user_id);
+ALTER TABLE waitlist_entries ADD CONSTRAINT uq_waitlist_entries_event_user UNIQUE (event_id, user_id);
+
+-- Lookups by user, which also keep ON DELETE CASCADE from scanning whole tables
+CREATE INDEX IF NOT EXISTS idx_events_user_id ON events (user_id);
+CREATE INDEX IF NOT EXISTS idx_registrations_user_id ON registrations (user_id);
+CREATE INDEX IF NOT EXISTS idx_reviews_user_id ON reviews (user_id);
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_user_id ON waitlist_entries (user_id);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id, created_at);
+
+-- Event listings by category and date range
+CREATE INDEX IF NOT EXISTS idx_events_category ON events (category);
+CREATE INDEX IF NOT EXISTS idx_events_datetime ON events (dateTime);
+
+-- Reviews newest first and the waitlist in order of joining, per event
+CREATE INDEX IF NOT EXISTS idx_reviews_event_id_created_at ON reviews (event_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_waitlist_entries_event_id_created_at ON waitlist_entries (event_id, created_at);
+
+-- Unfiltered audit log and expired OIDC request cleanup
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_oidc_auth_requests_expires_at ON oidc_auth_requests (expires_at);
@@ -0,0 +1,4 @@
+-- migrations/000015_add_event_search.down.sql
+
+DROP INDEX IF EXISTS idx_events_search_vector;
+ALTER TABLE events DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,6 @@
+-- migrations/000016_add_event_coordinates.down.sql
+
+DROP INDEX IF EXISTS idx_events_coordinates;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS chk_events_coordinates;
+ALTER TABLE events DROP COLUMN IF EXISTS longitude;
+ALTER TABLE events DROP COLUMN IF EXISTS latitude;
@@ -0,0 +1,14 @@
+-- migrations/000016_add_event_coordinates.up.sql
+
+-- Coordinates of the event location in decimal degrees, for searching events near a
+-- point. Both are set or neither.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+ALTER TABLE events ADD CONSTRAINT chk_events_coordinates CHECK (
+    (latitude IS NULL AND longitude IS NULL) OR
+    (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+);
+
+-- Nearby searches first narrow the events down to a bounding box
+CREATE INDEX IF NOT EXISTS idx_events_coordinates ON events (latitude, longitude) WHERE latitude IS NOT NULL;
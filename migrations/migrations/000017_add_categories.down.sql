@@ -0,0 +1,7 @@
+-- migrations/000017_add_categories.down.sql
+
+DROP INDEX IF EXISTS idx_events_category_id_datetime;
+CREATE INDEX IF NOT EXISTS idx_events_category ON events (category);
+ALTER TABLE events DROP CONSTRAINT IF EXISTS fk_events_categories;
+ALTER TABLE events DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS categories;
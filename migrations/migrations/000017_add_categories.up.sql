@@ -0,0 +1,57 @@
+-- migrations/000017_add_categories.up.sql
+
+-- Categories are managed by admins and can be nested. Slugs are lower case ASCII
+-- words joined by hyphens, so lookups by slug are case-insensitive.
+CREATE TABLE IF NOT EXISTS categories (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    slug VARCHAR(100) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    parent_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_categories_slug UNIQUE (slug),
+    CONSTRAINT chk_categories_slug CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$'),
+    CONSTRAINT chk_categories_parent CHECK (parent_id <> id),
+    CONSTRAINT fk_categories_parent FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
+
+-- Every free-text category in use becomes a category. Spellings that only differ in
+-- case or punctuation ("music", "Music ") share one, named after the most common
+-- spelling. Categories without letters or digits in a-z, 0-9 get a generated slug.
+INSERT INTO categories (slug, name)
+SELECT slug, mode() WITHIN GROUP (ORDER BY name)
+FROM (
+    SELECT
+        COALESCE(
+            NULLIF(trim(both '-' from regexp_replace(lower(trim(category)), '[^a-z0-9]+', '-', 'g')), ''),
+            'category-' || substr(md5(trim(category)), 1, 8)
+        ) AS slug,
+        trim(category) AS name
+    FROM events
+    WHERE trim(category) <> ''
+) AS used
+GROUP BY slug
+ON CONFLICT (slug) DO NOTHING;
+
+-- The default category of new events
+INSERT INTO categories (slug, name) VALUES ('general', 'General') ON CONFLICT (slug) DO NOTHING;
+
+-- Events reference their category. The category column keeps the category name for
+-- full-text search and existing clients.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS category_id UUID;
+
+UPDATE events e SET category_id = c.id
+FROM categories c
+WHERE c.slug = COALESCE(
+    NULLIF(trim(both '-' from regexp_replace(lower(trim(e.category)), '[^a-z0-9]+', '-', 'g')), ''),
+    'category-' || substr(md5(trim(e.category)), 1, 8)
+);
+UPDATE events SET category_id = (SELECT id FROM categories WHERE slug = 'general') WHERE category_id IS NULL;
+UPDATE events e SET category = c.name FROM categories c WHERE c.id = e.category_id AND e.category IS DISTINCT FROM c.name;
+
+ALTER TABLE events ALTER COLUMN category_id SET NOT NULL;
+ALTER TABLE events ADD CONSTRAINT fk_events_categories FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE RESTRICT;
+
+DROP INDEX IF EXISTS idx_events_category;
+CREATE INDEX IF NOT EXISTS idx_events_category_id_datetime ON events (category_id, dateTime);
@@ -0,0 +1,4 @@
+-- migrations/000018_add_tags.down.sql
+
+DROP TABLE IF EXISTS event_tags;
+DROP TABLE IF EXISTS tags;
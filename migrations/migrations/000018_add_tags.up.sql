@@ -0,0 +1,22 @@
+-- migrations/000018_add_tags.up.sql
+
+-- Free-form tags on events. Tags are created when first used; spellings with the
+-- same slug ("Live Music", "live-music") are the same tag.
+CREATE TABLE IF NOT EXISTS tags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    slug VARCHAR(50) NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_tags_slug UNIQUE (slug)
+);
+
+CREATE TABLE IF NOT EXISTS event_tags (
+    event_id UUID NOT NULL,
+    tag_id UUID NOT NULL,
+    PRIMARY KEY (event_id, tag_id),
+    CONSTRAINT fk_event_tags_events FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    CONSTRAINT fk_event_tags_tags FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- Filtering events by tag
+CREATE INDEX IF NOT EXISTS idx_event_tags_tag_id ON event_tags (tag_id);
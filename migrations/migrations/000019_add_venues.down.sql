@@ -0,0 +1,9 @@
+-- migrations/000019_add_venues.down.sql
+
+ALTER TABLE events DROP CONSTRAINT IF EXISTS excl_events_venue_time;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS chk_events_venue_end_time;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS chk_events_end_time;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS fk_events_venues;
+ALTER TABLE events DROP COLUMN IF EXISTS end_time;
+ALTER TABLE events DROP COLUMN IF EXISTS venue_id;
+DROP TABLE IF EXISTS venues;
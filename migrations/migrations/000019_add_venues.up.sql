@@ -0,0 +1,39 @@
+-- migrations/000019_add_venues.up.sql
+
+-- Venues are created by organizers and can be used by any event. Capacity is the
+-- most attendees any event there can have; NULL means unknown.
+CREATE TABLE IF NOT EXISTS venues (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(200) NOT NULL,
+    address TEXT NOT NULL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    capacity INTEGER,
+    accessibility TEXT,
+    time_zone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_venues_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT chk_venues_capacity CHECK (capacity > 0),
+    CONSTRAINT chk_venues_coordinates CHECK (
+        (latitude IS NULL AND longitude IS NULL) OR
+        (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+    )
+);
+
+CREATE INDEX IF NOT EXISTS idx_venues_user_id ON venues (user_id);
+
+-- Events can take place at a venue and have an end time, which events at a venue need.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS venue_id UUID;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS end_time TIMESTAMP;
+ALTER TABLE events ADD CONSTRAINT fk_events_venues FOREIGN KEY (venue_id) REFERENCES venues(id) ON DELETE SET NULL;
+ALTER TABLE events ADD CONSTRAINT chk_events_end_time CHECK (end_time > dateTime);
+ALTER TABLE events ADD CONSTRAINT chk_events_venue_end_time CHECK (venue_id IS NULL OR end_time IS NOT NULL);
+
+-- A venue can't host two events at the same time. Events ending when the next one
+-- starts don't overlap.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+ALTER TABLE events ADD CONSTRAINT excl_events_venue_time EXCLUDE USING gist (
+    venue_id WITH =,
+    tsrange(dateTime, end_time) WITH &&
+) WHERE (venue_id IS NOT NULL);
@@ -0,0 +1,4 @@
+-- migrations/000020_add_event_sessions.down.sql
+
+DROP TABLE IF EXISTS session_registrations;
+DROP TABLE IF EXISTS event_sessions;
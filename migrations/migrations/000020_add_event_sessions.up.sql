@@ -0,0 +1,37 @@
+-- migrations/000020_add_event_sessions.up.sql
+
+-- Sessions make up the agenda of an event, such as the talks of a conference.
+-- Capacity NULL means unlimited.
+CREATE TABLE IF NOT EXISTS event_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    track VARCHAR(100),
+    speaker VARCHAR(200),
+    room VARCHAR(100),
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    capacity INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_event_sessions_events FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    CONSTRAINT uq_event_sessions_id_event UNIQUE (id, event_id),
+    CONSTRAINT chk_event_sessions_time CHECK (end_time > start_time),
+    CONSTRAINT chk_event_sessions_capacity CHECK (capacity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_sessions_event_id_start_time ON event_sessions (event_id, start_time);
+
+-- Attendees pick sessions of events they are registered for. Cancelling the event
+-- registration removes their sessions too.
+CREATE TABLE IF NOT EXISTS session_registrations (
+    session_id UUID NOT NULL,
+    event_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, user_id),
+    CONSTRAINT fk_session_registrations_sessions FOREIGN KEY (session_id, event_id) REFERENCES event_sessions(id, event_id) ON DELETE CASCADE,
+    CONSTRAINT fk_session_registrations_registrations FOREIGN KEY (event_id, user_id) REFERENCES registrations(event_id, user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_registrations_user_id ON session_registrations (user_id);
+CREATE INDEX IF NOT EXISTS idx_session_registrations_event_user ON session_registrations (event_id, user_id);
@@ -0,0 +1,6 @@
+-- migrations/000021_add_speakers.down.sql
+
+DROP INDEX IF EXISTS idx_event_sessions_speaker_trgm;
+DROP TABLE IF EXISTS session_speakers;
+DROP TABLE IF EXISTS event_speakers;
+DROP TABLE IF EXISTS speakers;
@@ -0,0 +1,42 @@
+-- migrations/000021_add_speakers.up.sql
+
+-- Speakers and hosts can be attached to events and to sessions. Links is a JSON array
+-- of {"label", "url"} objects.
+CREATE TABLE IF NOT EXISTS speakers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(200) NOT NULL,
+    bio TEXT,
+    photo_url TEXT,
+    links JSONB NOT NULL DEFAULT '[]',
+    user_id UUID NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_speakers_users FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Speakers are searched by any part of their name
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_speakers_name_trgm ON speakers USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_speakers_user_id ON speakers (user_id);
+
+CREATE TABLE IF NOT EXISTS event_speakers (
+    event_id UUID NOT NULL,
+    speaker_id UUID NOT NULL,
+    PRIMARY KEY (event_id, speaker_id),
+    CONSTRAINT fk_event_speakers_events FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+    CONSTRAINT fk_event_speakers_speakers FOREIGN KEY (speaker_id) REFERENCES speakers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_speakers_speaker_id ON event_speakers (speaker_id);
+
+CREATE TABLE IF NOT EXISTS session_speakers (
+    session_id UUID NOT NULL,
+    speaker_id UUID NOT NULL,
+    PRIMARY KEY (session_id, speaker_id),
+    CONSTRAINT fk_session_speakers_sessions FOREIGN KEY (session_id) REFERENCES event_sessions(id) ON DELETE CASCADE,
+    CONSTRAINT fk_session_speakers_speakers FOREIGN KEY (speaker_id) REFERENCES speakers(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_speakers_speaker_id ON session_speakers (speaker_id);
+
+-- Event search also matches the free-text speaker of sessions
+CREATE INDEX IF NOT EXISTS idx_event_sessions_speaker_trgm ON event_sessions USING GIN (speaker gin_trgm_ops);
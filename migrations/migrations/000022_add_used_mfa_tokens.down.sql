@@ -0,0 +1,3 @@
+-- migrations/000022_add_used_mfa_tokens.down.sql
+
+DROP TABLE IF EXISTS used_mfa_tokens;
@@ -0,0 +1,10 @@
+-- migrations/000022_add_used_mfa_tokens.up.sql
+
+-- IDs (jti) of mfa_tokens that were exchanged for an access token, so each can only
+-- be used once. Rows are deleted once the token has expired.
+CREATE TABLE IF NOT EXISTS used_mfa_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_used_mfa_tokens_expires_at ON used_mfa_tokens (expires_at);
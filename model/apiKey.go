@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API key scopes. Read allows GET requests, write allows everything else,
+// and admin lets a key owned by an admin use the /admin endpoints.
+const (
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+	APIKeyScopeAdmin = "admin"
+)
+
+// APIKey is a long-lived credential for a machine client. It authenticates as
+// UserID; keys with an Organization are owned by that organization and managed by admins.
+type APIKey struct {
+	Id                 uuid.UUID  `json:"id"`
+	Name               string     `json:"name"`
+	Prefix             string     `json:"prefix"`
+	KeyHash            string     `json:"-"`
+	Scopes             []string   `json:"scopes"`
+	UserID             uuid.UUID  `json:"user_id"`
+	Organization       *string    `json:"organization,omitempty"`
+	CreatedBy          *uuid.UUID `json:"created_by,omitempty"`
+	RateLimitPerMinute int        `json:"rate_limit_per_minute"`
+	LastUsedAt         *time.Time `json:"last_used_at"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+	RevokedAt          *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+
+	// UserRole is the role of the user the key acts as.
+	UserRole string `json:"-"`
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditEventAccountLocked   = "account_locked"
+	AuditEventAccountUnlocked = "account_unlocked"
+	AuditEventIPLocked        = "ip_locked"
+)
+
+type AuditEvent struct {
+	Id        uuid.UUID              `json:"id"`
+	EventType string                 `json:"event_type"`
+	UserID    *uuid.UUID             `json:"user_id,omitempty"`
+	ActorID   *uuid.UUID             `json:"actor_id,omitempty"`
+	IP        string                 `json:"ip,omitempty"`
+	Details   map[string]interface{} `json:"details"`
+	CreatedAt time.Time              `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Category groups events. Categories can be nested; a category's events include
+// those of its subcategories.
+type Category struct {
+	Id        uuid.UUID  `json:"id"`
+	Slug      string     `json:"slug"`
+	Name      string     `json:"name"`
+	ParentId  *uuid.UUID `json:"parent_id"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// CategoryCount is a category with the number of its upcoming events, including
+// those of its subcategories.
+type CategoryCount struct {
+	Category
+	UpcomingEvents int `json:"upcoming_events"`
+}
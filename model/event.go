@@ -7,13 +7,29 @@ import (
 )
 
 type Event struct {
-	Id            uuid.UUID  `json:"id"`
-	Name          *string    `json:"name,omitempty" binding:"omitempty,min=5"`
-	Description   *string    `json:"description,omitempty" binding:"omitempty,min=10"`
-	Location      *string    `json:"location,omitempty"`
-	Date          *time.Time `json:"date,omitempty"`
+	Id          uuid.UUID  `json:"id"`
+	Name        *string    `json:"name,omitempty" binding:"omitempty,min=5"`
+	Description *string    `json:"description,omitempty" binding:"omitempty,min=10"`
+	Location    *string    `json:"location,omitempty"`
+	Date        *time.Time `json:"date,omitempty"`
+	// EndDate is when the event ends; required for events at a venue.
+	EndDate *time.Time `json:"end_date,omitempty"`
+	VenueID *uuid.UUID `json:"venue_id,omitempty"`
+	// Category is the name of the category given by CategoryID. Either can be set
+	// when creating or updating an event; Category is matched by slug.
 	Category      *string    `json:"category,omitempty"`
+	CategoryID    *uuid.UUID `json:"category_id,omitempty"`
 	UserIds       uuid.UUID  `json:"user_id"`
 	AverageRating float64    `json:"average_rating,omitempty"`
 	Capacity      *int       `json:"capacity,omitempty" binding:"omitempty,gte=0"`
+	// Coordinates of the location; both or neither are set. If neither is given
+	// they are looked up from Location when a geocoder is configured.
+	Latitude  *float64 `json:"latitude,omitempty" binding:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude *float64 `json:"longitude,omitempty" binding:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+	// Tags are free-form labels. When updating, nil keeps the tags and an empty
+	// list removes them.
+	Tags []string `json:"tags,omitempty" binding:"omitempty,max=20,dive,min=1,max=50"`
+	// SpeakerIDs are the event's speakers and hosts. When updating, nil keeps them
+	// and an empty list removes them.
+	SpeakerIDs []uuid.UUID `json:"speaker_ids,omitempty" binding:"omitempty,max=20"`
 }
@@ -0,0 +1,21 @@
+package model
+
+// EventFacets counts the events matching a search by category, tag, location and
+// month, for building filters. Each list has at most the most common values;
+// months are in date order.
+type EventFacets struct {
+	Total      int          `json:"total"`
+	Categories []FacetCount `json:"categories"`
+	Tags       []FacetCount `json:"tags"`
+	Locations  []FacetCount `json:"locations"`
+	Months     []FacetCount `json:"months"`
+}
+
+// FacetCount is the number of events with a value. Value is what the matching
+// search parameter takes, such as a category slug or a month as YYYY-MM; Label
+// is the display name.
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
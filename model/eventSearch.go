@@ -0,0 +1,44 @@
+package model
+
+import "go-rest-api/geo"
+
+// EventSearch filters events. Keyword is matched with full-text search over the
+// name, description, location and category; each word also matches as a prefix.
+// With Near set, only events with coordinates within RadiusKm of it are found,
+// nearest first.
+type EventSearch struct {
+	Keyword   string
+	StartDate string // YYYY-MM-DD, inclusive
+	EndDate   string // YYYY-MM-DD, inclusive
+	Near      *geo.Point
+	RadiusKm  float64
+	Category  string   // slug, including subcategories
+	Tags      []string // slugs
+	// MatchAllTags finds events with every tag instead of any of them.
+	MatchAllTags bool
+	Location     string // matched exactly, ignoring case
+	// Speaker finds events with a speaker, of the event or one of its sessions,
+	// whose name contains it, ignoring case.
+	Speaker string
+}
+
+// IsEmpty reports whether the search has no filters and finds every event.
+func (s EventSearch) IsEmpty() bool {
+	return s.Keyword == "" && s.StartDate == "" && s.EndDate == "" && s.Near == nil &&
+		s.Category == "" && len(s.Tags) == 0 && s.Location == "" && s.Speaker == ""
+}
+
+// EventSearchResult is an event found by a search. Rank and Highlights are only
+// set when searching by keyword, DistanceKm when searching near a point.
+type EventSearchResult struct {
+	Event
+	Rank       float64          `json:"rank,omitempty"`
+	Highlights *EventHighlights `json:"highlights,omitempty"`
+	DistanceKm *float64         `json:"distance_km,omitempty"`
+}
+
+// EventHighlights are HTML-escaped snippets with the matched words wrapped in <mark>.
+type EventHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+const (
+	LoginThrottleScopeAccount = "account"
+	LoginThrottleScopeIP      = "ip"
+)
+
+// LoginThrottle tracks recent failed logins for an account or client IP.
+type LoginThrottle struct {
+	Scope        string
+	Key          string
+	FailedCount  int
+	LastFailedAt *time.Time
+	LockedUntil  *time.Time
+}
@@ -0,0 +1,13 @@
+package model
+
+// UserMFA is the two-factor authentication state of a user.
+type UserMFA struct {
+	TOTPSecret       *string
+	TOTPEnabled      bool
+	TOTPLastUsedStep *int64
+}
+
+type MFAPolicy struct {
+	Role     string `json:"role" binding:"required,oneof=user admin"`
+	Required bool   `json:"required"`
+}
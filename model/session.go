@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a part of an event's agenda, such as a talk or workshop. Attendees
+// registered for the event can register for its sessions. Capacity nil means
+// unlimited. When updating, fields that are nil are kept.
+type Session struct {
+	Id        uuid.UUID  `json:"id"`
+	EventID   uuid.UUID  `json:"event_id"`
+	Title     *string    `json:"title,omitempty" binding:"omitempty,min=1,max=200"`
+	Track     *string    `json:"track,omitempty" binding:"omitempty,max=100"`
+	Speaker   *string    `json:"speaker,omitempty" binding:"omitempty,max=200"`
+	Room      *string    `json:"room,omitempty" binding:"omitempty,max=100"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+	Capacity  *int       `json:"capacity,omitempty" binding:"omitempty,gte=1"`
+	// SpeakerIDs are the session's speakers. When updating, nil keeps them and an
+	// empty list removes them.
+	SpeakerIDs []uuid.UUID `json:"speaker_ids,omitempty" binding:"omitempty,max=20"`
+	// Registered is the number of attendees registered for the session.
+	Registered int       `json:"registered"`
+	CreatedAt  time.Time `json:"created_at"`
+}
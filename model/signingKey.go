@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// SigningKeyRecord is a persisted token signing key.
+type SigningKeyRecord struct {
+	Kid         string
+	Algorithm   string
+	PrivateKey  string
+	ActivatesAt time.Time
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
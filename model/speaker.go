@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Speaker is a speaker or host of events and sessions. When updating, fields that
+// are nil are kept; an empty Links removes the links.
+type Speaker struct {
+	Id        uuid.UUID     `json:"id"`
+	Name      *string       `json:"name,omitempty" binding:"omitempty,min=1,max=200"`
+	Bio       *string       `json:"bio,omitempty" binding:"omitempty,max=5000"`
+	PhotoURL  *string       `json:"photo_url,omitempty" binding:"omitempty,http_url,max=2048"`
+	Links     []SpeakerLink `json:"links" binding:"omitempty,max=10,dive"`
+	UserID    uuid.UUID     `json:"user_id"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// SpeakerLink is a link to a speaker's website or profile elsewhere.
+type SpeakerLink struct {
+	Label string `json:"label" binding:"required,max=50"`
+	URL   string `json:"url" binding:"required,http_url,max=2048"`
+}
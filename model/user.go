@@ -1,10 +1,47 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+)
 
 type User struct {
-	Id       uuid.UUID `json:"id"`
-	Email    string    `binding:"required,email"`
-	Password string    `binding:"required,min=8"`
-	Role     string    `binding:"omitempty,oneof=user admin"`
+	Id          uuid.UUID       `json:"id"`
+	Email       string          `binding:"required,email"`
+	Password    string          `binding:"required,min=8"`
+	Role        string          `binding:"omitempty,oneof=user admin"`
+	DisplayName *string         `json:"display_name,omitempty"`
+	AvatarURL   *string         `json:"avatar_url,omitempty"`
+	Preferences UserPreferences `json:"preferences,omitempty"`
+	// PendingEmail is the address awaiting verification after an email change.
+	PendingEmail *string `json:"-"`
+}
+
+// UserPreferences holds free-form client settings, stored as JSONB.
+type UserPreferences map[string]interface{}
+
+func (p UserPreferences) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(p)
+}
+
+func (p *UserPreferences) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*p = UserPreferences{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("unsupported type for user preferences")
+	}
+	return json.Unmarshal(data, p)
 }
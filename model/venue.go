@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Venue is a place where events take place. Capacity limits the capacity of its
+// events; nil means unknown. TimeZone is an IANA name such as Europe/Berlin.
+// When updating, fields that are nil are kept.
+type Venue struct {
+	Id            uuid.UUID `json:"id"`
+	Name          *string   `json:"name,omitempty" binding:"omitempty,min=1,max=200"`
+	Address       *string   `json:"address,omitempty" binding:"omitempty,min=1,max=500"`
+	Latitude      *float64  `json:"latitude,omitempty" binding:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude     *float64  `json:"longitude,omitempty" binding:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+	Capacity      *int      `json:"capacity,omitempty" binding:"omitempty,gte=1"`
+	Accessibility *string   `json:"accessibility,omitempty" binding:"omitempty,max=2000"`
+	TimeZone      *string   `json:"time_zone,omitempty" binding:"omitempty,timezone"`
+	UserID        uuid.UUID `json:"user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
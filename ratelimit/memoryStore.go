@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store drops buckets that have refilled.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens    float64
+	last      time.Time
+	fullAfter time.Time
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns a Store that keeps buckets in this process. Limits are
+// not shared between instances of the API; use NewRedisStore for that.
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(policy.Capacity()), last: now}
+		s.buckets[key] = b
+	}
+
+	var result Result
+	b.tokens, result = take(policy, b.tokens, b.last, now)
+	b.last = now
+	b.fullAfter = now.Add(result.ResetAfter)
+
+	s.sweep(now)
+	return result, nil
+}
+
+// sweep removes full buckets; a missing bucket behaves the same as a full one.
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAfter) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStore checks the token bucket behaviour every Store must have.
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+	// 3 tokens, refilled at one per second
+	policy := Policy{Limit: 3, Period: 3 * time.Second}
+
+	take := func(key string, at time.Duration) Result {
+		t.Helper()
+		result, err := store.Take(ctx, key, policy, start.Add(at))
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("drains the bucket", func(t *testing.T) {
+		for i, remaining := range []int{2, 1, 0} {
+			result := take("drain", 0)
+			assert.True(t, result.Allowed, "request %d", i)
+			assert.Equal(t, 3, result.Limit)
+			assert.Equal(t, remaining, result.Remaining, "request %d", i)
+			assert.Equal(t, time.Duration(3-remaining)*time.Second, result.ResetAfter, "request %d", i)
+			assert.Zero(t, result.RetryAfter)
+		}
+
+		result := take("drain", 0)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Equal(t, time.Second, result.RetryAfter)
+		assert.Equal(t, 3*time.Second, result.ResetAfter)
+	})
+
+	t.Run("refills over time", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			take("refill", 0)
+		}
+
+		result := take("refill", 500*time.Millisecond)
+		assert.False(t, result.Allowed)
+		assert.InDelta(t, float64(500*time.Millisecond), float64(result.RetryAfter), float64(time.Millisecond))
+
+		result = take("refill", time.Second)
+		assert.True(t, result.Allowed, "one token after a second")
+		assert.Equal(t, 0, result.Remaining)
+
+		result = take("refill", time.Minute)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2, result.Remaining, "refilled up to the capacity")
+	})
+
+	t.Run("keeps buckets apart", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			take("apart-a", 0)
+		}
+		assert.False(t, take("apart-a", 0).Allowed)
+		assert.True(t, take("apart-b", 0).Allowed)
+	})
+
+	t.Run("burst sets the capacity", func(t *testing.T) {
+		burst := Policy{Limit: 1, Period: time.Second, Burst: 5}
+		for i := 0; i < 5; i++ {
+			result, err := store.Take(ctx, "burst", burst, start)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed, "request %d", i)
+			assert.Equal(t, 5, result.Limit)
+		}
+		result, err := store.Take(ctx, "burst", burst, start)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, time.Second, result.RetryAfter)
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	store := NewMemoryStore().(*memoryStore)
+	ctx := context.Background()
+	start := time.Unix(1700000000, 0)
+	// Refills a token every 2 sweep intervals
+	policy := Policy{Limit: 2, Period: 4 * sweepInterval}
+
+	_, err := store.Take(ctx, "idle", policy, start)
+	require.NoError(t, err)
+	_, err = store.Take(ctx, "busy", policy, start.Add(sweepInterval))
+	require.NoError(t, err)
+	assert.Len(t, store.buckets, 2, "idle bucket not full yet at the sweep")
+
+	// The idle bucket is full again, the busy one isn't
+	_, err = store.Take(ctx, "busy", policy, start.Add(3*sweepInterval))
+	require.NoError(t, err)
+	assert.NotContains(t, store.buckets, "idle")
+	assert.Contains(t, store.buckets, "busy")
+}
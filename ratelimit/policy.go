@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is a token bucket: it holds up to Burst tokens (Limit if Burst is 0)
+// and refills Limit tokens every Period. Each request takes one token.
+// The zero Policy means no limit.
+type Policy struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+func (p Policy) Enabled() bool {
+	return p.Limit > 0 && p.Period > 0
+}
+
+func (p Policy) Capacity() int {
+	if p.Burst > 0 {
+		return p.Burst
+	}
+	return p.Limit
+}
+
+// tokensPerSecond is the refill rate of the bucket.
+func (p Policy) tokensPerSecond() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// String formats the policy like ParsePolicy expects it.
+func (p Policy) String() string {
+	if !p.Enabled() {
+		return "off"
+	}
+	s := fmt.Sprintf("%d/%s", p.Limit, p.Period)
+	if p.Burst > 0 {
+		s += ":" + strconv.Itoa(p.Burst)
+	}
+	return s
+}
+
+// ParsePolicy parses "<limit>/<period>[:<burst>]", for example "60/1m" or
+// "600/1h:50". "off" disables limiting.
+func ParsePolicy(s string) (Policy, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" {
+		return Policy{}, nil
+	}
+
+	rate, burstStr, hasBurst := strings.Cut(s, ":")
+	limitStr, periodStr, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("invalid rate limit policy %q, expected <limit>/<period>[:<burst>]", s)
+	}
+
+	var policy Policy
+	var err error
+	policy.Limit, err = strconv.Atoi(limitStr)
+	if err != nil || policy.Limit < 1 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q in policy %q", limitStr, s)
+	}
+	policy.Period, err = time.ParseDuration(periodStr)
+	if err != nil || policy.Period <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit period %q in policy %q", periodStr, s)
+	}
+	if hasBurst {
+		policy.Burst, err = strconv.Atoi(burstStr)
+		if err != nil || policy.Burst < 1 {
+			return Policy{}, fmt.Errorf("invalid rate limit burst %q in policy %q", burstStr, s)
+		}
+	}
+	return policy, nil
+}
+
+// Identity decides who a request is counted against.
+type Identity string
+
+const (
+	// IdentityIP counts requests per client IP.
+	IdentityIP Identity = "ip"
+	// IdentityUser counts requests per signed-in user, or per IP for anonymous requests.
+	IdentityUser Identity = "user"
+	// IdentityAPIKey is the same as IdentityUser. Requests made with an API key are
+	// counted per key with any identity.
+	IdentityAPIKey Identity = "api_key"
+)
+
+func ParseIdentity(s string) (Identity, error) {
+	switch identity := Identity(strings.TrimSpace(s)); identity {
+	case IdentityIP, IdentityUser, IdentityAPIKey:
+		return identity, nil
+	}
+	return "", fmt.Errorf("invalid rate limit identity %q, expected ip, user or api_key", s)
+}
+
+// Result describes the state of a bucket after taking a token.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is the time until the bucket is full again.
+	ResetAfter time.Duration
+	// RetryAfter is the time until the next token is available when the request was not allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. Implementations must be safe for concurrent use.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error)
+}
+
+// take applies the token bucket algorithm to a bucket that held tokens at last.
+// It returns the new token count and the result of the request.
+func take(policy Policy, tokens float64, last time.Time, now time.Time) (float64, Result) {
+	capacity := float64(policy.Capacity())
+	rate := policy.tokensPerSecond()
+
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+
+	result := Result{Limit: policy.Capacity()}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	result.Remaining = int(math.Floor(tokens))
+	result.ResetAfter = secondsToDuration((capacity - tokens) / rate)
+	return tokens, result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript runs the token bucket atomically. It only uses HMGET, HSET and
+// PEXPIRE so it works with Redis and compatible servers such as Valkey.
+//
+// KEYS[1] bucket key
+// ARGV[1] capacity, ARGV[2] refill rate in tokens per millisecond, ARGV[3] now in milliseconds
+// Returns {allowed, tokens} with tokens as a string to keep the fraction.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+type redisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore returns a Store that keeps buckets in Redis so that all
+// instances of the API share the same limits. Keys are prefixed with prefix.
+func NewRedisStore(client redis.UniversalClient, prefix string) Store {
+	return &redisStore{client: client, prefix: prefix}
+}
+
+func (s *redisStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (Result, error) {
+	capacity := policy.Capacity()
+	ratePerMs := policy.tokensPerSecond() / 1000
+
+	values, err := takeScript.Run(ctx, s.client, []string{s.prefix + key},
+		capacity, strconv.FormatFloat(ratePerMs, 'g', -1, 64), now.UnixMilli()).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	if len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	tokensStr, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected rate limit token count %q: %w", tokensStr, err)
+	}
+
+	result := Result{
+		Allowed:    allowed == 1,
+		Limit:      capacity,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: secondsToDuration((float64(capacity) - tokens) / policy.tokensPerSecond()),
+	}
+	if !result.Allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / policy.tokensPerSecond())
+	}
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisStore(t *testing.T) (Store, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisStore(client, "test:"), server
+}
+
+func TestRedisStore(t *testing.T) {
+	store, _ := newTestRedisStore(t)
+	testStore(t, store)
+}
+
+func TestRedisStoreExpiresFullBuckets(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	policy := Policy{Limit: 3, Period: 3 * time.Second}
+
+	_, err := store.Take(ctx, "client", policy, now)
+	require.NoError(t, err)
+	require.True(t, server.Exists("test:client"), "keys are prefixed")
+	// The key lives until the bucket is full again, plus a second
+	assert.Equal(t, 2*time.Second, server.TTL("test:client"))
+
+	_, err = store.Take(ctx, "client", policy, now)
+	require.NoError(t, err)
+	_, err = store.Take(ctx, "client", policy, now)
+	require.NoError(t, err)
+	assert.Equal(t, 4*time.Second, server.TTL("test:client"))
+
+	server.FastForward(4 * time.Second)
+	assert.False(t, server.Exists("test:client"))
+
+	// A missing key is a full bucket
+	result, err := store.Take(ctx, "client", policy, now.Add(4*time.Second))
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Remaining)
+}
+
+func TestRedisStoreKeepsFractions(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	policy := Policy{Limit: 1, Period: time.Second}
+
+	_, err := store.Take(ctx, "client", policy, now)
+	require.NoError(t, err)
+
+	result, err := store.Take(ctx, "client", policy, now.Add(250*time.Millisecond))
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, "0.25", server.HGet("test:client", "tokens"))
+	assert.Equal(t, 750*time.Millisecond, result.RetryAfter)
+}
+
+func TestRedisStoreError(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	server.Close()
+
+	_, err := store.Take(context.Background(), "client", Policy{Limit: 1, Period: time.Second}, time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error)
+	GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error)
+	GetByOrganization(ctx context.Context, organization string) ([]model.APIKey, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID) error
+}
+
+type apiKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+const apiKeyColumns = `k.id, k.name, k.prefix, k.key_hash, k.scopes, k.user_id, k.organization, k.created_by,
+		k.rate_limit_per_minute, k.last_used_at, k.expires_at, k.revoked_at, k.created_at, u.role`
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *model.APIKey) error {
+	key.Id = uuid.New()
+	query := `
+		INSERT INTO api_keys (id, name, prefix, key_hash, scopes, user_id, organization, created_by, rate_limit_per_minute, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, key.Id, key.Name, key.Prefix, key.KeyHash, strings.Join(key.Scopes, " "),
+		key.UserID, key.Organization, key.CreatedBy, key.RateLimitPerMinute, key.ExpiresAt).Scan(&key.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperrors.ErrAlreadyExists
+		}
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.id = $1"
+	return r.getOne(ctx, query, id)
+}
+
+func (r *apiKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.prefix = $1"
+	return r.getOne(ctx, query, prefix)
+}
+
+// GetByUserID returns the keys a user created for themselves, not organization keys acting as them.
+func (r *apiKeyRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.user_id = $1 AND k.organization IS NULL ORDER BY k.created_at DESC"
+	return r.getMany(ctx, query, userID)
+}
+
+// GetByOrganization returns the keys of one organization, or of all organizations if organization is empty.
+func (r *apiKeyRepository) GetByOrganization(ctx context.Context, organization string) ([]model.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.organization IS NOT NULL AND ($1 = '' OR k.organization = $1) ORDER BY k.organization, k.created_at DESC"
+	return r.getMany(ctx, query, organization)
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id uuid.UUID) error {
+	query := "UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL"
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return nil
+}
+
+// TouchLastUsed records that a key was used. It writes at most once a minute per key.
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	query := "UPDATE api_keys SET last_used_at = NOW() WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')"
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to update API key last used time: %w", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) getOne(ctx context.Context, query string, arg interface{}) (*model.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return key, nil
+}
+
+func (r *apiKeyRepository) getMany(ctx context.Context, query string, arg interface{}) ([]model.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API keys: %w", err)
+	}
+	return keys, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	var key model.APIKey
+	var scopes string
+	err := row.Scan(&key.Id, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &key.UserID, &key.Organization, &key.CreatedBy,
+		&key.RateLimitPerMinute, &key.LastUsedAt, &key.ExpiresAt, &key.RevokedAt, &key.CreatedAt, &key.UserRole)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = strings.Fields(scopes)
+	return &key, nil
+}
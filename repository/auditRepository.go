@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go-rest-api/model"
+
+	"github.com/google/uuid"
+)
+
+type AuditRepository interface {
+	Record(ctx context.Context, event *model.AuditEvent) error
+	List(ctx context.Context, eventType string, limit int) ([]model.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Record(ctx context.Context, event *model.AuditEvent) error {
+	event.Id = uuid.New()
+	if event.Details == nil {
+		event.Details = map[string]interface{}{}
+	}
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit event details: %w", err)
+	}
+
+	query := `
+		INSERT INTO audit_events (id, event_type, user_id, actor_id, ip, details)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING created_at
+	`
+	err = r.db.QueryRowContext(ctx, query, event.Id, event.EventType, event.UserID, event.ActorID, event.IP, details).Scan(&event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+// List returns the most recent events, optionally filtered by type.
+func (r *auditRepository) List(ctx context.Context, eventType string, limit int) ([]model.AuditEvent, error) {
+	query := `
+		SELECT id, event_type, user_id, actor_id, COALESCE(ip, ''), details, created_at
+		FROM audit_events
+		WHERE $1 = '' OR event_type = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, eventType, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]model.AuditEvent, 0)
+	for rows.Next() {
+		var event model.AuditEvent
+		var details []byte
+		if err := rows.Scan(&event.Id, &event.EventType, &event.UserID, &event.ActorID, &event.IP, &details, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if err := json.Unmarshal(details, &event.Details); err != nil {
+			return nil, fmt.Errorf("failed to decode audit event details: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit events: %w", err)
+	}
+	return events, nil
+}
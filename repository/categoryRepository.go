@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+
+	"github.com/google/uuid"
+)
+
+type CategoryRepository interface {
+	Create(ctx context.Context, category *model.Category) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Category, error)
+	GetBySlug(ctx context.Context, slug string) (*model.Category, error)
+	// GetAllWithCounts returns every category by name with its number of upcoming events.
+	GetAllWithCounts(ctx context.Context) ([]model.CategoryCount, error)
+	// IsInTree reports whether id is rootID or one of its subcategories.
+	IsInTree(ctx context.Context, rootID, id uuid.UUID) (bool, error)
+	// Update saves the category and renames the category of its events.
+	Update(ctx context.Context, category *model.Category) error
+	// Delete returns apperrors.ErrConflict if events or subcategories still use the category.
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type categoryRepository struct {
+	db *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) CategoryRepository {
+	return &categoryRepository{db: db}
+}
+
+const categoryColumns = "id, slug, name, parent_id, created_at"
+
+// categoryTree pairs every category as root_id with itself and each of its
+// subcategories as id.
+const categoryTree = `
+	WITH RECURSIVE category_tree (root_id, id) AS (
+		SELECT id, id FROM categories
+		UNION
+		SELECT t.root_id, c.id FROM categories c JOIN category_tree t ON c.parent_id = t.id
+	)`
+
+func (r *categoryRepository) Create(ctx context.Context, category *model.Category) error {
+	category.Id = uuid.New()
+	query := "INSERT INTO categories (id, slug, name, parent_id) VALUES ($1, $2, $3, $4) RETURNING created_at"
+	err := r.db.QueryRowContext(ctx, query, category.Id, category.Slug, category.Name, category.ParentId).Scan(&category.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperrors.ErrAlreadyExists
+		}
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+	return nil
+}
+
+func (r *categoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Category, error) {
+	query := "SELECT " + categoryColumns + " FROM categories WHERE id = $1"
+	return r.getOne(ctx, query, id)
+}
+
+func (r *categoryRepository) GetBySlug(ctx context.Context, slug string) (*model.Category, error) {
+	query := "SELECT " + categoryColumns + " FROM categories WHERE slug = $1"
+	return r.getOne(ctx, query, slug)
+}
+
+func (r *categoryRepository) getOne(ctx context.Context, query string, arg interface{}) (*model.Category, error) {
+	var category model.Category
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(&category.Id, &category.Slug, &category.Name, &category.ParentId, &category.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+	return &category, nil
+}
+
+func (r *categoryRepository) GetAllWithCounts(ctx context.Context) ([]model.CategoryCount, error) {
+	query := categoryTree + `
+		SELECT c.id, c.slug, c.name, c.parent_id, c.created_at, count(e.id)
+		FROM categories c
+		JOIN category_tree t ON t.root_id = c.id
+		LEFT JOIN events e ON e.category_id = t.id AND e.dateTime >= NOW()
+		GROUP BY c.id
+		ORDER BY c.name
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := make([]model.CategoryCount, 0)
+	for rows.Next() {
+		var category model.CategoryCount
+		if err := rows.Scan(&category.Id, &category.Slug, &category.Name, &category.ParentId, &category.CreatedAt, &category.UpcomingEvents); err != nil {
+			return nil, fmt.Errorf("failed to scan category row: %w", err)
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating category rows: %w", err)
+	}
+	return categories, nil
+}
+
+func (r *categoryRepository) IsInTree(ctx context.Context, rootID, id uuid.UUID) (bool, error) {
+	query := categoryTree + " SELECT EXISTS(SELECT 1 FROM category_tree WHERE root_id = $1 AND id = $2)"
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, rootID, id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check category tree: %w", err)
+	}
+	return exists, nil
+}
+
+func (r *categoryRepository) Update(ctx context.Context, category *model.Category) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE categories SET slug = $1, name = $2, parent_id = $3 WHERE id = $4"
+	result, err := tx.ExecContext(ctx, query, category.Slug, category.Name, category.ParentId, category.Id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperrors.ErrAlreadyExists
+		}
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+
+	// Events keep the category name for full-text search
+	_, err = tx.ExecContext(ctx, "UPDATE events SET category = $1 WHERE category_id = $2 AND category <> $1", category.Name, category.Id)
+	if err != nil {
+		return fmt.Errorf("failed to rename event categories: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (r *categoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return apperrors.ErrConflict
+		}
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PostgreSQL error codes for violated unique, foreign key and exclusion constraints.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	exclusionViolation  = "23P01"
+)
+
+// isUniqueViolation reports whether err was caused by a unique constraint, which
+// repositories return as apperrors.ErrAlreadyExists.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// isForeignKeyViolation reports whether err was caused by a foreign key, for example
+// when deleting a row that is still referenced. Repositories return it as
+// apperrors.ErrConflict.
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation
+}
+
+// isExclusionViolation reports whether err was caused by an exclusion constraint,
+// such as two events overlapping at a venue. Repositories return it as
+// apperrors.ErrConflict.
+func isExclusionViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == exclusionViolation
+}
@@ -3,9 +3,19 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/geo"
 	"go-rest-api/model"
-	"log"
+	"go-rest-api/utils"
+	"html"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -14,12 +24,29 @@ type EventRepository interface {
 	Save(ctx context.Context, event *model.Event) error
 	GetAllEvents(ctx context.Context) ([]model.Event, error)
 	GetEventById(ctx context.Context, id uuid.UUID) (*model.Event, error)
-	GetEventsByCategory(ctx context.Context, category string) ([]model.Event, error)
-	GetEventsByCriteria(ctx context.Context, keyword string, startDate string, endDate string) ([]model.Event, error)
+	// GetEventsByCategory returns the events of the category and its subcategories.
+	GetEventsByCategory(ctx context.Context, categoryID uuid.UUID) ([]model.Event, error)
+	// SearchEvents returns events matching search: the nearest first when searching
+	// near a point, else the best matches first when searching by keyword, else in
+	// date order.
+	SearchEvents(ctx context.Context, search model.EventSearch) ([]model.EventSearchResult, error)
+	// GetVenueConflicts returns the events at the venue, other than excludeID, that
+	// overlap the time from start to end.
+	GetVenueConflicts(ctx context.Context, venueID uuid.UUID, start, end time.Time, excludeID uuid.UUID) ([]uuid.UUID, error)
+	// GetVenueEventsOverCapacity returns the upcoming events at the venue whose
+	// capacity is unlimited or more than capacity.
+	GetVenueEventsOverCapacity(ctx context.Context, venueID uuid.UUID, capacity int) ([]uuid.UUID, error)
+	// GetUpcomingEventsBySpeaker returns the upcoming events the speaker is attached
+	// to, directly or through one of their sessions, by date.
+	GetUpcomingEventsBySpeaker(ctx context.Context, speakerID uuid.UUID) ([]model.Event, error)
+	// GetEventFacets counts the events matching search by category, tag, location and month.
+	GetEventFacets(ctx context.Context, search model.EventSearch) (*model.EventFacets, error)
 	UpdateAverageRating(ctx context.Context, eventID uuid.UUID, avgRating float64) error
 	Update(ctx context.Context, event *model.Event) error
 	DeleteEvent(ctx context.Context, id uuid.UUID) error
-	RegisterEvent(ctx context.Context, eventID, userID uuid.UUID) error
+	// RegisterEventIfSeatFree registers the user unless the event is full, which it
+	// returns as apperrors.ErrConflict.
+	RegisterEventIfSeatFree(ctx context.Context, eventID, userID uuid.UUID) error
 	GetRegistrationCount(ctx context.Context, eventID uuid.UUID) (int, error)
 	IsUserRegistered(ctx context.Context, eventID uuid.UUID, userID uuid.UUID) (bool, error)
 	CancelRegistration(ctx context.Context, eventID, userID uuid.UUID) error
@@ -34,17 +61,105 @@ func NewEventRepository(db *sql.DB) EventRepository {
 	return &sqliteEventRepository{db: db}
 }
 
+// eventColumns are the events columns read into a model.Event, in the order of
+// eventFields. The tags are a JSON array of names, the speakers one of IDs. Queries
+// using it must not alias the events table.
+var eventColumns = "id, name, description, location, dateTime, end_time, venue_id, user_id, category, category_id, average_rating, capacity, latitude, longitude, " +
+	"(SELECT COALESCE(json_agg(t.name ORDER BY t.name), '[]') FROM event_tags et JOIN tags t ON t.id = et.tag_id WHERE et.event_id = events.id), " +
+	speakerIDsColumn("event_speakers", "event_id", "events")
+
+// eventFields returns the scan destinations for eventColumns.
+func eventFields(event *model.Event) []interface{} {
+	return []interface{}{&event.Id, &event.Name, &event.Description, &event.Location, &event.Date, &event.EndDate, &event.VenueID, &event.UserIds, &event.Category, &event.CategoryID, &event.AverageRating, &event.Capacity, &event.Latitude, &event.Longitude, jsonColumn{&event.Tags}, jsonColumn{&event.SpeakerIDs}}
+}
+
+// jsonColumn scans a JSON column into dest.
+type jsonColumn struct {
+	dest interface{}
+}
+
+func (c jsonColumn) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, c.dest)
+	case string:
+		return json.Unmarshal([]byte(src), c.dest)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T as JSON", src)
+}
+
 func (r *sqliteEventRepository) Save(ctx context.Context, event *model.Event) error {
 
 	event.Id = uuid.New()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	// Include capacity in the INSERT statement
-	insert := "INSERT INTO events (id, name, description, location, dateTime, category, user_id, capacity) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
-	_, err := r.db.ExecContext(ctx, insert, event.Id, event.Name, event.Description, event.Location, event.Date, event.Category, event.UserIds, event.Capacity)
+	insert := "INSERT INTO events (id, name, description, location, dateTime, end_time, venue_id, category, category_id, user_id, capacity, latitude, longitude) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)"
+	_, err = tx.ExecContext(ctx, insert, event.Id, event.Name, event.Description, event.Location, event.Date, event.EndDate, event.VenueID, event.Category, event.CategoryID, event.UserIds, event.Capacity, event.Latitude, event.Longitude)
 	if err != nil {
+		if isExclusionViolation(err) {
+			return apperrors.ErrConflict
+		}
 		return fmt.Errorf("failed to execute statement for event save: %w", err)
 	}
+	if event.Tags != nil {
+		if event.Tags, err = setEventTags(ctx, tx, event.Id, event.Tags); err != nil {
+			return err
+		}
+	}
+	if len(event.SpeakerIDs) > 0 {
+		if err := setSpeakers(ctx, tx, "event_speakers", "event_id", event.Id, event.SpeakerIDs); err != nil {
+			return err
+		}
+	}
 
-	return nil
+	return tx.Commit()
+}
+
+// setEventTags replaces the tags of an event, creating tags that don't exist yet,
+// and returns the tag names as stored. A tag that already exists keeps its name.
+func setEventTags(ctx context.Context, tx *sql.Tx, eventID uuid.UUID, tags []string) ([]string, error) {
+	_, err := tx.ExecContext(ctx, "DELETE FROM event_tags WHERE event_id = $1", eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete event tags: %w", err)
+	}
+	if len(tags) == 0 {
+		return []string{}, nil
+	}
+
+	slugs := make([]string, len(tags))
+	for i, tag := range tags {
+		slugs[i] = utils.Slugify(tag)
+	}
+	insertTags := "INSERT INTO tags (slug, name) SELECT * FROM unnest($1::text[], $2::text[]) ON CONFLICT (slug) DO NOTHING"
+	if _, err := tx.ExecContext(ctx, insertTags, slugs, tags); err != nil {
+		return nil, fmt.Errorf("failed to create tags: %w", err)
+	}
+	insertEventTags := "INSERT INTO event_tags (event_id, tag_id) SELECT $1, id FROM tags WHERE slug = ANY($2) ON CONFLICT DO NOTHING"
+	if _, err := tx.ExecContext(ctx, insertEventTags, eventID, slugs); err != nil {
+		return nil, fmt.Errorf("failed to tag event: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM tags WHERE slug = ANY($1) ORDER BY name", slugs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+	names := make([]string, 0, len(tags))
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan tag row: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
 }
 
 func (r *sqliteEventRepository) GetRegistrationCount(ctx context.Context, eventID uuid.UUID) (int, error) {
@@ -52,7 +167,7 @@ func (r *sqliteEventRepository) GetRegistrationCount(ctx context.Context, eventI
 	var count int
 	err := r.db.QueryRowContext(ctx, query, eventID).Scan(&count)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get registration count for event %d: %w", eventID, err)
+		return 0, fmt.Errorf("failed to get registration count for event %s: %w", eventID, err)
 	}
 	return count, nil
 }
@@ -62,54 +177,49 @@ func (r *sqliteEventRepository) IsUserRegistered(ctx context.Context, eventID uu
 	var exists bool
 	err := r.db.QueryRowContext(ctx, query, eventID, userID).Scan(&exists)
 	if err != nil {
-		return false, fmt.Errorf("failed to check if user %d is registered for event %d: %w", userID, eventID, err)
+		return false, fmt.Errorf("failed to check if user %s is registered for event %s: %w", userID, eventID, err)
 	}
 	return exists, nil
 }
 
 func (r *sqliteEventRepository) GetAllEvents(ctx context.Context) ([]model.Event, error) {
-	log.Println("Getting all events from database")
-
-	query := "SELECT id, name, description, location, dateTime, user_id, category, average_rating, capacity FROM events"
-	log.Printf("Executing query: %s", query)
+	query := "SELECT " + eventColumns + " FROM events"
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
-		log.Printf("Error executing query: %v", err)
 		return nil, fmt.Errorf("failed to query events: %w", err)
 	}
 	defer rows.Close()
 
 	var events []model.Event
-	log.Println("Scanning rows...")
 	for rows.Next() {
 		var event model.Event
 
-		err := rows.Scan(&event.Id, &event.Name, &event.Description, &event.Location, &event.Date, &event.UserIds, &event.Category, &event.AverageRating, &event.Capacity)
+		err := rows.Scan(eventFields(&event)...)
 		if err != nil {
-			log.Printf("Error scanning row: %v", err)
 			return nil, fmt.Errorf("failed to scan event row: %w", err)
 		}
-		log.Printf("Scanned event: %+v", event)
 		events = append(events, event)
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating rows: %v", err)
 		return nil, fmt.Errorf("error iterating event rows: %w", err)
 	}
-	log.Printf("Successfully retrieved %d events", len(events))
+	slog.DebugContext(ctx, "retrieved events", "count", len(events))
 	return events, nil
 }
 
 func (r *sqliteEventRepository) GetEventById(ctx context.Context, id uuid.UUID) (*model.Event, error) {
 
-	query := "SELECT id, name, description, location, dateTime, user_id, category, average_rating, capacity FROM events WHERE id = $1"
+	query := "SELECT " + eventColumns + " FROM events WHERE id = $1"
 	row := r.db.QueryRowContext(ctx, query, id)
 
 	var event model.Event
 
-	err := row.Scan(&event.Id, &event.Name, &event.Description, &event.Location, &event.Date, &event.UserIds, &event.Category, &event.AverageRating, &event.Capacity)
+	err := row.Scan(eventFields(&event)...)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrNotFound
+		}
 		return nil, err
 	}
 
@@ -143,16 +253,35 @@ func (r *sqliteEventRepository) Update(ctx context.Context, event *model.Event)
 		args = append(args, *event.Date)
 		argId++
 	}
+	if event.EndDate != nil {
+		query += fmt.Sprintf(" end_time = $%d,", argId)
+		args = append(args, *event.EndDate)
+		argId++
+	}
+	if event.VenueID != nil {
+		query += fmt.Sprintf(" venue_id = $%d,", argId)
+		args = append(args, *event.VenueID)
+		argId++
+	}
 	if event.Category != nil {
 		query += fmt.Sprintf(" category = $%d,", argId)
 		args = append(args, *event.Category)
 		argId++
 	}
+	if event.CategoryID != nil {
+		query += fmt.Sprintf(" category_id = $%d,", argId)
+		args = append(args, *event.CategoryID)
+		argId++
+	}
 	if event.Capacity != nil {
 		query += fmt.Sprintf(" capacity = $%d,", argId)
 		args = append(args, *event.Capacity)
 		argId++
 	}
+	// Coordinates are always written, so they can be cleared when the location changes
+	query += fmt.Sprintf(" latitude = $%d, longitude = $%d,", argId, argId+1)
+	args = append(args, event.Latitude, event.Longitude)
+	argId += 2
 
 	// Remove trailing comma
 	query = query[:len(query)-1]
@@ -160,12 +289,32 @@ func (r *sqliteEventRepository) Update(ctx context.Context, event *model.Event)
 	query += fmt.Sprintf(" WHERE id = $%d", argId)
 	args = append(args, event.Id)
 
-	_, err := r.db.ExecContext(ctx, query, args...)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		if isExclusionViolation(err) {
+			return apperrors.ErrConflict
+		}
 		return err
 	}
+	// nil keeps the tags and speakers
+	if event.Tags != nil {
+		if event.Tags, err = setEventTags(ctx, tx, event.Id, event.Tags); err != nil {
+			return err
+		}
+	}
+	if event.SpeakerIDs != nil {
+		if err := setSpeakers(ctx, tx, "event_speakers", "event_id", event.Id, event.SpeakerIDs); err != nil {
+			return err
+		}
+	}
 
-	return nil
+	return tx.Commit()
 }
 
 func (r *sqliteEventRepository) DeleteEvent(ctx context.Context, id uuid.UUID) error {
@@ -184,40 +333,49 @@ func (r *sqliteEventRepository) DeleteEvent(ctx context.Context, id uuid.UUID) e
 	return nil
 }
 
-func (r *sqliteEventRepository) RegisterEvent(ctx context.Context, eventId, userId uuid.UUID) error {
+// RegisterEvent records the registration. Capacity is the event's total number of
+// seats and is left unchanged; seats taken are counted from the registrations.
+// It returns apperrors.ErrAlreadyExists if the user is already registered.
+// RegisterEventIfSeatFree locks the event row while counting its registrations, so
+// concurrent calls, also from other instances, can't overbook it.
+func (r *sqliteEventRepository) RegisterEventIfSeatFree(ctx context.Context, eventID, userID uuid.UUID) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer tx.Rollback() // Rollback on any error
+	defer tx.Rollback()
 
-	// Insert into registrations table
-	insertRegistration := "INSERT INTO registrations (id, event_id, user_id) VALUES ($1, $2, $3)"
-	_, err = tx.ExecContext(ctx, insertRegistration, uuid.New(), eventId, userId)
+	var capacity *int
+	err = tx.QueryRowContext(ctx, "SELECT capacity FROM events WHERE id = $1 FOR UPDATE", eventID).Scan(&capacity)
 	if err != nil {
-		return fmt.Errorf("failed to insert registration: %w", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.ErrNotFound
+		}
+		return fmt.Errorf("failed to lock event: %w", err)
+	}
+	if capacity != nil && *capacity > 0 {
+		var count int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM registrations WHERE event_id = $1", eventID).Scan(&count); err != nil {
+			return fmt.Errorf("failed to get registration count: %w", err)
+		}
+		if count >= *capacity {
+			return apperrors.ErrConflict
+		}
 	}
 
-	// Decrement event capacity
-	updateCapacity := "UPDATE events SET capacity = capacity - 1 WHERE id = $1"
-	_, err = tx.ExecContext(ctx, updateCapacity, eventId)
+	_, err = tx.ExecContext(ctx, "INSERT INTO registrations (id, event_id, user_id) VALUES ($1, $2, $3)", uuid.New(), eventID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to update event capacity: %w", err)
+		if isUniqueViolation(err) {
+			return apperrors.ErrAlreadyExists
+		}
+		return fmt.Errorf("failed to insert registration: %w", err)
 	}
-
 	return tx.Commit()
 }
 
 func (r *sqliteEventRepository) CancelRegistration(ctx context.Context, eventId, userId uuid.UUID) error {
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
-	// Delete the registration
 	deleteRegistration := "DELETE FROM registrations WHERE event_id = $1 AND user_id = $2"
-	result, err := tx.ExecContext(ctx, deleteRegistration, eventId, userId)
+	result, err := r.db.ExecContext(ctx, deleteRegistration, eventId, userId)
 	if err != nil {
 		return fmt.Errorf("failed to delete registration: %w", err)
 	}
@@ -227,34 +385,13 @@ func (r *sqliteEventRepository) CancelRegistration(ctx context.Context, eventId,
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not registered for this event")
+		return apperrors.ErrNotFound
 	}
-
-	// Increment event capacity
-	updateCapacity := "UPDATE events SET capacity = capacity + 1 WHERE id = $1"
-	_, err = tx.ExecContext(ctx, updateCapacity, eventId)
-	if err != nil {
-		return fmt.Errorf("failed to update event capacity: %w", err)
-	}
-
-	return tx.Commit()
+	return nil
 }
 
 func (r *sqliteEventRepository) GetRegisteredEventByUserId(ctx context.Context, userId uuid.UUID) ([]model.Event, error) {
-	query := `
-		SELECT
-			e.id,
-			e.name,
-			e.description,
-			e.location,
-			e.dateTime,
-			e.user_id,
-			e.category,
-			e.capacity -- Add capacity
-		FROM events AS e
-		JOIN registrations AS r ON e.id = r.event_id
-		WHERE r.user_id = $1
-	`
+	query := "SELECT " + eventColumns + " FROM events WHERE id IN (SELECT event_id FROM registrations WHERE user_id = $1)"
 	rows, err := r.db.QueryContext(ctx, query, userId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query registered events: %w", err)
@@ -263,112 +400,314 @@ func (r *sqliteEventRepository) GetRegisteredEventByUserId(ctx context.Context,
 
 	events := make([]model.Event, 0)
 
-	log.Printf("Querying registered events for user ID: %d", userId)
 	for rows.Next() {
 		var event model.Event
 
-		err := rows.Scan(&event.Id, &event.Name, &event.Description, &event.Location, &event.Date, &event.UserIds, &event.Category, &event.Capacity)
+		err := rows.Scan(eventFields(&event)...)
 		if err != nil {
-			log.Printf("Error scanning registered event row: %v", err)
 			return nil, fmt.Errorf("failed to scan registered event row: %w", err)
 		}
 		events = append(events, event)
-		log.Printf("Scanned event: %+v", event)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating registered event rows: %w", err)
 	}
-	log.Printf("Finished scanning. Total events found: %d", len(events))
+	slog.DebugContext(ctx, "retrieved registered events", "user_id", userId, "count", len(events))
 	return events, nil
 }
 
-func (r *sqliteEventRepository) GetEventsByCategory(ctx context.Context, category string) ([]model.Event, error) {
-	log.Printf("Getting events with category: %s", category)
-
-	query := "SELECT id, name, description, location, dateTime, user_id, category, average_rating, capacity FROM events WHERE category = $1"
-	log.Printf("Executing query: %s with category=%s", query, category)
-	rows, err := r.db.QueryContext(ctx, query, category)
+func (r *sqliteEventRepository) GetEventsByCategory(ctx context.Context, categoryID uuid.UUID) ([]model.Event, error) {
+	query := categoryTree + " SELECT " + eventColumns + " FROM events WHERE category_id IN (SELECT id FROM category_tree WHERE root_id = $1) ORDER BY dateTime"
+	rows, err := r.db.QueryContext(ctx, query, categoryID)
 	if err != nil {
-		log.Printf("Error executing category query: %v", err)
 		return nil, fmt.Errorf("failed to query events by category: %w", err)
 	}
 	defer rows.Close()
 
 	var events []model.Event
-	log.Println("Scanning category rows...")
 	for rows.Next() {
 		var event model.Event
 
-		err := rows.Scan(&event.Id, &event.Name, &event.Description, &event.Location, &event.Date, &event.UserIds, &event.Category, &event.AverageRating, &event.Capacity)
+		err := rows.Scan(eventFields(&event)...)
 		if err != nil {
-			log.Printf("Error scanning category row: %v", err)
 			return nil, fmt.Errorf("failed to scan event row: %w", err)
 		}
-		log.Printf("Scanned category event: %+v", event)
 		events = append(events, event)
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating category rows: %v", err)
 		return nil, fmt.Errorf("error iterating event rows: %w", err)
 	}
-	log.Printf("Successfully retrieved %d events with category %s", len(events), category)
+	slog.DebugContext(ctx, "retrieved events by category", "category_id", categoryID, "count", len(events))
 	return events, nil
 }
 
-func (r *sqliteEventRepository) GetEventsByCriteria(ctx context.Context, keyword string, startDate string, endDate string) ([]model.Event, error) {
+func (r *sqliteEventRepository) GetUpcomingEventsBySpeaker(ctx context.Context, speakerID uuid.UUID) ([]model.Event, error) {
+	query := "SELECT " + eventColumns + ` FROM events
+		WHERE dateTime >= NOW() AND (id IN (SELECT event_id FROM event_speakers WHERE speaker_id = $1)
+		OR id IN (SELECT s.event_id FROM event_sessions s JOIN session_speakers ss ON ss.session_id = s.id WHERE ss.speaker_id = $1))
+		ORDER BY dateTime`
+	rows, err := r.db.QueryContext(ctx, query, speakerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events by speaker: %w", err)
+	}
+	defer rows.Close()
 
-	query := "SELECT id, name, description, location, dateTime, user_id, category, average_rating, capacity FROM events WHERE 1=1"
-	args := []interface{}{}
-	argId := 1
+	events := make([]model.Event, 0)
+	for rows.Next() {
+		var event model.Event
+		if err := rows.Scan(eventFields(&event)...); err != nil {
+			return nil, fmt.Errorf("failed to scan event row: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event rows: %w", err)
+	}
+	return events, nil
+}
 
-	if keyword != "" {
+// Headline options for search snippets. The matched words are marked with control
+// characters that can't appear in the options or be confused with event text, so
+// the snippet can be HTML-escaped before they are replaced with <mark> tags.
+const (
+	highlightStart         = "\x01"
+	highlightStop          = "\x02"
+	nameHeadlineOptions    = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `", HighlightAll=true`
+	snippetHeadlineOptions = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `", MaxWords=35, MinWords=15, MaxFragments=2`
+)
 
-		query += fmt.Sprintf(" AND (name ILIKE $%d OR description ILIKE $%d)", argId, argId)
-		args = append(args, "%"+keyword+"%")
-		argId++
+// eventFilter holds the clauses selecting the events that match a search.
+type eventFilter struct {
+	with     string // WITH clause of common table expressions the filter uses, if any
+	from     string
+	where    string
+	args     []interface{}
+	tsQuery  string
+	distance string // distance in km from the search point, if searching near one
+}
+
+// arg adds a query argument and returns its placeholder.
+func (f *eventFilter) arg(value interface{}) string {
+	f.args = append(f.args, value)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+func newEventFilter(search model.EventSearch) *eventFilter {
+	f := &eventFilter{from: " FROM events", where: " WHERE 1=1", tsQuery: prefixTSQuery(search.Keyword)}
+
+	if f.tsQuery != "" {
+		f.from += ", to_tsquery('english', " + f.arg(f.tsQuery) + ") AS q"
+		f.where += " AND search_vector @@ q"
+	}
+	if search.StartDate != "" {
+		f.where += " AND dateTime >= " + f.arg(search.StartDate) + "::date"
+	}
+	if search.EndDate != "" {
+		f.where += " AND dateTime < " + f.arg(search.EndDate) + "::date + 1"
+	}
+	if search.Category != "" {
+		f.with = categoryTree
+		f.where += " AND category_id IN (SELECT t.id FROM category_tree t JOIN categories c ON c.id = t.root_id WHERE c.slug = " + f.arg(search.Category) + ")"
 	}
+	if len(search.Tags) > 0 {
+		tagCount := "(SELECT count(*) FROM event_tags et JOIN tags t ON t.id = et.tag_id WHERE et.event_id = events.id AND t.slug = ANY(" + f.arg(search.Tags) + "))"
+		if search.MatchAllTags {
+			f.where += " AND " + tagCount + " = " + f.arg(len(search.Tags))
+		} else {
+			f.where += " AND " + tagCount + " > 0"
+		}
+	}
+	if search.Location != "" {
+		f.where += " AND lower(location) = lower(" + f.arg(search.Location) + ")"
+	}
+	if search.Speaker != "" {
+		// Speakers of the event or of one of its sessions, or the free-text speaker of a session
+		name := f.arg(containsPattern(search.Speaker))
+		f.where += " AND (id IN (SELECT es.event_id FROM event_speakers es JOIN speakers sp ON sp.id = es.speaker_id WHERE sp.name ILIKE " + name + ")" +
+			" OR id IN (SELECT s.event_id FROM event_sessions s JOIN session_speakers ss ON ss.session_id = s.id JOIN speakers sp ON sp.id = ss.speaker_id WHERE sp.name ILIKE " + name + ")" +
+			" OR id IN (SELECT event_id FROM event_sessions WHERE speaker ILIKE " + name + "))"
+	}
+	if search.Near != nil {
+		// Haversine distance in km. The bounding box lets the index skip far away events.
+		latitude, longitude := f.arg(search.Near.Latitude), f.arg(search.Near.Longitude)
+		f.distance = fmt.Sprintf("%g * 2 * asin(least(1, sqrt(power(sin(radians(latitude - %[2]s) / 2), 2) + cos(radians(%[2]s)) * cos(radians(latitude)) * power(sin(radians(longitude - %[3]s) / 2), 2))))",
+			geo.EarthRadiusKm, latitude, longitude)
+
+		minLat, maxLat, minLng, maxLng, wrapsLongitude := geo.BoundingBox(*search.Near, search.RadiusKm)
+		f.where += " AND latitude BETWEEN " + f.arg(minLat) + " AND " + f.arg(maxLat)
+		if !wrapsLongitude {
+			f.where += " AND longitude BETWEEN " + f.arg(minLng) + " AND " + f.arg(maxLng)
+		}
+		f.where += " AND " + f.distance + " <= " + f.arg(search.RadiusKm)
+	}
+	return f
+}
 
-	if startDate != "" {
-		query += fmt.Sprintf(" AND dateTime >= $%d", argId)
-		args = append(args, startDate)
-		argId++
+func (r *sqliteEventRepository) SearchEvents(ctx context.Context, search model.EventSearch) ([]model.EventSearchResult, error) {
+	f := newEventFilter(search)
+	columns := eventColumns
+	orderBy := " ORDER BY dateTime"
+	if f.tsQuery != "" {
+		columns += ", ts_rank_cd(search_vector, q) AS rank, ts_headline('english', name, q, " + f.arg(nameHeadlineOptions) + "), ts_headline('english', description, q, " + f.arg(snippetHeadlineOptions) + ")"
+		orderBy = " ORDER BY rank DESC, dateTime"
+	}
+	if f.distance != "" {
+		columns += ", " + f.distance + " AS distance"
+		if f.tsQuery != "" {
+			orderBy = " ORDER BY distance, rank DESC, dateTime"
+		} else {
+			orderBy = " ORDER BY distance, dateTime"
+		}
 	}
-	if endDate != "" {
-		query += fmt.Sprintf(" AND dateTime <= $%d", argId)
-		args = append(args, endDate)
-		argId++
+
+	query := f.with + " SELECT " + columns + f.from + f.where + orderBy
+	slog.DebugContext(ctx, "searching events", "query", query, "args", f.args)
+	rows, err := r.db.QueryContext(ctx, query, f.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search events: %w", err)
 	}
+	defer rows.Close()
 
-	log.Printf("Executing query: %s with args: %v", query, args)
+	results := make([]model.EventSearchResult, 0)
+	for rows.Next() {
+		var result model.EventSearchResult
+		event := &result.Event
+		dest := eventFields(event)
+		var name, description string
+		var distance float64
+		if f.tsQuery != "" {
+			dest = append(dest, &result.Rank, &name, &description)
+		}
+		if f.distance != "" {
+			dest = append(dest, &distance)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan event row: %w", err)
+		}
+		if f.tsQuery != "" {
+			result.Highlights = &model.EventHighlights{Name: highlight(name), Description: highlight(description)}
+		}
+		if f.distance != "" {
+			distance = math.Round(distance*100) / 100
+			result.DistanceKm = &distance
+		}
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event rows: %w", err)
+	}
+	return results, nil
+}
+
+func (r *sqliteEventRepository) GetVenueConflicts(ctx context.Context, venueID uuid.UUID, start, end time.Time, excludeID uuid.UUID) ([]uuid.UUID, error) {
+	query := "SELECT id FROM events WHERE venue_id = $1 AND id <> $2 AND dateTime < $3 AND end_time > $4 ORDER BY dateTime"
+	return r.eventIDs(ctx, query, venueID, excludeID, end, start)
+}
+
+func (r *sqliteEventRepository) GetVenueEventsOverCapacity(ctx context.Context, venueID uuid.UUID, capacity int) ([]uuid.UUID, error) {
+	query := "SELECT id FROM events WHERE venue_id = $1 AND dateTime >= NOW() AND (capacity IS NULL OR capacity = 0 OR capacity > $2) ORDER BY dateTime"
+	return r.eventIDs(ctx, query, venueID, capacity)
+}
+
+func (r *sqliteEventRepository) eventIDs(ctx context.Context, query string, args ...interface{}) ([]uuid.UUID, error) {
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Printf("Error executing search query: %v", err)
-		return nil, fmt.Errorf("failed to query events by criteria: %w", err)
+		return nil, fmt.Errorf("failed to query events: %w", err)
 	}
 	defer rows.Close()
 
-	var events []model.Event
-	log.Println("Scanning search result rows...")
+	ids := make([]uuid.UUID, 0)
 	for rows.Next() {
-		var event model.Event
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan event ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event rows: %w", err)
+	}
+	return ids, nil
+}
+
+// maxFacetValues limits the values returned per facet.
+const maxFacetValues = 20
+
+func (r *sqliteEventRepository) GetEventFacets(ctx context.Context, search model.EventSearch) (*model.EventFacets, error) {
+	f := newEventFilter(search)
+	with := "WITH"
+	if f.with != "" {
+		with = f.with + ","
+	}
+	with += " filtered AS (SELECT events.id, category_id, location, dateTime" + f.from + f.where + ") "
+
+	facets := &model.EventFacets{}
+	if err := r.db.QueryRowContext(ctx, with+"SELECT count(*) FROM filtered", f.args...).Scan(&facets.Total); err != nil {
+		return nil, fmt.Errorf("failed to count events: %w", err)
+	}
 
-		err := rows.Scan(&event.Id, &event.Name, &event.Description, &event.Location, &event.Date, &event.UserIds, &event.Category, &event.AverageRating, &event.Capacity)
+	limit := fmt.Sprintf(" LIMIT %d", maxFacetValues)
+	queries := []struct {
+		dest  *[]model.FacetCount
+		query string
+	}{
+		{&facets.Categories, "SELECT c.slug, c.name, count(*) FROM filtered f JOIN categories c ON c.id = f.category_id GROUP BY c.slug, c.name ORDER BY 3 DESC, 2" + limit},
+		{&facets.Tags, "SELECT t.slug, t.name, count(*) FROM filtered f JOIN event_tags et ON et.event_id = f.id JOIN tags t ON t.id = et.tag_id GROUP BY t.slug, t.name ORDER BY 3 DESC, 2" + limit},
+		{&facets.Locations, "SELECT location, location, count(*) FROM filtered WHERE location <> '' GROUP BY location ORDER BY 3 DESC, 1" + limit},
+		{&facets.Months, "SELECT to_char(dateTime, 'YYYY-MM'), to_char(dateTime, 'FMMonth YYYY'), count(*) FROM filtered WHERE dateTime IS NOT NULL GROUP BY 1, 2 ORDER BY 1"},
+	}
+	for _, q := range queries {
+		counts, err := r.facetCounts(ctx, with+q.query, f.args)
 		if err != nil {
-			log.Printf("Error scanning search row: %v", err)
-			return nil, fmt.Errorf("failed to scan event row: %w", err)
+			return nil, err
 		}
-		log.Printf("Scanned search event: %+v", event)
-		events = append(events, event)
+		*q.dest = counts
 	}
+	return facets, nil
+}
 
+func (r *sqliteEventRepository) facetCounts(ctx context.Context, query string, args []interface{}) ([]model.FacetCount, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query facet counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make([]model.FacetCount, 0)
+	for rows.Next() {
+		var count model.FacetCount
+		if err := rows.Scan(&count.Value, &count.Label, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan facet count row: %w", err)
+		}
+		counts = append(counts, count)
+	}
 	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating search rows: %v", err)
-		return nil, fmt.Errorf("error iterating event rows: %w", err)
+		return nil, fmt.Errorf("error iterating facet count rows: %w", err)
 	}
-	log.Printf("Successfully retrieved %d events from search", len(events))
-	return events, nil
+	return counts, nil
+}
+
+var searchWord = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// prefixTSQuery turns free text into a tsquery matching events that contain every
+// word, each also as a prefix, so "conf berl" finds "Conference in Berlin". Only
+// letters and digits are kept, so user input can't inject tsquery operators.
+func prefixTSQuery(keyword string) string {
+	words := searchWord.FindAllString(strings.ToLower(keyword), -1)
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// highlight HTML-escapes a ts_headline snippet and marks the matched words.
+func highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, highlightStart, "<mark>")
+	return strings.ReplaceAll(snippet, highlightStop, "</mark>")
 }
 
 func (r *sqliteEventRepository) UpdateAverageRating(ctx context.Context, eventID uuid.UUID, avgRating float64) error {
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	// MigrationVersion returns the applied schema version from golang-migrate's
+	// schema_migrations table. ok is false if no migration has been applied.
+	MigrationVersion(ctx context.Context) (version uint, dirty bool, ok bool, err error)
+}
+
+type healthRepository struct {
+	db *sql.DB
+}
+
+func NewHealthRepository(db *sql.DB) HealthRepository {
+	return &healthRepository{db: db}
+}
+
+func (r *healthRepository) Ping(ctx context.Context) error {
+	if err := r.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
+func (r *healthRepository) MigrationVersion(ctx context.Context) (uint, bool, bool, error) {
+	var version int64
+	var dirty bool
+	err := r.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, false, nil
+	}
+	if err != nil {
+		return 0, false, false, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	return uint(version), dirty, true, nil
+}
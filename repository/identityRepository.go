@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+
+	"github.com/google/uuid"
+)
+
+type IdentityRepository interface {
+	CreateIdentity(ctx context.Context, identity *model.UserIdentity) error
+	GetIdentity(ctx context.Context, provider string, subject string) (*model.UserIdentity, error)
+	GetIdentitiesByUserID(ctx context.Context, userID uuid.UUID) ([]model.UserIdentity, error)
+	SaveAuthRequest(ctx context.Context, authRequest *model.OIDCAuthRequest) error
+	ConsumeAuthRequest(ctx context.Context, state string) (*model.OIDCAuthRequest, error)
+}
+
+type identityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+func (r *identityRepository) CreateIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	identity.Id = uuid.New()
+	query := `
+		INSERT INTO user_identities (id, user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, identity.Id, identity.UserID, identity.Provider, identity.Subject, identity.Email).Scan(&identity.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperrors.ErrAlreadyExists
+		}
+		return fmt.Errorf("failed to create user identity: %w", err)
+	}
+	return nil
+}
+
+func (r *identityRepository) GetIdentity(ctx context.Context, provider string, subject string) (*model.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+	var identity model.UserIdentity
+	err := r.db.QueryRowContext(ctx, query, provider, subject).Scan(&identity.Id, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user identity: %w", err)
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) GetIdentitiesByUserID(ctx context.Context, userID uuid.UUID) ([]model.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, COALESCE(email, ''), created_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := make([]model.UserIdentity, 0)
+	for rows.Next() {
+		var identity model.UserIdentity
+		if err := rows.Scan(&identity.Id, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user identities: %w", err)
+	}
+	return identities, nil
+}
+
+func (r *identityRepository) SaveAuthRequest(ctx context.Context, authRequest *model.OIDCAuthRequest) error {
+	// Opportunistically clean up abandoned login attempts.
+	_, err := r.db.ExecContext(ctx, "DELETE FROM oidc_auth_requests WHERE expires_at < NOW()")
+	if err != nil {
+		return fmt.Errorf("failed to delete expired auth requests: %w", err)
+	}
+
+	query := "INSERT INTO oidc_auth_requests (state, provider, code_verifier, nonce, expires_at) VALUES ($1, $2, $3, $4, $5)"
+	_, err = r.db.ExecContext(ctx, query, authRequest.State, authRequest.Provider, authRequest.CodeVerifier, authRequest.Nonce, authRequest.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save auth request: %w", err)
+	}
+	return nil
+}
+
+// ConsumeAuthRequest deletes and returns the unexpired auth request for state, so that each state is usable once.
+func (r *identityRepository) ConsumeAuthRequest(ctx context.Context, state string) (*model.OIDCAuthRequest, error) {
+	query := `
+		DELETE FROM oidc_auth_requests
+		WHERE state = $1 AND expires_at > NOW()
+		RETURNING state, provider, code_verifier, nonce, expires_at
+	`
+	var authRequest model.OIDCAuthRequest
+	err := r.db.QueryRowContext(ctx, query, state).Scan(&authRequest.State, &authRequest.Provider, &authRequest.CodeVerifier, &authRequest.Nonce, &authRequest.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to consume auth request: %w", err)
+	}
+	return &authRequest, nil
+}
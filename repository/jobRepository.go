@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-rest-api/model"
+	"sort"
+)
+
+type JobRepository interface {
+	Save(ctx context.Context, job *model.PendingJob) error
+	// TakeAll deletes and returns all pending jobs, oldest first. Each job is
+	// returned to exactly one caller even if several instances start together.
+	TakeAll(ctx context.Context) ([]model.PendingJob, error)
+}
+
+type jobRepository struct {
+	db *sql.DB
+}
+
+func NewJobRepository(db *sql.DB) JobRepository {
+	return &jobRepository{db: db}
+}
+
+func (r *jobRepository) Save(ctx context.Context, job *model.PendingJob) error {
+	query := `
+		INSERT INTO pending_jobs (job_type, payload, request_id)
+		VALUES ($1, $2, NULLIF($3, ''))
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, job.Type, []byte(job.Payload), job.RequestID).Scan(&job.Id, &job.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save pending job: %w", err)
+	}
+	return nil
+}
+
+func (r *jobRepository) TakeAll(ctx context.Context) ([]model.PendingJob, error) {
+	query := `
+		DELETE FROM pending_jobs
+		RETURNING id, job_type, payload, COALESCE(request_id, ''), created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take pending jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []model.PendingJob
+	for rows.Next() {
+		var job model.PendingJob
+		var payload []byte
+		if err := rows.Scan(&job.Id, &job.Type, &payload, &job.RequestID, &job.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan pending job: %w", err)
+		}
+		job.Payload = payload
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pending jobs: %w", err)
+	}
+
+	// DELETE ... RETURNING has no ORDER BY.
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/model"
+	"time"
+)
+
+type LoginThrottleRepository interface {
+	Get(ctx context.Context, scope string, key string) (*model.LoginThrottle, error)
+	RegisterFailure(ctx context.Context, scope string, key string, window time.Duration) (*model.LoginThrottle, error)
+	Lock(ctx context.Context, scope string, key string, until time.Time) error
+	Reset(ctx context.Context, scope string, key string) error
+	// DeleteExpired deletes the rows that are not locked and whose last failure is
+	// older than window, and returns how many it deleted.
+	DeleteExpired(ctx context.Context, window time.Duration) (int64, error)
+}
+
+type loginThrottleRepository struct {
+	db *sql.DB
+}
+
+func NewLoginThrottleRepository(db *sql.DB) LoginThrottleRepository {
+	return &loginThrottleRepository{db: db}
+}
+
+func (r *loginThrottleRepository) Get(ctx context.Context, scope string, key string) (*model.LoginThrottle, error) {
+	query := "SELECT scope, key, failed_count, last_failed_at, locked_until FROM login_throttles WHERE scope = $1 AND key = $2"
+	var throttle model.LoginThrottle
+	err := r.db.QueryRowContext(ctx, query, scope, key).Scan(&throttle.Scope, &throttle.Key, &throttle.FailedCount, &throttle.LastFailedAt, &throttle.LockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // No failed attempts recorded
+		}
+		return nil, fmt.Errorf("failed to get login throttle: %w", err)
+	}
+	return &throttle, nil
+}
+
+// RegisterFailure increments the failure counter, starting over when the previous
+// failure is older than window, and returns the updated state.
+func (r *loginThrottleRepository) RegisterFailure(ctx context.Context, scope string, key string, window time.Duration) (*model.LoginThrottle, error) {
+	query := `
+		INSERT INTO login_throttles (scope, key, failed_count, last_failed_at)
+		VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failed_count = CASE
+				WHEN login_throttles.last_failed_at IS NULL OR login_throttles.last_failed_at < NOW() - make_interval(secs => $3) THEN 1
+				ELSE login_throttles.failed_count + 1
+			END,
+			last_failed_at = NOW()
+		RETURNING scope, key, failed_count, last_failed_at, locked_until
+	`
+	var throttle model.LoginThrottle
+	err := r.db.QueryRowContext(ctx, query, scope, key, window.Seconds()).Scan(&throttle.Scope, &throttle.Key, &throttle.FailedCount, &throttle.LastFailedAt, &throttle.LockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register login failure: %w", err)
+	}
+	return &throttle, nil
+}
+
+// Lock locks the key until the given time and clears its failure counter.
+func (r *loginThrottleRepository) Lock(ctx context.Context, scope string, key string, until time.Time) error {
+	query := "UPDATE login_throttles SET locked_until = $1, failed_count = 0 WHERE scope = $2 AND key = $3"
+	_, err := r.db.ExecContext(ctx, query, until, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to lock login throttle: %w", err)
+	}
+	return nil
+}
+
+func (r *loginThrottleRepository) Reset(ctx context.Context, scope string, key string) error {
+	query := "DELETE FROM login_throttles WHERE scope = $1 AND key = $2"
+	_, err := r.db.ExecContext(ctx, query, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+	return nil
+}
+
+func (r *loginThrottleRepository) DeleteExpired(ctx context.Context, window time.Duration) (int64, error) {
+	query := `
+		DELETE FROM login_throttles
+		WHERE (locked_until IS NULL OR locked_until < NOW())
+		  AND (last_failed_at IS NULL OR last_failed_at < NOW() - make_interval(secs => $1))
+	`
+	result, err := r.db.ExecContext(ctx, query, window.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired login throttles: %w", err)
+	}
+	return result.RowsAffected()
+}
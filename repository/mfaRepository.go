@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MFARepository interface {
+	GetUserMFA(ctx context.Context, userID uuid.UUID) (*model.UserMFA, error)
+	SetPendingSecret(ctx context.Context, userID uuid.UUID, secret string) error
+	Enable(ctx context.Context, userID uuid.UUID, usedStep int64) error
+	Disable(ctx context.Context, userID uuid.UUID) error
+	MarkStepUsed(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	UseToken(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error)
+	GetPolicies(ctx context.Context) ([]model.MFAPolicy, error)
+	IsRequiredForRole(ctx context.Context, role string) (bool, error)
+	SetPolicy(ctx context.Context, policy *model.MFAPolicy) error
+}
+
+type mfaRepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) GetUserMFA(ctx context.Context, userID uuid.UUID) (*model.UserMFA, error) {
+	query := "SELECT totp_secret, totp_enabled, totp_last_used_step FROM users WHERE id = $1"
+	var mfa model.UserMFA
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&mfa.TOTPSecret, &mfa.TOTPEnabled, &mfa.TOTPLastUsedStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get two-factor state: %w", err)
+	}
+	return &mfa, nil
+}
+
+// SetPendingSecret stores a secret that becomes active once Enable is called.
+func (r *mfaRepository) SetPendingSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	query := "UPDATE users SET totp_secret = $1, totp_enabled = false, totp_last_used_step = NULL WHERE id = $2"
+	_, err := r.db.ExecContext(ctx, query, secret, userID)
+	if err != nil {
+		return fmt.Errorf("failed to store two-factor secret: %w", err)
+	}
+	return nil
+}
+
+func (r *mfaRepository) Enable(ctx context.Context, userID uuid.UUID, usedStep int64) error {
+	query := "UPDATE users SET totp_enabled = true, totp_last_used_step = $1 WHERE id = $2 AND totp_secret IS NOT NULL"
+	_, err := r.db.ExecContext(ctx, query, usedStep, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	return nil
+}
+
+func (r *mfaRepository) Disable(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET totp_secret = NULL, totp_enabled = false, totp_last_used_step = NULL WHERE id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// MarkStepUsed records step as used, returning false if it (or a later step) was already used.
+func (r *mfaRepository) MarkStepUsed(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	query := "UPDATE users SET totp_last_used_step = $1 WHERE id = $2 AND (totp_last_used_step IS NULL OR totp_last_used_step < $1)"
+	result, err := r.db.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record used two-factor code: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, codeHash := range codeHashes {
+		_, err = tx.ExecContext(ctx, "INSERT INTO user_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)", uuid.New(), userID, codeHash)
+		if err != nil {
+			return fmt.Errorf("failed to insert recovery code: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseToken records the mfa_token with ID jti as used, returning false if it already
+// was. Tokens that have expired are forgotten.
+func (r *mfaRepository) UseToken(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM used_mfa_tokens WHERE expires_at < NOW()")
+	if err != nil {
+		return false, fmt.Errorf("failed to delete expired mfa tokens: %w", err)
+	}
+	query := "INSERT INTO used_mfa_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING"
+	result, err := r.db.ExecContext(ctx, query, jti, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to record used mfa token: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used and reports whether one matched.
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	query := "UPDATE user_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL"
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *mfaRepository) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL", userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+func (r *mfaRepository) GetPolicies(ctx context.Context) ([]model.MFAPolicy, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT role, required FROM mfa_policies ORDER BY role")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query two-factor policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := make([]model.MFAPolicy, 0)
+	for rows.Next() {
+		var policy model.MFAPolicy
+		if err := rows.Scan(&policy.Role, &policy.Required); err != nil {
+			return nil, fmt.Errorf("failed to scan two-factor policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating two-factor policies: %w", err)
+	}
+	return policies, nil
+}
+
+func (r *mfaRepository) IsRequiredForRole(ctx context.Context, role string) (bool, error) {
+	var required bool
+	err := r.db.QueryRowContext(ctx, "SELECT required FROM mfa_policies WHERE role = $1", role).Scan(&required)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get two-factor policy: %w", err)
+	}
+	return required, nil
+}
+
+func (r *mfaRepository) SetPolicy(ctx context.Context, policy *model.MFAPolicy) error {
+	query := `
+		INSERT INTO mfa_policies (role, required, updated_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (role) DO UPDATE SET required = EXCLUDED.required, updated_at = NOW()
+	`
+	_, err := r.db.ExecContext(ctx, query, policy.Role, policy.Required)
+	if err != nil {
+		return fmt.Errorf("failed to set two-factor policy: %w", err)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"go-rest-api/apperrors"
 	"go-rest-api/model"
 
 	"github.com/google/uuid"
@@ -32,6 +33,9 @@ func (r *sqliteReviewRepository) SaveReview(ctx context.Context, review *model.R
 	`
 	err := r.db.QueryRowContext(ctx, query, review.Id, review.EventID, review.UserID, review.Rating, review.Comment).Scan(&review.CreatedAt)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return apperrors.ErrAlreadyExists
+		}
 		return fmt.Errorf("failed to execute statement for save review: %w", err)
 	}
 	return nil
@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *model.Session) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Session, error)
+	// GetByEvent returns the event's sessions by start time.
+	GetByEvent(ctx context.Context, eventID uuid.UUID) ([]model.Session, error)
+	// GetByUser returns the sessions the user registered for, by start time.
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]model.Session, error)
+	// GetOverlapping returns the sessions the user registered for, other than
+	// excludeID, that overlap the time from start to end.
+	GetOverlapping(ctx context.Context, userID uuid.UUID, start, end time.Time, excludeID uuid.UUID) ([]model.Session, error)
+	Update(ctx context.Context, session *model.Session) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Register registers the user for the session. It returns apperrors.ErrNotFound
+	// if the user isn't registered for the session's event, and apperrors.ErrConflict
+	// if the session is full or overlaps another of the user's sessions.
+	Register(ctx context.Context, session *model.Session, userID uuid.UUID) error
+	CancelRegistration(ctx context.Context, sessionID, userID uuid.UUID) error
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// sessionColumns are the event_sessions columns read into a model.Session, in the
+// order of sessionFields. The speakers are a JSON array of IDs. Queries using it
+// must not alias the event_sessions table.
+var sessionColumns = "id, event_id, title, track, speaker, room, start_time, end_time, capacity, created_at, " +
+	"(SELECT count(*) FROM session_registrations sr WHERE sr.session_id = event_sessions.id), " +
+	speakerIDsColumn("session_speakers", "session_id", "event_sessions")
+
+// sessionFields returns the scan destinations for sessionColumns.
+func sessionFields(session *model.Session) []interface{} {
+	return []interface{}{&session.Id, &session.EventID, &session.Title, &session.Track, &session.Speaker, &session.Room,
+		&session.StartTime, &session.EndTime, &session.Capacity, &session.CreatedAt, &session.Registered, jsonColumn{&session.SpeakerIDs}}
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *model.Session) error {
+	session.Id = uuid.New()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO event_sessions (id, event_id, title, track, speaker, room, start_time, end_time, capacity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at
+	`
+	err = tx.QueryRowContext(ctx, query, session.Id, session.EventID, session.Title, session.Track, session.Speaker,
+		session.Room, session.StartTime, session.EndTime, session.Capacity).Scan(&session.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	if len(session.SpeakerIDs) > 0 {
+		if err := setSpeakers(ctx, tx, "session_speakers", "session_id", session.Id, session.SpeakerIDs); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *sessionRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Session, error) {
+	var session model.Session
+	err := r.db.QueryRowContext(ctx, "SELECT "+sessionColumns+" FROM event_sessions WHERE id = $1", id).Scan(sessionFields(&session)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) GetByEvent(ctx context.Context, eventID uuid.UUID) ([]model.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM event_sessions WHERE event_id = $1 ORDER BY start_time, track NULLS FIRST, title"
+	return r.sessions(ctx, query, eventID)
+}
+
+func (r *sessionRepository) GetByUser(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM event_sessions WHERE id IN (SELECT session_id FROM session_registrations WHERE user_id = $1) ORDER BY start_time"
+	return r.sessions(ctx, query, userID)
+}
+
+func (r *sessionRepository) GetOverlapping(ctx context.Context, userID uuid.UUID, start, end time.Time, excludeID uuid.UUID) ([]model.Session, error) {
+	query := "SELECT " + sessionColumns + ` FROM event_sessions
+		WHERE id IN (SELECT session_id FROM session_registrations WHERE user_id = $1)
+		AND id <> $2 AND start_time < $3 AND end_time > $4
+		ORDER BY start_time`
+	return r.sessions(ctx, query, userID, excludeID, end, start)
+}
+
+func (r *sessionRepository) sessions(ctx context.Context, query string, args ...interface{}) ([]model.Session, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]model.Session, 0)
+	for rows.Next() {
+		var session model.Session
+		if err := rows.Scan(sessionFields(&session)...); err != nil {
+			return nil, fmt.Errorf("failed to scan session row: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating session rows: %w", err)
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepository) Update(ctx context.Context, session *model.Session) error {
+	query := `
+		UPDATE event_sessions SET title = $1, track = $2, speaker = $3, room = $4, start_time = $5, end_time = $6, capacity = $7
+		WHERE id = $8
+	`
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, session.Title, session.Track, session.Speaker, session.Room,
+		session.StartTime, session.EndTime, session.Capacity, session.Id)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	// nil keeps the speakers
+	if session.SpeakerIDs != nil {
+		if err := setSpeakers(ctx, tx, "session_speakers", "session_id", session.Id, session.SpeakerIDs); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *sessionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM event_sessions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
+
+func (r *sessionRepository) Register(ctx context.Context, session *model.Session, userID uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Take a lock per user, so their concurrent picks, in any event, are checked for
+	// overlaps one at a time. It is released when the transaction ends.
+	_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtextextended('session_registrations:' || $1::text, 0))", userID)
+	if err != nil {
+		return fmt.Errorf("failed to lock session registrations: %w", err)
+	}
+
+	// Keep the event registration from being cancelled meanwhile, and lock the
+	// session so it isn't overbooked.
+	var lockedID uuid.UUID
+	err = tx.QueryRowContext(ctx, "SELECT id FROM registrations WHERE event_id = $1 AND user_id = $2 FOR SHARE", session.EventID, userID).Scan(&lockedID)
+	if err == nil {
+		err = tx.QueryRowContext(ctx, "SELECT id FROM event_sessions WHERE id = $1 FOR UPDATE", session.Id).Scan(&lockedID)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.ErrNotFound
+		}
+		return fmt.Errorf("failed to lock session registration: %w", err)
+	}
+
+	query := `
+		INSERT INTO session_registrations (session_id, event_id, user_id)
+		SELECT s.id, s.event_id, $2 FROM event_sessions s
+		WHERE s.id = $1
+		AND (s.capacity IS NULL OR (SELECT count(*) FROM session_registrations WHERE session_id = s.id) < s.capacity)
+		AND NOT EXISTS (
+			SELECT 1 FROM session_registrations sr JOIN event_sessions o ON o.id = sr.session_id
+			WHERE sr.user_id = $2 AND o.id <> s.id AND o.start_time < s.end_time AND o.end_time > s.start_time
+		)
+	`
+	result, err := tx.ExecContext(ctx, query, session.Id, userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperrors.ErrAlreadyExists
+		}
+		return fmt.Errorf("failed to insert session registration: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrConflict
+	}
+	return tx.Commit()
+}
+
+func (r *sessionRepository) CancelRegistration(ctx context.Context, sessionID, userID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM session_registrations WHERE session_id = $1 AND user_id = $2", sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete session registration: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go-rest-api/model"
+)
+
+type SigningKeyRepository interface {
+	Save(ctx context.Context, key *model.SigningKeyRecord) error
+	GetUnexpired(ctx context.Context) ([]model.SigningKeyRecord, error)
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type signingKeyRepository struct {
+	db *sql.DB
+}
+
+func NewSigningKeyRepository(db *sql.DB) SigningKeyRepository {
+	return &signingKeyRepository{db: db}
+}
+
+func (r *signingKeyRepository) Save(ctx context.Context, key *model.SigningKeyRecord) error {
+	query := `
+		INSERT INTO signing_keys (kid, algorithm, private_key, activates_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, key.Kid, key.Algorithm, key.PrivateKey, key.ActivatesAt, key.ExpiresAt).Scan(&key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save signing key: %w", err)
+	}
+	return nil
+}
+
+func (r *signingKeyRepository) GetUnexpired(ctx context.Context) ([]model.SigningKeyRecord, error) {
+	query := `
+		SELECT kid, algorithm, private_key, activates_at, expires_at, created_at
+		FROM signing_keys
+		WHERE expires_at > NOW()
+		ORDER BY activates_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []model.SigningKeyRecord
+	for rows.Next() {
+		var key model.SigningKeyRecord
+		if err := rows.Scan(&key.Kid, &key.Algorithm, &key.PrivateKey, &key.ActivatesAt, &key.ExpiresAt, &key.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *signingKeyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM signing_keys WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired signing keys: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type SpeakerRepository interface {
+	Create(ctx context.Context, speaker *model.Speaker) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Speaker, error)
+	// GetByIDs returns the speakers with the given IDs that exist.
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Speaker, error)
+	// GetAll returns the speakers by name. A non-empty name only returns speakers
+	// whose name contains it, ignoring case.
+	GetAll(ctx context.Context, name string) ([]model.Speaker, error)
+	Update(ctx context.Context, speaker *model.Speaker) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type speakerRepository struct {
+	db *sql.DB
+}
+
+func NewSpeakerRepository(db *sql.DB) SpeakerRepository {
+	return &speakerRepository{db: db}
+}
+
+const speakerColumns = "id, name, bio, photo_url, links, user_id, created_at"
+
+func speakerFields(speaker *model.Speaker) []interface{} {
+	return []interface{}{&speaker.Id, &speaker.Name, &speaker.Bio, &speaker.PhotoURL, jsonColumn{&speaker.Links}, &speaker.UserID, &speaker.CreatedAt}
+}
+
+// speakerLinks returns the links as a JSON array for the links column.
+func speakerLinks(links []model.SpeakerLink) (string, error) {
+	if links == nil {
+		links = []model.SpeakerLink{}
+	}
+	data, err := json.Marshal(links)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode speaker links: %w", err)
+	}
+	return string(data), nil
+}
+
+func (r *speakerRepository) Create(ctx context.Context, speaker *model.Speaker) error {
+	links, err := speakerLinks(speaker.Links)
+	if err != nil {
+		return err
+	}
+	speaker.Id = uuid.New()
+	query := `
+		INSERT INTO speakers (id, name, bio, photo_url, links, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at
+	`
+	err = r.db.QueryRowContext(ctx, query, speaker.Id, speaker.Name, speaker.Bio, speaker.PhotoURL, links, speaker.UserID).Scan(&speaker.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create speaker: %w", err)
+	}
+	if speaker.Links == nil {
+		speaker.Links = []model.SpeakerLink{}
+	}
+	return nil
+}
+
+func (r *speakerRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Speaker, error) {
+	var speaker model.Speaker
+	err := r.db.QueryRowContext(ctx, "SELECT "+speakerColumns+" FROM speakers WHERE id = $1", id).Scan(speakerFields(&speaker)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get speaker: %w", err)
+	}
+	return &speaker, nil
+}
+
+func (r *speakerRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Speaker, error) {
+	return r.speakers(ctx, "SELECT "+speakerColumns+" FROM speakers WHERE id = ANY($1::uuid[]) ORDER BY name", uuidStrings(ids))
+}
+
+func (r *speakerRepository) GetAll(ctx context.Context, name string) ([]model.Speaker, error) {
+	if name == "" {
+		return r.speakers(ctx, "SELECT "+speakerColumns+" FROM speakers ORDER BY name")
+	}
+	return r.speakers(ctx, "SELECT "+speakerColumns+" FROM speakers WHERE name ILIKE $1 ORDER BY name", containsPattern(name))
+}
+
+func (r *speakerRepository) speakers(ctx context.Context, query string, args ...interface{}) ([]model.Speaker, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query speakers: %w", err)
+	}
+	defer rows.Close()
+
+	speakers := make([]model.Speaker, 0)
+	for rows.Next() {
+		var speaker model.Speaker
+		if err := rows.Scan(speakerFields(&speaker)...); err != nil {
+			return nil, fmt.Errorf("failed to scan speaker row: %w", err)
+		}
+		speakers = append(speakers, speaker)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating speaker rows: %w", err)
+	}
+	return speakers, nil
+}
+
+func (r *speakerRepository) Update(ctx context.Context, speaker *model.Speaker) error {
+	links, err := speakerLinks(speaker.Links)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE speakers SET name = $1, bio = $2, photo_url = $3, links = $4 WHERE id = $5"
+	result, err := r.db.ExecContext(ctx, query, speaker.Name, speaker.Bio, speaker.PhotoURL, links, speaker.Id)
+	if err != nil {
+		return fmt.Errorf("failed to update speaker: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes the speaker from its events and sessions and deletes it.
+func (r *speakerRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM speakers WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete speaker: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
+
+// setSpeakers replaces the speakers of the row id of an event or session. table is
+// event_speakers or session_speakers and column the one referencing the row.
+func setSpeakers(ctx context.Context, tx *sql.Tx, table, column string, id uuid.UUID, speakerIDs []uuid.UUID) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE "+column+" = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete speakers: %w", err)
+	}
+	if len(speakerIDs) == 0 {
+		return nil
+	}
+	insert := "INSERT INTO " + table + " (" + column + ", speaker_id) SELECT $1, unnest($2::uuid[]) ON CONFLICT DO NOTHING"
+	if _, err := tx.ExecContext(ctx, insert, id, uuidStrings(speakerIDs)); err != nil {
+		return fmt.Errorf("failed to add speakers: %w", err)
+	}
+	return nil
+}
+
+// speakerIDsColumn selects the IDs of the speakers of the row of owner, by name,
+// as a JSON array. The owner table must not be aliased.
+func speakerIDsColumn(table, column, owner string) string {
+	return "(SELECT COALESCE(json_agg(sp.id ORDER BY sp.name), '[]') FROM " + table + " x JOIN speakers sp ON sp.id = x.speaker_id WHERE x." + column + " = " + owner + ".id)"
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strs
+}
+
+// containsPattern returns an ILIKE pattern matching text containing s.
+func containsPattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"go-rest-api/apperrors"
 	"go-rest-api/model"
 	"go-rest-api/utils"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -19,6 +21,10 @@ type UserRepository interface {
 	Update(ctx context.Context, user *model.User) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	UpdateProfile(ctx context.Context, user *model.User) error
+	UpdatePassword(ctx context.Context, id uuid.UUID, password string) error
+	SetPendingEmail(ctx context.Context, id uuid.UUID, email string, tokenHash string, expiresAt time.Time) error
+	ConfirmPendingEmail(ctx context.Context, id uuid.UUID, tokenHash string) error
 }
 
 type userRepository struct {
@@ -38,13 +44,10 @@ func (s *userRepository) Create(ctx context.Context, u *model.User) error {
 	query := "INSERT INTO users (id, email, password, role) VALUES ($1, $2, $3, $4)"
 	_, err := s.db.ExecContext(ctx, query, u.Id, u.Email, utils.HashPassword(u.Password), u.Role)
 	if err != nil {
-		// This is a simplified check. In a real-world application, you'd want to check for the specific database error code.
-		if err.Error() == "pq: duplicate key value violates unique constraint \"users_email_key\"" {
+		if isUniqueViolation(err) {
 			return apperrors.ErrAlreadyExists
 		}
-		if errors.Is(err, sql.ErrNoRows) {
-			return apperrors.ErrInternalServer
-		}
+		return fmt.Errorf("failed to create user: %w", err)
 	}
 	return nil
 }
@@ -73,11 +76,11 @@ func (s *userRepository) GetAll(ctx context.Context) ([]model.User, error) {
 }
 
 func (s *userRepository) GetById(ctx context.Context, id uuid.UUID) (*model.User, error) {
-	query := "SELECT id, email, role FROM users WHERE id = $1"
+	query := "SELECT id, email, role, display_name, avatar_url, preferences, pending_email FROM users WHERE id = $1"
 	row := s.db.QueryRowContext(ctx, query, id)
 
 	var user model.User
-	err := row.Scan(&user.Id, &user.Email, &user.Role)
+	err := row.Scan(&user.Id, &user.Email, &user.Role, &user.DisplayName, &user.AvatarURL, &user.Preferences, &user.PendingEmail)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, apperrors.ErrNotFound
@@ -122,8 +125,7 @@ func (s *userRepository) Update(ctx context.Context, u *model.User) error {
 
 	result, err := stmt.ExecContext(ctx, args...)
 	if err != nil {
-		// This is a simplified check. In a real-world application, you'd want to check for the specific database error code.
-		if err.Error() == "pq: duplicate key value violates unique constraint \"users_email_key\"" {
+		if isUniqueViolation(err) {
 			return apperrors.ErrAlreadyExists
 		}
 		return err
@@ -183,3 +185,86 @@ func (s *userRepository) Validate(ctx context.Context, u *model.User) error {
 	u.Role = retrievedRole
 	return nil
 }
+
+func (s *userRepository) UpdateProfile(ctx context.Context, u *model.User) error {
+	query := "UPDATE users SET display_name = $1, avatar_url = $2, preferences = $3 WHERE id = $4"
+	result, err := s.db.ExecContext(ctx, query, u.DisplayName, u.AvatarURL, u.Preferences, u.Id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
+
+func (s *userRepository) UpdatePassword(ctx context.Context, id uuid.UUID, password string) error {
+	query := "UPDATE users SET password = $1 WHERE id = $2"
+	result, err := s.db.ExecContext(ctx, query, utils.HashPassword(password), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
+
+func (s *userRepository) SetPendingEmail(ctx context.Context, id uuid.UUID, email string, tokenHash string, expiresAt time.Time) error {
+	query := "UPDATE users SET pending_email = $1, email_verification_token = $2, email_verification_expires_at = $3 WHERE id = $4"
+	result, err := s.db.ExecContext(ctx, query, email, tokenHash, expiresAt, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
+
+// ConfirmPendingEmail swaps in the pending email when the token hash matches and has not expired.
+// It returns apperrors.ErrNotFound when there is no matching, unexpired request.
+func (s *userRepository) ConfirmPendingEmail(ctx context.Context, id uuid.UUID, tokenHash string) error {
+	query := `
+		UPDATE users
+		SET email = pending_email,
+			pending_email = NULL,
+			email_verification_token = NULL,
+			email_verification_expires_at = NULL
+		WHERE id = $1
+			AND pending_email IS NOT NULL
+			AND email_verification_token = $2
+			AND email_verification_expires_at > NOW()
+	`
+	result, err := s.db.ExecContext(ctx, query, id, tokenHash)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperrors.ErrAlreadyExists
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
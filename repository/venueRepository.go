@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+
+	"github.com/google/uuid"
+)
+
+type VenueRepository interface {
+	Create(ctx context.Context, venue *model.Venue) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Venue, error)
+	GetAll(ctx context.Context) ([]model.Venue, error)
+	Update(ctx context.Context, venue *model.Venue) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type venueRepository struct {
+	db *sql.DB
+}
+
+func NewVenueRepository(db *sql.DB) VenueRepository {
+	return &venueRepository{db: db}
+}
+
+const venueColumns = "id, name, address, latitude, longitude, capacity, accessibility, time_zone, user_id, created_at"
+
+func venueFields(venue *model.Venue) []interface{} {
+	return []interface{}{&venue.Id, &venue.Name, &venue.Address, &venue.Latitude, &venue.Longitude, &venue.Capacity, &venue.Accessibility, &venue.TimeZone, &venue.UserID, &venue.CreatedAt}
+}
+
+func (r *venueRepository) Create(ctx context.Context, venue *model.Venue) error {
+	venue.Id = uuid.New()
+	query := `
+		INSERT INTO venues (id, name, address, latitude, longitude, capacity, accessibility, time_zone, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at
+	`
+	err := r.db.QueryRowContext(ctx, query, venue.Id, venue.Name, venue.Address, venue.Latitude, venue.Longitude,
+		venue.Capacity, venue.Accessibility, venue.TimeZone, venue.UserID).Scan(&venue.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create venue: %w", err)
+	}
+	return nil
+}
+
+func (r *venueRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Venue, error) {
+	var venue model.Venue
+	err := r.db.QueryRowContext(ctx, "SELECT "+venueColumns+" FROM venues WHERE id = $1", id).Scan(venueFields(&venue)...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get venue: %w", err)
+	}
+	return &venue, nil
+}
+
+func (r *venueRepository) GetAll(ctx context.Context) ([]model.Venue, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+venueColumns+" FROM venues ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query venues: %w", err)
+	}
+	defer rows.Close()
+
+	venues := make([]model.Venue, 0)
+	for rows.Next() {
+		var venue model.Venue
+		if err := rows.Scan(venueFields(&venue)...); err != nil {
+			return nil, fmt.Errorf("failed to scan venue row: %w", err)
+		}
+		venues = append(venues, venue)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating venue rows: %w", err)
+	}
+	return venues, nil
+}
+
+func (r *venueRepository) Update(ctx context.Context, venue *model.Venue) error {
+	query := `
+		UPDATE venues SET name = $1, address = $2, latitude = $3, longitude = $4, capacity = $5, accessibility = $6, time_zone = $7
+		WHERE id = $8
+	`
+	result, err := r.db.ExecContext(ctx, query, venue.Name, venue.Address, venue.Latitude, venue.Longitude,
+		venue.Capacity, venue.Accessibility, venue.TimeZone, venue.Id)
+	if err != nil {
+		return fmt.Errorf("failed to update venue: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes the venue. Its events stay, without a venue.
+func (r *venueRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM venues WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete venue: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"go-rest-api/apperrors"
 	"go-rest-api/model"
 	"time"
 
@@ -40,6 +41,9 @@ func (r *sqliteWaitlistRepository) AddUserToWaitlist(ctx context.Context, eventI
 	now := time.Now()
 	err := r.db.QueryRowContext(ctx, query, entry.Id, eventID, userID, now).Scan(&entry.CreatedAt)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, apperrors.ErrAlreadyExists
+		}
 		return nil, fmt.Errorf("failed to add user to waitlist: %w", err)
 	}
 	return entry, nil
@@ -56,7 +60,7 @@ func (r *sqliteWaitlistRepository) RemoveUserFromWaitlist(ctx context.Context, e
 		return fmt.Errorf("failed to get rows affected after removing from waitlist: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found on waitlist or already removed") // Or sql.ErrNoRows if preferred
+		return apperrors.ErrNotFound
 	}
 	return nil
 }
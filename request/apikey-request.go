@@ -0,0 +1,20 @@
+package request
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreateAPIKeyRequest struct {
+	Name               string     `json:"name" binding:"required,max=100"`
+	Scopes             []string   `json:"scopes" binding:"required,min=1,dive,oneof=read write admin"`
+	RateLimitPerMinute int        `json:"rate_limit_per_minute" binding:"omitempty,min=1,max=10000"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+}
+
+type CreateOrganizationAPIKeyRequest struct {
+	CreateAPIKeyRequest
+	Organization string    `json:"organization" binding:"required,max=100"`
+	UserID       uuid.UUID `json:"user_id" binding:"required"`
+}
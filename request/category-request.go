@@ -0,0 +1,11 @@
+package request
+
+import "github.com/google/uuid"
+
+// CategoryRequest creates or replaces a category. The slug is derived from the
+// name if it is empty.
+type CategoryRequest struct {
+	Name     string     `json:"name" binding:"required,max=100"`
+	Slug     string     `json:"slug" binding:"omitempty,max=100"`
+	ParentID *uuid.UUID `json:"parent_id"`
+}
@@ -0,0 +1,18 @@
+package request
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreateSessionRequest struct {
+	Title      string      `json:"title" binding:"required,max=200"`
+	Track      *string     `json:"track" binding:"omitempty,max=100"`
+	Speaker    *string     `json:"speaker" binding:"omitempty,max=200"`
+	Room       *string     `json:"room" binding:"omitempty,max=100"`
+	StartTime  time.Time   `json:"start_time" binding:"required"`
+	EndTime    time.Time   `json:"end_time" binding:"required"`
+	Capacity   *int        `json:"capacity" binding:"omitempty,gte=1"`
+	SpeakerIDs []uuid.UUID `json:"speaker_ids" binding:"omitempty,max=20"`
+}
@@ -0,0 +1,10 @@
+package request
+
+import "go-rest-api/model"
+
+type CreateSpeakerRequest struct {
+	Name     string              `json:"name" binding:"required,max=200"`
+	Bio      *string             `json:"bio" binding:"omitempty,max=5000"`
+	PhotoURL *string             `json:"photo_url" binding:"omitempty,http_url,max=2048"`
+	Links    []model.SpeakerLink `json:"links" binding:"omitempty,max=10,dive"`
+}
@@ -0,0 +1,30 @@
+package request
+
+import "go-rest-api/model"
+
+type UpdateProfileRequest struct {
+	DisplayName *string               `json:"display_name" binding:"omitempty,max=100"`
+	AvatarURL   *string               `json:"avatar_url" binding:"omitempty,url,max=2048"`
+	Preferences model.UserPreferences `json:"preferences"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	// Password may be left out by users with a linked identity who just signed in with it
+	Password string `json:"password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	// CurrentPassword may be left out by users with a linked identity who just signed in with it
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type DeleteAccountRequest struct {
+	// Password may be left out by users with a linked identity who just signed in with it
+	Password string `json:"password"`
+}
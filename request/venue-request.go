@@ -0,0 +1,11 @@
+package request
+
+type CreateVenueRequest struct {
+	Name          string   `json:"name" binding:"required,max=200"`
+	Address       string   `json:"address" binding:"required,max=500"`
+	Latitude      *float64 `json:"latitude" binding:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude     *float64 `json:"longitude" binding:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+	Capacity      *int     `json:"capacity" binding:"omitempty,gte=1"`
+	Accessibility *string  `json:"accessibility" binding:"omitempty,max=2000"`
+	TimeZone      string   `json:"time_zone" binding:"omitempty,timezone"`
+}
@@ -1,9 +1,23 @@
 package response
 
-import "github.com/google/uuid"
+import (
+	"go-rest-api/model"
+
+	"github.com/google/uuid"
+)
 
 type UserResponse struct {
 	Id    uuid.UUID `json:"id"`
 	Email string    `json:"email"`
 	Role  string    `json:"role"`
 }
+
+type ProfileResponse struct {
+	Id           uuid.UUID             `json:"id"`
+	Email        string                `json:"email"`
+	Role         string                `json:"role"`
+	DisplayName  *string               `json:"display_name,omitempty"`
+	AvatarURL    *string               `json:"avatar_url,omitempty"`
+	Preferences  model.UserPreferences `json:"preferences"`
+	PendingEmail *string               `json:"pending_email,omitempty"`
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/utils"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyPrefix starts every API key so it can be told apart from a JWT.
+const APIKeyPrefix = "ebk_"
+
+const defaultAPIKeyRateLimit = 60
+
+var ErrInvalidAPIKey = apperrors.Unauthorized("invalid_api_key", "invalid API key")
+var ErrInvalidAPIKeyScope = apperrors.BadRequest("invalid_api_key_scope", "scopes must be read, write or admin")
+var ErrAPIKeyAdminScope = apperrors.Forbidden("api_key_admin_scope", "only admins can create keys with the admin scope")
+var ErrAPIKeyNotFound = apperrors.NotFound("api_key_not_found", "API key not found")
+
+type APIKeyService interface {
+	// Create stores a new key and returns the plaintext secret, which is not kept.
+	Create(ctx context.Context, key *model.APIKey) (string, error)
+	GetUserKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error)
+	GetOrganizationKeys(ctx context.Context, organization string) ([]model.APIKey, error)
+	RevokeUserKey(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	RevokeOrganizationKey(ctx context.Context, id uuid.UUID) error
+	Authenticate(ctx context.Context, rawKey string) (*model.APIKey, error)
+}
+
+type apiKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+	userRepo   repository.UserRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository, userRepo repository.UserRepository) APIKeyService {
+	return &apiKeyService{apiKeyRepo: apiKeyRepo, userRepo: userRepo}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, key *model.APIKey) (string, error) {
+	ctx, span := tracer.Start(ctx, "ApiKeyService.Create")
+	defer span.End()
+
+	if len(key.Scopes) == 0 {
+		return "", ErrInvalidAPIKeyScope
+	}
+	for _, scope := range key.Scopes {
+		if scope != model.APIKeyScopeRead && scope != model.APIKeyScopeWrite && scope != model.APIKeyScopeAdmin {
+			return "", ErrInvalidAPIKeyScope
+		}
+	}
+	if key.RateLimitPerMinute == 0 {
+		key.RateLimitPerMinute = defaultAPIKeyRateLimit
+	}
+
+	user, err := s.userRepo.GetById(ctx, key.UserID)
+	if err != nil {
+		return "", userError(err)
+	}
+	if key.HasScope(model.APIKeyScopeAdmin) && user.Role != "admin" {
+		return "", ErrAPIKeyAdminScope
+	}
+	key.UserRole = user.Role
+
+	prefixBytes := make([]byte, 4)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key.Prefix = hex.EncodeToString(prefixBytes)
+	key.KeyHash = utils.HashToken(secret)
+
+	err = s.apiKeyRepo.Create(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + key.Prefix + "_" + secret, nil
+}
+
+func (s *apiKeyService) GetUserKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	ctx, span := tracer.Start(ctx, "ApiKeyService.GetUserKeys")
+	defer span.End()
+
+	return s.apiKeyRepo.GetByUserID(ctx, userID)
+}
+
+func (s *apiKeyService) GetOrganizationKeys(ctx context.Context, organization string) ([]model.APIKey, error) {
+	ctx, span := tracer.Start(ctx, "ApiKeyService.GetOrganizationKeys")
+	defer span.End()
+
+	return s.apiKeyRepo.GetByOrganization(ctx, organization)
+}
+
+// RevokeUserKey revokes one of the user's own keys. Organization keys can only be revoked by admins.
+func (s *apiKeyService) RevokeUserKey(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "ApiKeyService.RevokeUserKey")
+	defer span.End()
+
+	key, err := s.getKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.UserID != userID || key.Organization != nil {
+		return ErrAPIKeyNotFound
+	}
+	return s.apiKeyRepo.Revoke(ctx, id)
+}
+
+func (s *apiKeyService) RevokeOrganizationKey(ctx context.Context, id uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "ApiKeyService.RevokeOrganizationKey")
+	defer span.End()
+
+	key, err := s.getKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.Organization == nil {
+		return ErrAPIKeyNotFound
+	}
+	return s.apiKeyRepo.Revoke(ctx, id)
+}
+
+func (s *apiKeyService) getKey(ctx context.Context, id uuid.UUID) (*model.APIKey, error) {
+	key, err := s.apiKeyRepo.GetByID(ctx, id)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// Authenticate checks a key of the form ebk_<prefix>_<secret> and records when it was used.
+// The key's rate limit is applied by the rate limit middleware.
+func (s *apiKeyService) Authenticate(ctx context.Context, rawKey string) (*model.APIKey, error) {
+	ctx, span := tracer.Start(ctx, "ApiKeyService.Authenticate")
+	defer span.End()
+
+	parts := strings.SplitN(strings.TrimPrefix(rawKey, APIKeyPrefix), "_", 2)
+	if !strings.HasPrefix(rawKey, APIKeyPrefix) || len(parts) != 2 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetByPrefix(ctx, parts[0])
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(parts[1])), []byte(key.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if err := s.apiKeyRepo.TouchLastUsed(ctx, key.Id); err != nil {
+		slog.WarnContext(ctx, "failed to update API key last used time", "api_key_id", key.Id, "error", err)
+	}
+	return key, nil
+}
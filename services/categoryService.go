@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/utils"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DefaultCategorySlug is the category of events created without one.
+const DefaultCategorySlug = "general"
+
+var ErrCategoryNotFound = apperrors.NotFound("category_not_found", "category not found")
+var ErrCategoryExists = apperrors.Conflict("category_exists", "a category with this slug already exists")
+var ErrCategoryInUse = apperrors.Conflict("category_in_use", "category still has events or subcategories")
+var ErrInvalidCategorySlug = apperrors.BadRequest("invalid_category_slug", "category slug must contain letters or digits")
+var ErrInvalidCategoryParent = apperrors.BadRequest("invalid_category_parent", "parent category doesn't exist or is the category itself or one of its subcategories")
+
+type CategoryService interface {
+	// GetCategories returns all categories with their number of upcoming events.
+	GetCategories(ctx context.Context) ([]model.CategoryCount, error)
+	CreateCategory(ctx context.Context, category *model.Category) error
+	UpdateCategory(ctx context.Context, category *model.Category) error
+	DeleteCategory(ctx context.Context, id uuid.UUID) error
+}
+
+type categoryService struct {
+	categoryRepository repository.CategoryRepository
+}
+
+func NewCategoryService(categoryRepository repository.CategoryRepository) CategoryService {
+	return &categoryService{categoryRepository: categoryRepository}
+}
+
+// findCategory looks up a category by slug, or by a name that slugifies to it, so
+// "Arts & Culture", "ARTS-CULTURE" and "arts-culture" find the same category.
+func findCategory(ctx context.Context, categoryRepo repository.CategoryRepository, slugOrName string) (*model.Category, error) {
+	slug := utils.Slugify(slugOrName)
+	if slug == "" {
+		return nil, ErrCategoryNotFound
+	}
+	category, err := categoryRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return category, nil
+}
+
+func (s *categoryService) GetCategories(ctx context.Context) ([]model.CategoryCount, error) {
+	ctx, span := tracer.Start(ctx, "CategoryService.GetCategories")
+	defer span.End()
+
+	return s.categoryRepository.GetAllWithCounts(ctx)
+}
+
+func (s *categoryService) CreateCategory(ctx context.Context, category *model.Category) error {
+	ctx, span := tracer.Start(ctx, "CategoryService.CreateCategory")
+	defer span.End()
+
+	if err := s.validate(ctx, category); err != nil {
+		return err
+	}
+	err := s.categoryRepository.Create(ctx, category)
+	if errors.Is(err, apperrors.ErrAlreadyExists) {
+		return ErrCategoryExists
+	}
+	return err
+}
+
+func (s *categoryService) UpdateCategory(ctx context.Context, category *model.Category) error {
+	ctx, span := tracer.Start(ctx, "CategoryService.UpdateCategory")
+	defer span.End()
+
+	if err := s.validate(ctx, category); err != nil {
+		return err
+	}
+	err := s.categoryRepository.Update(ctx, category)
+	switch {
+	case errors.Is(err, apperrors.ErrNotFound):
+		return ErrCategoryNotFound
+	case errors.Is(err, apperrors.ErrAlreadyExists):
+		return ErrCategoryExists
+	}
+	return err
+}
+
+func (s *categoryService) DeleteCategory(ctx context.Context, id uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "CategoryService.DeleteCategory")
+	defer span.End()
+
+	err := s.categoryRepository.Delete(ctx, id)
+	switch {
+	case errors.Is(err, apperrors.ErrNotFound):
+		return ErrCategoryNotFound
+	case errors.Is(err, apperrors.ErrConflict):
+		return ErrCategoryInUse
+	}
+	return err
+}
+
+// validate normalizes the slug, derived from the name if empty, and checks that
+// the parent exists and moving the category under it doesn't create a cycle.
+func (s *categoryService) validate(ctx context.Context, category *model.Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Slug == "" {
+		category.Slug = category.Name
+	}
+	category.Slug = utils.Slugify(category.Slug)
+	if category.Slug == "" {
+		return ErrInvalidCategorySlug
+	}
+
+	if category.ParentId == nil {
+		return nil
+	}
+	if _, err := s.categoryRepository.GetByID(ctx, *category.ParentId); err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return ErrInvalidCategoryParent
+		}
+		return err
+	}
+	if category.Id != uuid.Nil {
+		cycle, err := s.categoryRepository.IsInTree(ctx, category.Id, *category.ParentId)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrInvalidCategoryParent
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailSender delivers transactional emails such as address verification links.
+type EmailSender interface {
+	SendEmailVerification(ctx context.Context, email string, token string) error
+}
+
+type logEmailSender struct{}
+
+// NewLogEmailSender returns an EmailSender that only logs messages.
+// It is meant for local development, where no mail server is configured.
+func NewLogEmailSender() EmailSender {
+	return &logEmailSender{}
+}
+
+func (s *logEmailSender) SendEmailVerification(ctx context.Context, email string, token string) error {
+	ctx, span := tracer.Start(ctx, "LogEmailSender.SendEmailVerification")
+	defer span.End()
+
+	slog.InfoContext(ctx, "email verification: use the token with POST /me/email/verify", "email", email, "token", token)
+	return nil
+}
+
+// smtpTimeout bounds sending a message when the context has no deadline.
+const smtpTimeout = 30 * time.Second
+
+type smtpEmailSender struct {
+	addr string
+	host string
+	auth smtp.Auth
+	// from is the From header, envelopeFrom only its address
+	from         string
+	envelopeFrom string
+}
+
+// NewSMTPEmailSender returns an EmailSender that sends mail through the SMTP server
+// at host:port, from the address from, such as "Event Booking <no-reply@example.com>".
+// The connection is upgraded with STARTTLS when
+// the server offers it. Without a username no authentication is attempted.
+func NewSMTPEmailSender(host string, port int, username, password, from string) EmailSender {
+	s := &smtpEmailSender{addr: net.JoinHostPort(host, strconv.Itoa(port)), host: host, from: from, envelopeFrom: from}
+	if address, err := mail.ParseAddress(from); err == nil {
+		s.envelopeFrom = address.Address
+	}
+	if username != "" {
+		// PlainAuth refuses to send the password over an unencrypted connection,
+		// except to localhost.
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *smtpEmailSender) SendEmailVerification(ctx context.Context, email string, token string) error {
+	ctx, span := tracer.Start(ctx, "SMTPEmailSender.SendEmailVerification")
+	defer span.End()
+
+	body := "Use this token to confirm your new email address with POST /me/email/verify:\r\n\r\n" +
+		token + "\r\n\r\n" +
+		fmt.Sprintf("It is valid for %d hours. If you didn't ask to change your email address, ignore this message.\r\n", int(emailVerificationTTL.Hours()))
+	return s.send(ctx, email, "Confirm your email address", body)
+}
+
+func (s *smtpEmailSender) send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") {
+		return errors.New("invalid recipient address")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to mail server: %w", err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if s.auth != nil {
+		if err := client.Auth(s.auth); err != nil {
+			return fmt.Errorf("failed to authenticate with mail server: %w", err)
+		}
+	}
+	if err := client.Mail(s.envelopeFrom); err != nil {
+		return fmt.Errorf("mail server rejected sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("mail server rejected recipient: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	message := "From: " + s.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+	if _, err := w.Write([]byte(message)); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return client.Quit()
+}
@@ -2,64 +2,264 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt" // Added import for fmt
+	"go-rest-api/apperrors"
+	"go-rest-api/geo"
+	"go-rest-api/metrics"
 	"go-rest-api/model"
 	"go-rest-api/repository"
-	"log" // Added import for log
+	"go-rest-api/utils"
+	"go-rest-api/worker"
+	"log/slog"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
+var ErrNotEventOwner = apperrors.Forbidden("not_event_owner", "you don't have permission to modify this event")
+var ErrNotRegistered = apperrors.NotFound("not_registered", "user is not registered for this event")
+var ErrInvalidEventEnd = apperrors.BadRequest("invalid_event_end", "end_date must be after date")
+var ErrUnknownVenue = apperrors.BadRequest("unknown_venue", "venue doesn't exist")
+var ErrVenueEventNeedsEnd = apperrors.BadRequest("venue_event_needs_end", "events at a venue need an end_date")
+var ErrCapacityExceedsVenue = apperrors.BadRequest("capacity_exceeds_venue", "event capacity must be between 1 and the venue's capacity")
+var ErrVenueDoubleBooked = apperrors.Conflict("venue_double_booked", "the venue is booked by another event at this time")
+var ErrInvalidTag = apperrors.BadRequest("invalid_tag", "tags must contain letters or digits")
+var ErrUnknownCategory = apperrors.BadRequest("unknown_category", "category doesn't exist, see GET /categories")
+
 type EventService interface {
 	CreateEvent(ctx context.Context, event *model.Event) error
 	GetAllEvents(ctx context.Context) ([]model.Event, error)
 	GetEventByID(ctx context.Context, id uuid.UUID) (*model.Event, error)
+	// GetEventsByCategory returns the events of the category with the given slug or
+	// name, including its subcategories.
 	GetEventsByCategory(ctx context.Context, category string) ([]model.Event, error)
-	GetEventsByCriteria(ctx context.Context, keyword string, startDate string, endDate string) ([]model.Event, error)
+	SearchEvents(ctx context.Context, search model.EventSearch) ([]model.EventSearchResult, error)
+	GetEventFacets(ctx context.Context, search model.EventSearch) (*model.EventFacets, error)
 	UpdateEvent(ctx context.Context, event *model.Event, userID uuid.UUID, userRole string) error
 	DeleteEvent(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) error
-	RegisterForEvent(ctx context.Context, eventID, userID uuid.UUID) error
+	// RegisterForEvent registers the user, or puts them on the waitlist and returns
+	// the waitlist entry if the event is full.
+	RegisterForEvent(ctx context.Context, eventID, userID uuid.UUID) (*model.WaitlistEntry, error)
 	CancelEventRegistration(ctx context.Context, eventID, userID uuid.UUID) error
 	GetRegisteredEvents(ctx context.Context, userID uuid.UUID) ([]model.Event, error)
 }
 
+// JobProcessWaitlist promotes the next user on an event's waitlist after a cancellation.
+const JobProcessWaitlist = "waitlist.process_next"
+
+type eventJobPayload struct {
+	EventID uuid.UUID `json:"event_id"`
+}
+
 type eventService struct {
-	eventRepository repository.EventRepository
-	waitlistService WaitlistService // Added to call ProcessNextOnWaitlist
+	eventRepository    repository.EventRepository
+	categoryRepository repository.CategoryRepository
+	venueRepository    repository.VenueRepository
+	speakerRepository  repository.SpeakerRepository
+	waitlistService    WaitlistService // Added to call ProcessNextOnWaitlist
+	geocoder           geo.Geocoder
+	jobs               *worker.Group
 }
 
-func NewEventService(eventRepository repository.EventRepository, waitlistService WaitlistService) EventService {
-	return &eventService{
-		eventRepository: eventRepository,
-		waitlistService: waitlistService,
+// NewEventService returns an EventService. geocoder may be nil, then events only
+// have coordinates if they are given.
+func NewEventService(eventRepository repository.EventRepository, categoryRepository repository.CategoryRepository, venueRepository repository.VenueRepository,
+	speakerRepository repository.SpeakerRepository, waitlistService WaitlistService, geocoder geo.Geocoder, jobs *worker.Group) EventService {
+	s := &eventService{
+		eventRepository:    eventRepository,
+		categoryRepository: categoryRepository,
+		venueRepository:    venueRepository,
+		speakerRepository:  speakerRepository,
+		waitlistService:    waitlistService,
+		geocoder:           geocoder,
+		jobs:               jobs,
 	}
+	jobs.Handle(JobProcessWaitlist, s.processWaitlistJob)
+	return s
 }
 
 func (s *eventService) CreateEvent(ctx context.Context, event *model.Event) error {
+	ctx, span := tracer.Start(ctx, "EventService.CreateEvent")
+	defer span.End()
+
 	// Default capacity to 0 if not provided or negative, unless binding already handles gte=0
 	if event.Capacity != nil && *event.Capacity < 0 {
 		*event.Capacity = 0
 	}
-	return s.eventRepository.Save(ctx, event)
+	if event.Category == nil && event.CategoryID == nil {
+		defaultCategory := DefaultCategorySlug
+		event.Category = &defaultCategory
+	}
+	if err := s.setCategory(ctx, event); err != nil {
+		return err
+	}
+	if event.Tags != nil {
+		tags, err := normalizeTags(event.Tags)
+		if err != nil {
+			return err
+		}
+		event.Tags = tags
+	}
+	speakerIDs, err := checkSpeakers(ctx, s.speakerRepository, event.SpeakerIDs)
+	if err != nil {
+		return err
+	}
+	event.SpeakerIDs = speakerIDs
+	useVenueLocation := event.Location == nil && event.Latitude == nil
+	if err := s.checkVenue(ctx, event, useVenueLocation); err != nil {
+		return err
+	}
+	if event.Latitude == nil && !useVenueLocation {
+		s.locate(ctx, event)
+	}
+	return venueConflict(s.eventRepository.Save(ctx, event))
+}
+
+// checkVenue checks that the event ends after it starts and fits its venue: it
+// needs an end, its capacity must not exceed the venue's, and the venue must be
+// free at that time. An event without capacity gets the venue's. With
+// useVenueLocation the event takes the venue's address and coordinates.
+func (s *eventService) checkVenue(ctx context.Context, event *model.Event, useVenueLocation bool) error {
+	if event.EndDate != nil && event.Date != nil && !event.EndDate.After(*event.Date) {
+		return ErrInvalidEventEnd
+	}
+	if event.VenueID == nil {
+		return nil
+	}
+
+	venue, err := s.venueRepository.GetByID(ctx, *event.VenueID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return ErrUnknownVenue
+		}
+		return err
+	}
+	if event.EndDate == nil || event.Date == nil {
+		return ErrVenueEventNeedsEnd
+	}
+	if venue.Capacity != nil {
+		if event.Capacity == nil {
+			event.Capacity = venue.Capacity
+		}
+		if *event.Capacity < 1 || *event.Capacity > *venue.Capacity {
+			return ErrCapacityExceedsVenue.WithDetails(map[string]interface{}{"venue_capacity": *venue.Capacity})
+		}
+	}
+
+	conflicts, err := s.eventRepository.GetVenueConflicts(ctx, venue.Id, *event.Date, *event.EndDate, event.Id)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return ErrVenueDoubleBooked.WithDetails(map[string]interface{}{"conflicting_events": conflicts})
+	}
+
+	if useVenueLocation {
+		location := *venue.Name + ", " + *venue.Address
+		event.Location = &location
+		event.Latitude, event.Longitude = venue.Latitude, venue.Longitude
+		if event.Latitude == nil {
+			s.locate(ctx, event)
+		}
+	}
+	return nil
+}
+
+// venueConflict turns the conflict returned when saving an event that overlaps
+// another at its venue into ErrVenueDoubleBooked. The check in checkVenue misses
+// events saved at the same time.
+func venueConflict(err error) error {
+	if errors.Is(err, apperrors.ErrConflict) {
+		return ErrVenueDoubleBooked
+	}
+	return err
+}
+
+// normalizeTags trims the tags and drops repeated ones, including spellings with
+// the same slug.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		slug := utils.Slugify(tag)
+		if slug == "" {
+			return nil, ErrInvalidTag
+		}
+		if !seen[slug] {
+			seen[slug] = true
+			normalized = append(normalized, strings.Join(strings.Fields(tag), " "))
+		}
+	}
+	return normalized, nil
+}
+
+// setCategory looks up the category given by CategoryID, or else by Category, and
+// sets both to the category found.
+func (s *eventService) setCategory(ctx context.Context, event *model.Event) error {
+	var category *model.Category
+	var err error
+	if event.CategoryID != nil {
+		category, err = s.categoryRepository.GetByID(ctx, *event.CategoryID)
+		if errors.Is(err, apperrors.ErrNotFound) {
+			err = ErrCategoryNotFound
+		}
+	} else {
+		category, err = findCategory(ctx, s.categoryRepository, *event.Category)
+	}
+	if errors.Is(err, ErrCategoryNotFound) {
+		return ErrUnknownCategory
+	}
+	if err != nil {
+		return err
+	}
+	event.Category, event.CategoryID = &category.Name, &category.Id
+	return nil
+}
+
+// locate sets the coordinates of event from its location, or clears them if the
+// location can't be geocoded.
+func (s *eventService) locate(ctx context.Context, event *model.Event) {
+	event.Latitude, event.Longitude = nil, nil
+	if s.geocoder == nil || event.Location == nil {
+		return
+	}
+	point, err := s.geocoder.Geocode(ctx, *event.Location)
+	if err != nil {
+		if !errors.Is(err, geo.ErrNotFound) {
+			slog.WarnContext(ctx, "failed to geocode event location", "error", err)
+		}
+		return
+	}
+	event.Latitude, event.Longitude = &point.Latitude, &point.Longitude
 }
 
 func (s *eventService) GetAllEvents(ctx context.Context) ([]model.Event, error) {
+	ctx, span := tracer.Start(ctx, "EventService.GetAllEvents")
+	defer span.End()
+
 	return s.eventRepository.GetAllEvents(ctx)
 }
 
 func (s *eventService) GetEventByID(ctx context.Context, id uuid.UUID) (*model.Event, error) {
-	return s.eventRepository.GetEventById(ctx, id)
+	ctx, span := tracer.Start(ctx, "EventService.GetEventByID")
+	defer span.End()
+
+	return getEvent(ctx, s.eventRepository, id)
 }
 
 func (s *eventService) UpdateEvent(ctx context.Context, event *model.Event, userID uuid.UUID, userRole string) error {
-	existingEvent, err := s.eventRepository.GetEventById(ctx, event.Id)
+	ctx, span := tracer.Start(ctx, "EventService.UpdateEvent")
+	defer span.End()
+
+	existingEvent, err := getEvent(ctx, s.eventRepository, event.Id)
 	if err != nil {
 		return err
 	}
 
 	if existingEvent.UserIds != userID && userRole != "admin" {
-		return errors.New("unauthorized: you don't have permission to update this event")
+		return ErrNotEventOwner
 	}
 	// Preserve existing capacity if not provided in update payload
 	if event.Name != nil {
@@ -68,76 +268,127 @@ func (s *eventService) UpdateEvent(ctx context.Context, event *model.Event, user
 	if event.Description != nil {
 		existingEvent.Description = event.Description
 	}
+	if event.Latitude != nil {
+		existingEvent.Latitude, existingEvent.Longitude = event.Latitude, event.Longitude
+	}
 	if event.Location != nil {
+		changed := existingEvent.Location == nil || *existingEvent.Location != *event.Location
 		existingEvent.Location = event.Location
+		// The old coordinates belong to the old location
+		if changed && event.Latitude == nil {
+			s.locate(ctx, existingEvent)
+		}
 	}
 	if event.Date != nil {
 		existingEvent.Date = event.Date
 	}
-	if event.Category != nil {
-		existingEvent.Category = event.Category
+	if event.EndDate != nil {
+		existingEvent.EndDate = event.EndDate
+	}
+	if event.VenueID != nil {
+		existingEvent.VenueID = event.VenueID
+	}
+	if event.Category != nil || event.CategoryID != nil {
+		if err := s.setCategory(ctx, event); err != nil {
+			return err
+		}
+		existingEvent.Category, existingEvent.CategoryID = event.Category, event.CategoryID
+	}
+	// nil keeps the tags and speakers
+	existingEvent.Tags = nil
+	if event.Tags != nil {
+		if existingEvent.Tags, err = normalizeTags(event.Tags); err != nil {
+			return err
+		}
+	}
+	if existingEvent.SpeakerIDs, err = checkSpeakers(ctx, s.speakerRepository, event.SpeakerIDs); err != nil {
+		return err
 	}
 	if event.Capacity != nil {
 		existingEvent.Capacity = event.Capacity
 	}
+	// Moving to another venue moves the event there unless a location is given
+	useVenueLocation := event.VenueID != nil && event.Location == nil && event.Latitude == nil
+	if err := s.checkVenue(ctx, existingEvent, useVenueLocation); err != nil {
+		return err
+	}
 
-	return s.eventRepository.Update(ctx, existingEvent)
+	return venueConflict(s.eventRepository.Update(ctx, existingEvent))
 }
 
 func (s *eventService) DeleteEvent(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) error {
-	existingEvent, err := s.eventRepository.GetEventById(ctx, id)
+	ctx, span := tracer.Start(ctx, "EventService.DeleteEvent")
+	defer span.End()
+
+	existingEvent, err := getEvent(ctx, s.eventRepository, id)
 	if err != nil {
 		return err
 	}
 
 	if existingEvent.UserIds != userID && userRole != "admin" {
-		return errors.New("unauthorized: you don't have permission to delete this event")
+		return ErrNotEventOwner
 	}
 
 	return s.eventRepository.DeleteEvent(ctx, id)
 }
 
-func (s *eventService) RegisterForEvent(ctx context.Context, eventID, userID uuid.UUID) error {
-	event, err := s.eventRepository.GetEventById(ctx, eventID)
-	if err != nil {
-		return ErrEventNotFound // Use defined error
+func (s *eventService) RegisterForEvent(ctx context.Context, eventID, userID uuid.UUID) (entry *model.WaitlistEntry, err error) {
+	ctx, span := tracer.Start(ctx, "EventService.RegisterForEvent")
+	defer span.End()
+
+	defer func() {
+		result := metrics.Result(err)
+		if err == nil && entry != nil {
+			result = metrics.ResultWaitlisted
+		}
+		metrics.Registrations.WithLabelValues(result).Inc()
+	}()
+
+	if _, err := getEvent(ctx, s.eventRepository, eventID); err != nil {
+		return nil, err
 	}
 
 	// Check if user is already registered
 	isRegistered, err := s.eventRepository.IsUserRegistered(ctx, eventID, userID)
 	if err != nil {
-		return fmt.Errorf("failed to check registration status: %w", err)
+		return nil, fmt.Errorf("failed to check registration status: %w", err)
 	}
 	if isRegistered {
-		return ErrAlreadyRegistered // Use defined error
+		return nil, ErrAlreadyRegistered // Use defined error
 	}
 
-	// Check capacity if it's set (event.Capacity > 0)
-	if event.Capacity != nil && *event.Capacity > 0 {
-		registrationCount, err := s.eventRepository.GetRegistrationCount(ctx, eventID)
-		if err != nil {
-			return fmt.Errorf("failed to get registration count: %w", err)
-		}
-		if registrationCount >= *event.Capacity {
-			// Event is full, try adding to waitlist via WaitlistService
-			log.Printf("Event %s is full. Attempting to add user %s to waitlist.", eventID, userID)
-			_, wlErr := s.waitlistService.JoinWaitlist(ctx, eventID, userID)
-			if wlErr != nil {
-				log.Printf("Failed to add user %d to waitlist for event %d: %v", userID, eventID, wlErr)
-				return fmt.Errorf("event is full and failed to join waitlist: %w", wlErr)
-			}
-			return errors.New("event is full, user added to waitlist") // Specific error/message
+	// The seat is checked and taken under the event's row lock, so concurrent
+	// requests can't both take the last one.
+	err = s.eventRepository.RegisterEventIfSeatFree(ctx, eventID, userID)
+	switch {
+	case errors.Is(err, apperrors.ErrConflict):
+		// Event is full, try adding to waitlist via WaitlistService
+		slog.DebugContext(ctx, "event is full, adding user to waitlist", "event_id", eventID, "user_id", userID)
+		entry, wlErr := s.waitlistService.JoinWaitlist(ctx, eventID, userID)
+		if wlErr != nil {
+			return nil, fmt.Errorf("event is full and failed to join waitlist: %w", wlErr)
 		}
+		return entry, nil
+	case errors.Is(err, apperrors.ErrAlreadyExists):
+		// A concurrent request registered the user after the check above
+		return nil, ErrAlreadyRegistered
+	case errors.Is(err, apperrors.ErrNotFound):
+		// The event was deleted after the check above
+		return nil, ErrEventNotFound
 	}
-
-	return s.eventRepository.RegisterEvent(ctx, eventID, userID)
+	return nil, err
 }
 
-func (s *eventService) CancelEventRegistration(ctx context.Context, eventID, userID uuid.UUID) error {
+func (s *eventService) CancelEventRegistration(ctx context.Context, eventID, userID uuid.UUID) (err error) {
+	ctx, span := tracer.Start(ctx, "EventService.CancelEventRegistration")
+	defer span.End()
+
+	defer func() { metrics.Cancellations.WithLabelValues(metrics.Result(err)).Inc() }()
+
 	// Get event details before cancellation
-	event, err := s.eventRepository.GetEventById(ctx, eventID)
+	event, err := getEvent(ctx, s.eventRepository, eventID)
 	if err != nil {
-		return err // Event not found
+		return err
 	}
 
 	// Check if the event is currently at full capacity
@@ -152,39 +403,94 @@ func (s *eventService) CancelEventRegistration(ctx context.Context, eventID, use
 	// Cancel the registration
 	err = s.eventRepository.CancelRegistration(ctx, eventID, userID)
 	if err != nil {
-		return err // Failed to cancel or user wasn't registered
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return ErrNotRegistered
+		}
+		return err
 	}
 
 	// Only process the waitlist if the event was at full capacity before cancellation
 	if isFull {
-		// Run in a goroutine to avoid blocking the cancellation response
-		go func() {
-			log.Printf("Event %d was at full capacity. Processing waitlist after cancellation.", eventID)
-			promotedUser, err := s.waitlistService.ProcessNextOnWaitlist(context.Background(), eventID)
-			if err != nil {
-				log.Printf("Error processing waitlist for event %d after cancellation: %v", eventID, err)
-			} else if promotedUser != nil {
-				log.Printf("User %s (ID: %d) was promoted from waitlist for event %d.", promotedUser.Email, promotedUser.Id, eventID)
-				// Potentially send notification here if not handled by ProcessNextOnWaitlist internally
-			} else {
-				log.Printf("No user promoted from waitlist for event %d, or waitlist was empty.", eventID)
-			}
-		}()
-	} else {
-		log.Printf("Event %d was not at full capacity. No need to process waitlist.", eventID)
+		// Run in the background to avoid blocking the cancellation response
+		s.jobs.Submit(ctx, JobProcessWaitlist, eventJobPayload{EventID: eventID})
+	}
+
+	return nil
+}
+
+func (s *eventService) processWaitlistJob(ctx context.Context, payload json.RawMessage) error {
+	var job eventJobPayload
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return fmt.Errorf("invalid waitlist job payload: %w", err)
 	}
 
+	promotedUser, err := s.waitlistService.ProcessNextOnWaitlist(ctx, job.EventID)
+	if err != nil {
+		return fmt.Errorf("failed to process waitlist after cancellation: %w", err)
+	}
+	if promotedUser != nil {
+		// Potentially send notification here if not handled by ProcessNextOnWaitlist internally
+		slog.InfoContext(ctx, "promoted user from waitlist", "event_id", job.EventID, "user_id", promotedUser.Id, "email", promotedUser.Email)
+	}
 	return nil
 }
 
 func (s *eventService) GetRegisteredEvents(ctx context.Context, userID uuid.UUID) ([]model.Event, error) {
+	ctx, span := tracer.Start(ctx, "EventService.GetRegisteredEvents")
+	defer span.End()
+
 	return s.eventRepository.GetRegisteredEventByUserId(ctx, userID)
 }
 
 func (s *eventService) GetEventsByCategory(ctx context.Context, category string) ([]model.Event, error) {
-	return s.eventRepository.GetEventsByCategory(ctx, category)
+	ctx, span := tracer.Start(ctx, "EventService.GetEventsByCategory")
+	defer span.End()
+
+	found, err := findCategory(ctx, s.categoryRepository, category)
+	if err != nil {
+		return nil, err
+	}
+	return s.eventRepository.GetEventsByCategory(ctx, found.Id)
+}
+
+func (s *eventService) SearchEvents(ctx context.Context, search model.EventSearch) ([]model.EventSearchResult, error) {
+	ctx, span := tracer.Start(ctx, "EventService.SearchEvents")
+	defer span.End()
+
+	return s.eventRepository.SearchEvents(ctx, slugifySearch(search))
+}
+
+func (s *eventService) GetEventFacets(ctx context.Context, search model.EventSearch) (*model.EventFacets, error) {
+	ctx, span := tracer.Start(ctx, "EventService.GetEventFacets")
+	defer span.End()
+
+	return s.eventRepository.GetEventFacets(ctx, slugifySearch(search))
 }
 
-func (s *eventService) GetEventsByCriteria(ctx context.Context, keyword string, startDate string, endDate string) ([]model.Event, error) {
-	return s.eventRepository.GetEventsByCriteria(ctx, keyword, startDate, endDate)
+// noSlug is never a slug, so filtering by it matches nothing.
+const noSlug = "-"
+
+// slugifySearch turns the category and tags of a search, which may be names, into
+// slugs. A category or tag without letters or digits matches nothing.
+func slugifySearch(search model.EventSearch) model.EventSearch {
+	if search.Category != "" {
+		search.Category = utils.Slugify(search.Category)
+		if search.Category == "" {
+			search.Category = noSlug
+		}
+	}
+	tags := make([]string, 0, len(search.Tags))
+	seen := make(map[string]bool, len(search.Tags))
+	for _, tag := range search.Tags {
+		slug := utils.Slugify(tag)
+		if slug == "" {
+			slug = noSlug
+		}
+		if !seen[slug] {
+			seen[slug] = true
+			tags = append(tags, slug)
+		}
+	}
+	search.Tags = tags
+	return search
 }
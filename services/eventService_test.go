@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/worker"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEventRepository keeps events and their registrations in memory. Like the
+// database it checks for a free seat and registers under one lock.
+type fakeEventRepository struct {
+	repository.EventRepository
+	mu            sync.Mutex
+	events        map[uuid.UUID]model.Event
+	registrations map[uuid.UUID][]uuid.UUID
+}
+
+func newFakeEventRepository(events ...model.Event) *fakeEventRepository {
+	r := &fakeEventRepository{events: map[uuid.UUID]model.Event{}, registrations: map[uuid.UUID][]uuid.UUID{}}
+	for _, event := range events {
+		r.events[event.Id] = event
+	}
+	return r
+}
+
+func (r *fakeEventRepository) GetEventById(ctx context.Context, id uuid.UUID) (*model.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event, ok := r.events[id]
+	if !ok {
+		return nil, apperrors.ErrNotFound
+	}
+	return &event, nil
+}
+
+func (r *fakeEventRepository) RegisterEventIfSeatFree(ctx context.Context, eventID, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event, ok := r.events[eventID]
+	if !ok {
+		return apperrors.ErrNotFound
+	}
+	for _, registered := range r.registrations[eventID] {
+		if registered == userID {
+			return apperrors.ErrAlreadyExists
+		}
+	}
+	if event.Capacity != nil && *event.Capacity > 0 && len(r.registrations[eventID]) >= *event.Capacity {
+		return apperrors.ErrConflict
+	}
+	r.registrations[eventID] = append(r.registrations[eventID], userID)
+	return nil
+}
+
+func (r *fakeEventRepository) GetRegistrationCount(ctx context.Context, eventID uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.registrations[eventID]), nil
+}
+
+func (r *fakeEventRepository) IsUserRegistered(ctx context.Context, eventID uuid.UUID, userID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, registered := range r.registrations[eventID] {
+		if registered == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeEventRepository) CancelRegistration(ctx context.Context, eventID, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	registrations := r.registrations[eventID]
+	for i, registered := range registrations {
+		if registered == userID {
+			r.registrations[eventID] = append(registrations[:i:i], registrations[i+1:]...)
+			return nil
+		}
+	}
+	return apperrors.ErrNotFound
+}
+
+// fakeWaitlistRepository keeps waitlists in memory, in the order users joined.
+type fakeWaitlistRepository struct {
+	mu      sync.Mutex
+	entries []model.WaitlistEntry
+}
+
+func (r *fakeWaitlistRepository) AddUserToWaitlist(ctx context.Context, eventID uuid.UUID, userID uuid.UUID) (*model.WaitlistEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, entry := range r.entries {
+		if entry.EventID == eventID && entry.UserID == userID {
+			return nil, apperrors.ErrAlreadyExists
+		}
+	}
+	entry := model.WaitlistEntry{Id: uuid.New(), EventID: eventID, UserID: userID, CreatedAt: time.Now()}
+	r.entries = append(r.entries, entry)
+	return &entry, nil
+}
+
+func (r *fakeWaitlistRepository) RemoveUserFromWaitlist(ctx context.Context, eventID uuid.UUID, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, entry := range r.entries {
+		if entry.EventID == eventID && entry.UserID == userID {
+			r.entries = append(r.entries[:i:i], r.entries[i+1:]...)
+			return nil
+		}
+	}
+	return apperrors.ErrNotFound
+}
+
+func (r *fakeWaitlistRepository) GetWaitlistForEvent(ctx context.Context, eventID uuid.UUID) ([]model.WaitlistEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]model.WaitlistEntry, 0)
+	for _, entry := range r.entries {
+		if entry.EventID == eventID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *fakeWaitlistRepository) GetNextUserFromWaitlist(ctx context.Context, eventID uuid.UUID) (*model.WaitlistEntry, error) {
+	entries, _ := r.GetWaitlistForEvent(ctx, eventID)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+func (r *fakeWaitlistRepository) IsUserOnWaitlist(ctx context.Context, eventID uuid.UUID, userID uuid.UUID) (bool, error) {
+	entries, _ := r.GetWaitlistForEvent(ctx, eventID)
+	for _, entry := range entries {
+		if entry.UserID == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// newTestEventService returns an event service for one event with room for capacity
+// users, and the background job group it runs the waitlist on.
+func newTestEventService(t *testing.T, capacity int, users ...*model.User) (EventService, *worker.Group, *fakeEventRepository, *fakeWaitlistRepository, uuid.UUID) {
+	t.Helper()
+	event := model.Event{Id: uuid.New(), Capacity: &capacity}
+	events := newFakeEventRepository(event)
+	waitlist := &fakeWaitlistRepository{}
+	jobs := worker.NewGroup(nil)
+	waitlistService := NewWaitlistService(waitlist, events, newFakeUserRepository(users...))
+	return NewEventService(events, nil, nil, nil, waitlistService, nil, jobs), jobs, events, waitlist, event.Id
+}
+
+func TestCancelRegistrationPromotesFromWaitlist(t *testing.T) {
+	attendee := &model.User{Id: uuid.New(), Email: "ada@example.com"}
+	waiting := &model.User{Id: uuid.New(), Email: "grace@example.com"}
+	service, jobs, events, waitlist, eventID := newTestEventService(t, 1, attendee, waiting)
+	ctx := context.Background()
+
+	require.NoError(t, events.RegisterEventIfSeatFree(ctx, eventID, attendee.Id))
+	_, err := waitlist.AddUserToWaitlist(ctx, eventID, waiting.Id)
+	require.NoError(t, err)
+
+	require.NoError(t, service.CancelEventRegistration(ctx, eventID, attendee.Id))
+	// Shutdown waits for the waitlist job started by the cancellation
+	require.NoError(t, jobs.Shutdown(ctx))
+
+	registered, err := events.IsUserRegistered(ctx, eventID, waiting.Id)
+	require.NoError(t, err)
+	assert.True(t, registered, "waitlisted user is promoted")
+	onWaitlist, err := waitlist.IsUserOnWaitlist(ctx, eventID, waiting.Id)
+	require.NoError(t, err)
+	assert.False(t, onWaitlist)
+}
+
+func TestConcurrentRegistrationsDontOverbook(t *testing.T) {
+	const attendees = 10
+	service, _, events, waitlist, eventID := newTestEventService(t, 1)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for range attendees {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.RegisterForEvent(ctx, eventID, uuid.New())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	count, err := events.GetRegistrationCount(ctx, eventID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	waiting, err := waitlist.GetWaitlistForEvent(ctx, eventID)
+	require.NoError(t, err)
+	assert.Len(t, waiting, attendees-1, "everyone else is waitlisted")
+}
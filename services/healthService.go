@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/repository"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Component and overall health states reported by HealthService.
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+// readinessTimeout bounds each dependency check so a hung database can't stall
+// the orchestrator's probe.
+const readinessTimeout = 2 * time.Second
+
+type ComponentHealth struct {
+	Status    string                 `json:"status"`
+	LatencyMS int64                  `json:"latency_ms"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+type HealthService interface {
+	// Ready checks the dependencies the API needs to serve traffic: the database is
+	// reachable and its schema is at the expected migration version and not dirty.
+	Ready(ctx context.Context) *HealthReport
+	// StartDraining makes Ready report the instance as down so load balancers stop
+	// sending it new traffic while it shuts down.
+	StartDraining()
+}
+
+type healthService struct {
+	healthRepo      repository.HealthRepository
+	expectedVersion uint
+	draining        atomic.Bool
+}
+
+// NewHealthService returns a HealthService expecting the schema to be at expectedVersion,
+// the version of the newest migration shipped with the binary.
+func NewHealthService(healthRepo repository.HealthRepository, expectedVersion uint) HealthService {
+	return &healthService{
+		healthRepo:      healthRepo,
+		expectedVersion: expectedVersion,
+	}
+}
+
+func (s *healthService) Ready(ctx context.Context) *HealthReport {
+	ctx, span := tracer.Start(ctx, "HealthService.Ready")
+	defer span.End()
+
+	report := &HealthReport{
+		Status: HealthStatusUp,
+		Components: map[string]ComponentHealth{
+			"database":   s.check(ctx, s.checkDatabase),
+			"migrations": s.check(ctx, s.checkMigrations),
+		},
+	}
+	if s.draining.Load() {
+		report.Components["server"] = ComponentHealth{Status: HealthStatusDown, Error: "shutting down"}
+	}
+	for _, component := range report.Components {
+		if component.Status != HealthStatusUp {
+			report.Status = HealthStatusDown
+		}
+	}
+	return report
+}
+
+func (s *healthService) StartDraining() {
+	s.draining.Store(true)
+}
+
+func (s *healthService) check(ctx context.Context, fn func(ctx context.Context) (map[string]interface{}, error)) ComponentHealth {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := fn(ctx)
+	health := ComponentHealth{
+		Status:    HealthStatusUp,
+		LatencyMS: time.Since(start).Milliseconds(),
+		Details:   details,
+	}
+	if err != nil {
+		health.Status = HealthStatusDown
+		health.Error = err.Error()
+	}
+	return health
+}
+
+// The driver's error can include connection details, so it is only logged.
+func (s *healthService) checkDatabase(ctx context.Context) (map[string]interface{}, error) {
+	if err := s.healthRepo.Ping(ctx); err != nil {
+		slog.WarnContext(ctx, "readiness check: database unreachable", "error", err)
+		return nil, errors.New("database is unreachable")
+	}
+	return nil, nil
+}
+
+func (s *healthService) checkMigrations(ctx context.Context) (map[string]interface{}, error) {
+	version, dirty, ok, err := s.healthRepo.MigrationVersion(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "readiness check: failed to read migration version", "error", err)
+		return nil, errors.New("could not read migration version")
+	}
+
+	details := map[string]interface{}{
+		"version":          version,
+		"expected_version": s.expectedVersion,
+		"dirty":            dirty,
+	}
+	switch {
+	case !ok:
+		return details, errors.New("no migrations have been applied")
+	case dirty:
+		return details, fmt.Errorf("schema version %d is dirty, a migration failed part-way", version)
+	case version != s.expectedVersion:
+		return details, fmt.Errorf("schema version %d does not match expected version %d", version, s.expectedVersion)
+	}
+	return details, nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/utils"
+	"log/slog"
+	"time"
+)
+
+// keyRefreshInterval is how often every instance reloads keys from the database.
+// New keys are published keyPropagationDelay before they start signing so that
+// all instances (and JWKS consumers) know them by then.
+const (
+	keyRefreshInterval  = time.Minute
+	keyPropagationDelay = 2 * keyRefreshInterval
+)
+
+type KeyRotationService interface {
+	Refresh(ctx context.Context) error
+	Run(ctx context.Context)
+}
+
+type keyRotationService struct {
+	signingKeyRepo   repository.SigningKeyRepository
+	keys             *utils.KeySet
+	algorithm        string
+	rotationInterval time.Duration
+	staticKeys       []utils.SigningKey
+}
+
+// NewKeyRotationService manages algorithm keys in keys. staticKeys are always kept in the set,
+// e.g. the legacy HS256 secret so tokens issued before switching algorithms stay valid.
+func NewKeyRotationService(signingKeyRepo repository.SigningKeyRepository, keys *utils.KeySet, algorithm string, rotationInterval time.Duration, staticKeys ...utils.SigningKey) KeyRotationService {
+	return &keyRotationService{
+		signingKeyRepo:   signingKeyRepo,
+		keys:             keys,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		staticKeys:       staticKeys,
+	}
+}
+
+// Refresh creates a new key when the current one is due for rotation, removes expired
+// keys and reloads the key set from the database.
+func (s *keyRotationService) Refresh(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "KeyRotationService.Refresh")
+	defer span.End()
+
+	records, err := s.signingKeyRepo.GetUnexpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var newest *model.SigningKeyRecord
+	for i := range records {
+		if records[i].Algorithm == s.algorithm && (newest == nil || records[i].ActivatesAt.After(newest.ActivatesAt)) {
+			newest = &records[i]
+		}
+	}
+
+	if newest == nil || !now.Before(newest.ActivatesAt.Add(s.rotationInterval-keyPropagationDelay)) {
+		activatesAt := now
+		if newest != nil && newest.ActivatesAt.Add(s.rotationInterval).After(now) {
+			activatesAt = newest.ActivatesAt.Add(s.rotationInterval)
+		}
+		record, err := s.createKey(ctx, activatesAt)
+		if err != nil {
+			return err
+		}
+		records = append(records, *record)
+		slog.InfoContext(ctx, "created signing key", "algorithm", s.algorithm, "kid", record.Kid, "activates_at", record.ActivatesAt)
+	}
+
+	deleted, err := s.signingKeyRepo.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, "removed expired signing keys", "count", deleted)
+	}
+
+	keys := append([]utils.SigningKey{}, s.staticKeys...)
+	for _, record := range records {
+		privateKey, err := utils.ParsePrivateKey(record.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("failed to parse signing key %s: %w", record.Kid, err)
+		}
+		keys = append(keys, utils.SigningKey{
+			Kid:         record.Kid,
+			Algorithm:   record.Algorithm,
+			PrivateKey:  privateKey,
+			ActivatesAt: record.ActivatesAt,
+			ExpiresAt:   record.ExpiresAt,
+		})
+	}
+	s.keys.SetKeys(keys)
+	return nil
+}
+
+func (s *keyRotationService) createKey(ctx context.Context, activatesAt time.Time) (*model.SigningKeyRecord, error) {
+	// A key signs for one rotation interval; keep it for another interval plus the token
+	// lifetime so tokens it signed remain verifiable even if rotation runs late.
+	expiresAt := activatesAt.Add(2*s.rotationInterval + utils.TokenTTL)
+	key, err := utils.GenerateSigningKey(s.algorithm, activatesAt, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	privateKey, err := utils.EncodePrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signing key: %w", err)
+	}
+
+	record := &model.SigningKeyRecord{
+		Kid:         key.Kid,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  privateKey,
+		ActivatesAt: key.ActivatesAt,
+		ExpiresAt:   key.ExpiresAt,
+	}
+	if err := s.signingKeyRepo.Save(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Run refreshes the key set periodically until ctx is cancelled.
+func (s *keyRotationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(keyRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(ctx); err != nil {
+				slog.ErrorContext(ctx, "failed to refresh signing keys", "error", err)
+			}
+		}
+	}
+}
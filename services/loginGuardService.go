@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrAccountLocked = apperrors.TooManyRequests("account_locked", "account is temporarily locked due to too many failed login attempts")
+var ErrTooManyLoginAttempts = apperrors.TooManyRequests("too_many_login_attempts", "too many failed login attempts, try again later")
+
+// LoginPolicy controls progressive delays and lockouts for failed logins.
+type LoginPolicy struct {
+	// Failures are forgotten once the last one is older than FailureWindow.
+	FailureWindow time.Duration
+	// After DelayAfter failures each attempt must wait BaseDelay, doubled per extra failure, up to MaxDelay.
+	DelayAfter int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// Reaching AccountLockThreshold (per email) or IPLockThreshold (per client IP) failures locks for LockDuration.
+	AccountLockThreshold int
+	IPLockThreshold      int
+	LockDuration         time.Duration
+}
+
+func DefaultLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		FailureWindow:        15 * time.Minute,
+		DelayAfter:           3,
+		BaseDelay:            time.Second,
+		MaxDelay:             30 * time.Second,
+		AccountLockThreshold: 10,
+		IPLockThreshold:      50,
+		LockDuration:         15 * time.Minute,
+	}
+}
+
+type LoginGuardService interface {
+	Check(ctx context.Context, email string, ip string) error
+	RecordFailure(ctx context.Context, email string, ip string) error
+	RecordSuccess(ctx context.Context, email string) error
+	UnlockUser(ctx context.Context, userID uuid.UUID, actorID uuid.UUID) error
+	GetAuditEvents(ctx context.Context, eventType string, limit int) ([]model.AuditEvent, error)
+	// Run deletes expired failure counters and lockouts periodically until ctx is cancelled.
+	Run(ctx context.Context)
+}
+
+// throttlePruneInterval is how often Run deletes expired login throttles.
+const throttlePruneInterval = time.Hour
+
+type loginGuardService struct {
+	throttleRepo repository.LoginThrottleRepository
+	auditRepo    repository.AuditRepository
+	userRepo     repository.UserRepository
+	policy       LoginPolicy
+}
+
+func NewLoginGuardService(throttleRepo repository.LoginThrottleRepository, auditRepo repository.AuditRepository, userRepo repository.UserRepository, policy LoginPolicy) LoginGuardService {
+	return &loginGuardService{
+		throttleRepo: throttleRepo,
+		auditRepo:    auditRepo,
+		userRepo:     userRepo,
+		policy:       policy,
+	}
+}
+
+// Accounts are keyed by normalized email rather than user ID so that attempts
+// against unknown emails are throttled the same way and reveal nothing.
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Check returns ErrAccountLocked or ErrTooManyLoginAttempts if the account or IP is
+// locked or still inside its progressive delay. The retry_after detail tells the
+// client how many seconds to wait.
+func (s *loginGuardService) Check(ctx context.Context, email string, ip string) error {
+	ctx, span := tracer.Start(ctx, "LoginGuardService.Check")
+	defer span.End()
+
+	now := time.Now()
+	var lockedFor, delayedFor time.Duration
+
+	for _, scope := range []struct{ name, key string }{
+		{model.LoginThrottleScopeAccount, accountKey(email)},
+		{model.LoginThrottleScopeIP, ip},
+	} {
+		throttle, err := s.throttleRepo.Get(ctx, scope.name, scope.key)
+		if err != nil {
+			return err
+		}
+		if throttle == nil {
+			continue
+		}
+
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			lockedFor = max(lockedFor, throttle.LockedUntil.Sub(now))
+		} else if throttle.LastFailedAt != nil {
+			delayedFor = max(delayedFor, throttle.LastFailedAt.Add(s.delayFor(throttle.FailedCount)).Sub(now))
+		}
+	}
+
+	if lockedFor > 0 {
+		return ErrAccountLocked.WithDetails(retryAfterDetails(lockedFor))
+	}
+	if delayedFor > 0 {
+		return ErrTooManyLoginAttempts.WithDetails(retryAfterDetails(delayedFor))
+	}
+	return nil
+}
+
+func retryAfterDetails(d time.Duration) map[string]interface{} {
+	return map[string]interface{}{"retry_after": int(math.Ceil(d.Seconds()))}
+}
+
+func (s *loginGuardService) delayFor(failedCount int) time.Duration {
+	if failedCount < s.policy.DelayAfter {
+		return 0
+	}
+	delay := s.policy.BaseDelay
+	for i := s.policy.DelayAfter; i < failedCount && delay < s.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > s.policy.MaxDelay {
+		delay = s.policy.MaxDelay
+	}
+	return delay
+}
+
+// RecordFailure counts a failed login and locks the account or IP once its threshold is reached.
+func (s *loginGuardService) RecordFailure(ctx context.Context, email string, ip string) error {
+	ctx, span := tracer.Start(ctx, "LoginGuardService.RecordFailure")
+	defer span.End()
+
+	account, err := s.throttleRepo.RegisterFailure(ctx, model.LoginThrottleScopeAccount, accountKey(email), s.policy.FailureWindow)
+	if err != nil {
+		return err
+	}
+	if account.FailedCount >= s.policy.AccountLockThreshold {
+		if err := s.lock(ctx, account, model.AuditEventAccountLocked, ip); err != nil {
+			return err
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	ipThrottle, err := s.throttleRepo.RegisterFailure(ctx, model.LoginThrottleScopeIP, ip, s.policy.FailureWindow)
+	if err != nil {
+		return err
+	}
+	if ipThrottle.FailedCount >= s.policy.IPLockThreshold {
+		return s.lock(ctx, ipThrottle, model.AuditEventIPLocked, ip)
+	}
+	return nil
+}
+
+func (s *loginGuardService) lock(ctx context.Context, throttle *model.LoginThrottle, eventType string, ip string) error {
+	lockedUntil := time.Now().Add(s.policy.LockDuration)
+	if err := s.throttleRepo.Lock(ctx, throttle.Scope, throttle.Key, lockedUntil); err != nil {
+		return err
+	}
+	slog.WarnContext(ctx, "login locked", "scope", throttle.Scope, "key", throttle.Key, "locked_until", lockedUntil, "failed_attempts", throttle.FailedCount)
+
+	event := &model.AuditEvent{
+		EventType: eventType,
+		IP:        ip,
+		Details: map[string]interface{}{
+			"failed_attempts": throttle.FailedCount,
+			"locked_until":    lockedUntil,
+		},
+	}
+	if throttle.Scope == model.LoginThrottleScopeAccount {
+		event.Details["email"] = throttle.Key
+		if user, err := s.userRepo.GetByEmail(ctx, throttle.Key); err == nil {
+			event.UserID = &user.Id
+		}
+	}
+	return s.auditRepo.Record(ctx, event)
+}
+
+func (s *loginGuardService) RecordSuccess(ctx context.Context, email string) error {
+	ctx, span := tracer.Start(ctx, "LoginGuardService.RecordSuccess")
+	defer span.End()
+
+	return s.throttleRepo.Reset(ctx, model.LoginThrottleScopeAccount, accountKey(email))
+}
+
+// UnlockUser clears the lockout and failure counter of a user's account.
+func (s *loginGuardService) UnlockUser(ctx context.Context, userID uuid.UUID, actorID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "LoginGuardService.UnlockUser")
+	defer span.End()
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return userError(err)
+	}
+
+	if err := s.throttleRepo.Reset(ctx, model.LoginThrottleScopeAccount, accountKey(user.Email)); err != nil {
+		return err
+	}
+
+	err = s.auditRepo.Record(ctx, &model.AuditEvent{
+		EventType: model.AuditEventAccountUnlocked,
+		UserID:    &user.Id,
+		ActorID:   &actorID,
+		Details:   map[string]interface{}{"email": accountKey(user.Email)},
+	})
+	if err != nil {
+		return fmt.Errorf("account unlocked but failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+func (s *loginGuardService) GetAuditEvents(ctx context.Context, eventType string, limit int) ([]model.AuditEvent, error) {
+	ctx, span := tracer.Start(ctx, "LoginGuardService.GetAuditEvents")
+	defer span.End()
+
+	return s.auditRepo.List(ctx, eventType, limit)
+}
+
+func (s *loginGuardService) Run(ctx context.Context) {
+	ticker := time.NewTicker(throttlePruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.throttleRepo.DeleteExpired(ctx, s.policy.FailureWindow)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to delete expired login throttles", "error", err)
+				continue
+			}
+			slog.DebugContext(ctx, "deleted expired login throttles", "count", deleted)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrMFAAlreadyEnabled = apperrors.Conflict("mfa_already_enabled", "two-factor authentication is already enabled")
+var ErrMFANotEnabled = apperrors.Conflict("mfa_not_enabled", "two-factor authentication is not enabled")
+var ErrMFANotEnrolling = apperrors.Conflict("mfa_not_enrolling", "two-factor enrollment has not been started")
+var ErrInvalidMFACode = apperrors.Unauthorized("invalid_mfa_code", "invalid two-factor code")
+var ErrInvalidMFAToken = apperrors.Unauthorized("invalid_mfa_token", "invalid or expired mfa_token")
+var ErrMFARequiredByPolicy = apperrors.Forbidden("mfa_required", "two-factor authentication is required for your role")
+
+const (
+	totpIssuer        = "Event Booking"
+	recoveryCodeCount = 10
+)
+
+// What a user has to do after a correct password before a token is issued.
+const (
+	MFAStepNone   = ""
+	MFAStepVerify = "verify"
+	MFAStepEnroll = "enroll"
+)
+
+type MFAStatus struct {
+	Enabled                bool `json:"enabled"`
+	Required               bool `json:"required"`
+	RemainingRecoveryCodes int  `json:"remaining_recovery_codes"`
+}
+
+type MFAEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type MFAService interface {
+	GetStatus(ctx context.Context, userID uuid.UUID) (*MFAStatus, error)
+	BeginEnrollment(ctx context.Context, userID uuid.UUID) (*MFAEnrollment, error)
+	ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	Disable(ctx context.Context, userID uuid.UUID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	LoginStep(ctx context.Context, user *model.User) (string, error)
+	VerifyLogin(ctx context.Context, userID uuid.UUID, code string, recoveryCode string) error
+	// UseToken marks the mfa_token as used, returning ErrInvalidMFAToken if it already was.
+	UseToken(ctx context.Context, token *utils.MFATokenClaims) error
+	GetPolicies(ctx context.Context) ([]model.MFAPolicy, error)
+	SetPolicy(ctx context.Context, policy *model.MFAPolicy) error
+}
+
+type mfaService struct {
+	mfaRepo  repository.MFARepository
+	userRepo repository.UserRepository
+}
+
+func NewMFAService(mfaRepo repository.MFARepository, userRepo repository.UserRepository) MFAService {
+	return &mfaService{
+		mfaRepo:  mfaRepo,
+		userRepo: userRepo,
+	}
+}
+
+func (s *mfaService) GetStatus(ctx context.Context, userID uuid.UUID) (*MFAStatus, error) {
+	ctx, span := tracer.Start(ctx, "MfaService.GetStatus")
+	defer span.End()
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, userError(err)
+	}
+	mfa, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, userError(err)
+	}
+	required, err := s.mfaRepo.IsRequiredForRole(ctx, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MFAStatus{Enabled: mfa.TOTPEnabled, Required: required}
+	if mfa.TOTPEnabled {
+		status.RemainingRecoveryCodes, err = s.mfaRepo.CountUnusedRecoveryCodes(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// BeginEnrollment generates a new secret. It only becomes active once ConfirmEnrollment
+// is called with a code from the authenticator app.
+func (s *mfaService) BeginEnrollment(ctx context.Context, userID uuid.UUID) (*MFAEnrollment, error) {
+	ctx, span := tracer.Start(ctx, "MfaService.BeginEnrollment")
+	defer span.End()
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return nil, userError(err)
+	}
+	mfa, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, userError(err)
+	}
+	if mfa.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate two-factor secret: %w", err)
+	}
+	if err := s.mfaRepo.SetPendingSecret(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &MFAEnrollment{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables two-factor authentication and returns fresh recovery codes.
+func (s *mfaService) ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "MfaService.ConfirmEnrollment")
+	defer span.End()
+
+	mfa, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, userError(err)
+	}
+	if mfa.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if mfa.TOTPSecret == nil {
+		return nil, ErrMFANotEnrolling
+	}
+
+	step, ok := utils.ValidateTOTP(*mfa.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	if err := s.mfaRepo.Enable(ctx, userID, step); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, userID)
+}
+
+func (s *mfaService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	ctx, span := tracer.Start(ctx, "MfaService.Disable")
+	defer span.End()
+
+	user, err := s.userRepo.GetById(ctx, userID)
+	if err != nil {
+		return userError(err)
+	}
+	required, err := s.mfaRepo.IsRequiredForRole(ctx, user.Role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequiredByPolicy
+	}
+
+	if err := s.verifyCode(ctx, userID, code, code); err != nil {
+		return err
+	}
+	return s.mfaRepo.Disable(ctx, userID)
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "MfaService.RegenerateRecoveryCodes")
+	defer span.End()
+
+	if err := s.verifyCode(ctx, userID, code, ""); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, userID)
+}
+
+// LoginStep reports whether the user must verify a code (MFAStepVerify), must enrol
+// because their role requires it (MFAStepEnroll), or can be issued a token directly.
+func (s *mfaService) LoginStep(ctx context.Context, user *model.User) (string, error) {
+	ctx, span := tracer.Start(ctx, "MfaService.LoginStep")
+	defer span.End()
+
+	mfa, err := s.mfaRepo.GetUserMFA(ctx, user.Id)
+	if err != nil {
+		return MFAStepNone, err
+	}
+	if mfa.TOTPEnabled {
+		return MFAStepVerify, nil
+	}
+
+	required, err := s.mfaRepo.IsRequiredForRole(ctx, user.Role)
+	if err != nil {
+		return MFAStepNone, err
+	}
+	if required {
+		return MFAStepEnroll, nil
+	}
+	return MFAStepNone, nil
+}
+
+func (s *mfaService) VerifyLogin(ctx context.Context, userID uuid.UUID, code string, recoveryCode string) error {
+	ctx, span := tracer.Start(ctx, "MfaService.VerifyLogin")
+	defer span.End()
+
+	return s.verifyCode(ctx, userID, code, recoveryCode)
+}
+
+func (s *mfaService) UseToken(ctx context.Context, token *utils.MFATokenClaims) error {
+	ctx, span := tracer.Start(ctx, "MfaService.UseToken")
+	defer span.End()
+
+	fresh, err := s.mfaRepo.UseToken(ctx, token.ID, token.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidMFAToken
+	}
+	return nil
+}
+
+// verifyCode accepts either a TOTP code that has not been used before or an unused recovery code.
+func (s *mfaService) verifyCode(ctx context.Context, userID uuid.UUID, code string, recoveryCode string) error {
+	mfa, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return userError(err)
+	}
+	if !mfa.TOTPEnabled || mfa.TOTPSecret == nil {
+		return ErrMFANotEnabled
+	}
+
+	if code != "" {
+		if step, ok := utils.ValidateTOTP(*mfa.TOTPSecret, code, time.Now()); ok {
+			fresh, err := s.mfaRepo.MarkStepUsed(ctx, userID, step)
+			if err != nil {
+				return err
+			}
+			if fresh {
+				return nil
+			}
+		}
+	}
+
+	if recoveryCode != "" {
+		used, err := s.mfaRepo.UseRecoveryCode(ctx, userID, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+	}
+
+	return ErrInvalidMFACode
+}
+
+func (s *mfaService) newRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mfaService) GetPolicies(ctx context.Context) ([]model.MFAPolicy, error) {
+	ctx, span := tracer.Start(ctx, "MfaService.GetPolicies")
+	defer span.End()
+
+	return s.mfaRepo.GetPolicies(ctx)
+}
+
+func (s *mfaService) SetPolicy(ctx context.Context, policy *model.MFAPolicy) error {
+	ctx, span := tracer.Start(ctx, "MfaService.SetPolicy")
+	defer span.End()
+
+	if policy.Role != "user" && policy.Role != "admin" {
+		return ErrInvalidRole
+	}
+	return s.mfaRepo.SetPolicy(ctx, policy)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMFARepository keeps one user's two-factor state in memory. Methods the tests
+// don't use panic through the nil embedded interface.
+type fakeMFARepository struct {
+	repository.MFARepository
+	mu         sync.Mutex
+	mfa        model.UserMFA
+	usedTokens map[string]bool
+}
+
+func (r *fakeMFARepository) GetUserMFA(ctx context.Context, userID uuid.UUID) (*model.UserMFA, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mfa := r.mfa
+	return &mfa, nil
+}
+
+func (r *fakeMFARepository) MarkStepUsed(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.mfa.TOTPLastUsedStep != nil && *r.mfa.TOTPLastUsedStep >= step {
+		return false, nil
+	}
+	r.mfa.TOTPLastUsedStep = &step
+	return true, nil
+}
+
+func (r *fakeMFARepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeMFARepository) UseToken(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.usedTokens[jti] {
+		return false, nil
+	}
+	r.usedTokens[jti] = true
+	return true, nil
+}
+
+func newTestMFAService(t *testing.T) (MFAService, string) {
+	t.Helper()
+	secret, err := utils.GenerateTOTPSecret()
+	require.NoError(t, err)
+	repo := &fakeMFARepository{mfa: model.UserMFA{TOTPSecret: &secret, TOTPEnabled: true}, usedTokens: map[string]bool{}}
+	return NewMFAService(repo, nil), secret
+}
+
+func TestVerifyLoginRejectsReplayedCode(t *testing.T) {
+	service, secret := newTestMFAService(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	code, err := utils.TOTPCode(secret, time.Now())
+	require.NoError(t, err)
+
+	require.NoError(t, service.VerifyLogin(ctx, userID, code, ""))
+	assert.ErrorIs(t, service.VerifyLogin(ctx, userID, code, ""), ErrInvalidMFACode)
+}
+
+func TestVerifyLoginRejectsEarlierCodeAfterLaterOne(t *testing.T) {
+	service, secret := newTestMFAService(t)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	// Both are inside the accepted skew, but once the later step was used the
+	// earlier one must not be accepted any more.
+	earlier, err := utils.TOTPCode(secret, time.Now().Add(-30*time.Second))
+	require.NoError(t, err)
+	current, err := utils.TOTPCode(secret, time.Now())
+	require.NoError(t, err)
+	if earlier == current {
+		t.Skip("codes of consecutive steps happen to be equal")
+	}
+
+	require.NoError(t, service.VerifyLogin(ctx, userID, current, ""))
+	assert.ErrorIs(t, service.VerifyLogin(ctx, userID, earlier, ""), ErrInvalidMFACode)
+}
+
+func TestVerifyLoginRejectsWrongCode(t *testing.T) {
+	service, secret := newTestMFAService(t)
+
+	code, err := utils.TOTPCode(secret, time.Now().Add(-5*time.Minute))
+	require.NoError(t, err)
+	assert.ErrorIs(t, service.VerifyLogin(context.Background(), uuid.New(), code, ""), ErrInvalidMFACode)
+}
+
+func TestUseTokenOnlyOnce(t *testing.T) {
+	service, _ := newTestMFAService(t)
+	ctx := context.Background()
+	token := &utils.MFATokenClaims{UserID: uuid.NewString(), ID: "jti-1", ExpiresAt: time.Now().Add(time.Minute)}
+
+	require.NoError(t, service.UseToken(ctx, token))
+	assert.ErrorIs(t, service.UseToken(ctx, token), ErrInvalidMFAToken)
+	assert.NoError(t, service.UseToken(ctx, &utils.MFATokenClaims{UserID: token.UserID, ID: "jti-2", ExpiresAt: token.ExpiresAt}))
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/config"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/utils"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+var ErrUnknownOIDCProvider = apperrors.NotFound("unknown_oidc_provider", "unknown identity provider")
+var ErrInvalidOIDCState = apperrors.BadRequest("invalid_oidc_state", "invalid or expired login state")
+var ErrOIDCEmailNotVerified = apperrors.BadRequest("oidc_email_not_verified", "identity provider did not return a verified email address")
+var ErrOIDCLoginFailed = apperrors.Unauthorized("oidc_login_failed", "login with identity provider failed")
+var ErrOIDCProviderUnavailable = apperrors.New(http.StatusBadGateway, "oidc_provider_unavailable", "identity provider is unavailable")
+
+const oidcAuthRequestTTL = 10 * time.Minute
+
+type OIDCService interface {
+	Providers() []string
+	AuthorizationURL(ctx context.Context, provider string) (string, error)
+	HandleCallback(ctx context.Context, provider string, state string, code string) (*model.User, error)
+	GetIdentities(ctx context.Context, userID uuid.UUID) ([]model.UserIdentity, error)
+}
+
+type oidcService struct {
+	identityRepo repository.IdentityRepository
+	userRepo     repository.UserRepository
+	providers    map[string]*oidcProvider
+}
+
+// oidcProvider discovers its provider metadata lazily, so an unreachable
+// identity provider does not prevent the API from starting.
+type oidcProvider struct {
+	cfg          config.OIDCProviderConfig
+	mu           sync.Mutex
+	verifier     *oidc.IDTokenVerifier
+	oauth2Config *oauth2.Config
+}
+
+func NewOIDCService(identityRepo repository.IdentityRepository, userRepo repository.UserRepository, providerConfigs []config.OIDCProviderConfig) OIDCService {
+	providers := make(map[string]*oidcProvider, len(providerConfigs))
+	for _, cfg := range providerConfigs {
+		providers[cfg.Name] = &oidcProvider{cfg: cfg}
+	}
+	return &oidcService{
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		providers:    providers,
+	}
+}
+
+func (p *oidcProvider) init(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.verifier != nil {
+		return nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.cfg.IssuerURL)
+	if err != nil {
+		return ErrOIDCProviderUnavailable.Wrap(fmt.Errorf("failed to discover OIDC provider %s: %w", p.cfg.Name, err))
+	}
+
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	p.oauth2Config = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.cfg.Scopes,
+	}
+	return nil
+}
+
+func (s *oidcService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *oidcService) provider(ctx context.Context, name string) (*oidcProvider, error) {
+	p, ok := s.providers[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+	if err := p.init(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// AuthorizationURL starts an authorization code flow with PKCE and returns the URL
+// the user agent should be redirected to.
+func (s *oidcService) AuthorizationURL(ctx context.Context, providerName string) (string, error) {
+	ctx, span := tracer.Start(ctx, "OidcService.AuthorizationURL")
+	defer span.End()
+
+	p, err := s.provider(ctx, providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	err = s.identityRepo.SaveAuthRequest(ctx, &model.OIDCAuthRequest{
+		State:        state,
+		Provider:     p.cfg.Name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oidcAuthRequestTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return p.oauth2Config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// HandleCallback exchanges the authorization code, verifies the ID token and returns
+// the local user linked to the external identity, linking or creating one if needed.
+func (s *oidcService) HandleCallback(ctx context.Context, providerName string, state string, code string) (*model.User, error) {
+	ctx, span := tracer.Start(ctx, "OidcService.HandleCallback")
+	defer span.End()
+
+	p, err := s.provider(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	authRequest, err := s.identityRepo.ConsumeAuthRequest(ctx, state)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, ErrInvalidOIDCState
+		}
+		return nil, err
+	}
+	if authRequest.Provider != p.cfg.Name {
+		return nil, ErrInvalidOIDCState
+	}
+
+	token, err := p.oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(authRequest.CodeVerifier))
+	if err != nil {
+		return nil, ErrOIDCLoginFailed.Wrap(fmt.Errorf("failed to exchange authorization code: %w", err))
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrOIDCLoginFailed.Wrap(errors.New("token response did not contain an id_token"))
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, ErrOIDCLoginFailed.Wrap(fmt.Errorf("failed to verify id_token: %w", err))
+	}
+	if idToken.Nonce != authRequest.Nonce {
+		return nil, ErrOIDCLoginFailed.Wrap(errors.New("id_token nonce mismatch"))
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+		Picture       string `json:"picture"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse id_token claims: %w", err)
+	}
+
+	return s.resolveUser(ctx, p.cfg.Name, idToken.Subject, claims.Email, claims.EmailVerified, claims.Name, claims.Picture)
+}
+
+// resolveUser finds the user linked to (provider, subject). Unlinked identities are linked to
+// the user with the same verified email, or a new user is created for them.
+func (s *oidcService) resolveUser(ctx context.Context, provider, subject, email string, emailVerified bool, name, picture string) (*model.User, error) {
+	identity, err := s.identityRepo.GetIdentity(ctx, provider, subject)
+	if err == nil {
+		return s.userRepo.GetById(ctx, identity.UserID)
+	}
+	if !errors.Is(err, apperrors.ErrNotFound) {
+		return nil, err
+	}
+
+	// Never link or create accounts on an unverified address, otherwise anyone able to
+	// register that email at the provider could take over the local account.
+	if email == "" || !emailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, apperrors.ErrNotFound) {
+			return nil, err
+		}
+
+		// Users created through OIDC get an unguessable password. To set a real one
+		// with PUT /me/password, or make other account changes, they sign in with the
+		// provider again and leave the current password out (see reauthWindow).
+		password, err := utils.GenerateRandomToken(32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate password: %w", err)
+		}
+		user = &model.User{Email: email, Password: password}
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return nil, err
+		}
+		if name != "" || picture != "" {
+			if name != "" {
+				user.DisplayName = &name
+			}
+			if picture != "" {
+				user.AvatarURL = &picture
+			}
+			if err := s.userRepo.UpdateProfile(ctx, user); err != nil {
+				slog.WarnContext(ctx, "failed to store OIDC profile", "user_id", user.Id, "error", err)
+			}
+		}
+		slog.InfoContext(ctx, "created user from OIDC identity", "user_id", user.Id, "provider", provider)
+	}
+
+	err = s.identityRepo.CreateIdentity(ctx, &model.UserIdentity{
+		UserID:   user.Id,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *oidcService) GetIdentities(ctx context.Context, userID uuid.UUID) ([]model.UserIdentity, error) {
+	ctx, span := tracer.Start(ctx, "OidcService.GetIdentities")
+	defer span.End()
+
+	return s.identityRepo.GetIdentitiesByUserID(ctx, userID)
+}
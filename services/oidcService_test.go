@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"go-rest-api/apperrors"
+	"go-rest-api/config"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// fakeIdentityRepository keeps identities and pending auth requests in memory.
+type fakeIdentityRepository struct {
+	repository.IdentityRepository
+	mu           sync.Mutex
+	identities   []model.UserIdentity
+	authRequests map[string]*model.OIDCAuthRequest
+}
+
+func newFakeIdentityRepository() *fakeIdentityRepository {
+	return &fakeIdentityRepository{authRequests: map[string]*model.OIDCAuthRequest{}}
+}
+
+func (r *fakeIdentityRepository) CreateIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return apperrors.ErrAlreadyExists
+		}
+	}
+	identity.Id, identity.CreatedAt = uuid.New(), time.Now()
+	r.identities = append(r.identities, *identity)
+	return nil
+}
+
+func (r *fakeIdentityRepository) GetIdentity(ctx context.Context, provider string, subject string) (*model.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, apperrors.ErrNotFound
+}
+
+func (r *fakeIdentityRepository) GetIdentitiesByUserID(ctx context.Context, userID uuid.UUID) ([]model.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identities := make([]model.UserIdentity, 0)
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (r *fakeIdentityRepository) SaveAuthRequest(ctx context.Context, authRequest *model.OIDCAuthRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	saved := *authRequest
+	r.authRequests[authRequest.State] = &saved
+	return nil
+}
+
+func (r *fakeIdentityRepository) ConsumeAuthRequest(ctx context.Context, state string) (*model.OIDCAuthRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	authRequest, ok := r.authRequests[state]
+	delete(r.authRequests, state)
+	if !ok || !authRequest.ExpiresAt.After(time.Now()) {
+		return nil, apperrors.ErrNotFound
+	}
+	return authRequest, nil
+}
+
+// updateAuthRequest changes the pending auth request for state, to simulate a tampered
+// or expired login.
+func (r *fakeIdentityRepository) updateAuthRequest(state string, update func(*model.OIDCAuthRequest)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	update(r.authRequests[state])
+}
+
+const mockClientID = "test-client"
+
+// mockIssuer is an OpenID Connect provider serving discovery, its JWKS and a token
+// endpoint that enforces PKCE. Users "sign in" through authorize instead of a login page.
+type mockIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+// mockGrant is an issued authorization code.
+type mockGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	m := &mockIssuer{key: key, grants: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"jwks_uri":                              m.URL + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", m.token)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// token redeems an authorization code once, if the code_verifier matches its challenge.
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	m.mu.Lock()
+	grant, ok := m.grants[r.PostForm.Get("code")]
+	delete(m.grants, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		oauth2.S256ChallengeFromVerifier(r.PostForm.Get("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// authorize plays the user signing in at the provider as subject, following the
+// authorization URL. It returns the state and the code to pass to the callback. claims
+// are added to the ID token, and can override the nonce taken from the URL.
+func (m *mockIssuer) authorize(t *testing.T, authURL string, subject string, claims jwt.MapClaims) (string, string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, m.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	query := u.Query()
+	assert.Equal(t, mockClientID, query.Get("client_id"))
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	require.NotEmpty(t, query.Get("code_challenge"))
+	require.NotEmpty(t, query.Get("state"))
+	require.NotEmpty(t, query.Get("nonce"))
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   m.URL,
+		"aud":   mockClientID,
+		"sub":   subject,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code := uuid.NewString()
+	m.mu.Lock()
+	m.grants[code] = mockGrant{challenge: query.Get("code_challenge"), claims: idClaims}
+	m.mu.Unlock()
+	return query.Get("state"), code
+}
+
+func newTestOIDCService(t *testing.T, users ...*model.User) (OIDCService, *mockIssuer, *fakeIdentityRepository, *fakeUserRepository) {
+	t.Helper()
+	issuer := newMockIssuer(t)
+	identities := newFakeIdentityRepository()
+	userRepo := newFakeUserRepository(users...)
+	providers := []config.OIDCProviderConfig{
+		{Name: "mock", IssuerURL: issuer.URL, ClientID: mockClientID, ClientSecret: "secret", RedirectURL: "http://localhost/auth/oidc/mock/callback"},
+		{Name: "other", IssuerURL: issuer.URL, ClientID: mockClientID, ClientSecret: "secret", RedirectURL: "http://localhost/auth/oidc/other/callback"},
+	}
+	return NewOIDCService(identities, userRepo, providers), issuer, identities, userRepo
+}
+
+var verifiedEmail = jwt.MapClaims{"email": "ada@example.com", "email_verified": true}
+
+func TestOIDCLoginCreatesUser(t *testing.T) {
+	service, issuer, identities, users := newTestOIDCService(t)
+	ctx := context.Background()
+
+	authURL, err := service.AuthorizationURL(ctx, "mock")
+	require.NoError(t, err)
+	state, code := issuer.authorize(t, authURL, "ada", jwt.MapClaims{"email": "ada@example.com", "email_verified": true, "name": "Ada"})
+
+	user, err := service.HandleCallback(ctx, "mock", state, code)
+	require.NoError(t, err)
+	assert.Equal(t, "ada@example.com", user.Email)
+	assert.Equal(t, 1, users.count())
+	stored, err := users.GetById(ctx, user.Id)
+	require.NoError(t, err)
+	require.NotNil(t, stored.DisplayName)
+	assert.Equal(t, "Ada", *stored.DisplayName)
+
+	linked, err := identities.GetIdentitiesByUserID(ctx, user.Id)
+	require.NoError(t, err)
+	require.Len(t, linked, 1)
+	assert.Equal(t, "mock", linked[0].Provider)
+	assert.Equal(t, "ada", linked[0].Subject)
+
+	// Signing in again finds the user through the identity, even with another email
+	authURL, err = service.AuthorizationURL(ctx, "mock")
+	require.NoError(t, err)
+	state, code = issuer.authorize(t, authURL, "ada", jwt.MapClaims{"email": "ada@elsewhere.example", "email_verified": true})
+	again, err := service.HandleCallback(ctx, "mock", state, code)
+	require.NoError(t, err)
+	assert.Equal(t, user.Id, again.Id)
+	assert.Equal(t, 1, users.count())
+}
+
+func TestOIDCLoginLinksExistingUser(t *testing.T) {
+	existing := &model.User{Id: uuid.New(), Email: "ada@example.com", Password: "password123", Role: "admin"}
+	service, issuer, identities, users := newTestOIDCService(t, existing)
+	ctx := context.Background()
+
+	authURL, err := service.AuthorizationURL(ctx, "mock")
+	require.NoError(t, err)
+	state, code := issuer.authorize(t, authURL, "ada", verifiedEmail)
+
+	user, err := service.HandleCallback(ctx, "mock", state, code)
+	require.NoError(t, err)
+	assert.Equal(t, existing.Id, user.Id)
+	assert.Equal(t, "admin", user.Role)
+	assert.Equal(t, 1, users.count())
+
+	linked, err := identities.GetIdentitiesByUserID(ctx, existing.Id)
+	require.NoError(t, err)
+	assert.Len(t, linked, 1)
+}
+
+func TestOIDCLoginRequiresVerifiedEmail(t *testing.T) {
+	existing := &model.User{Id: uuid.New(), Email: "ada@example.com", Password: "password123"}
+	service, issuer, identities, _ := newTestOIDCService(t, existing)
+	ctx := context.Background()
+
+	authURL, err := service.AuthorizationURL(ctx, "mock")
+	require.NoError(t, err)
+	state, code := issuer.authorize(t, authURL, "mallory", jwt.MapClaims{"email": "ada@example.com", "email_verified": false})
+
+	_, err = service.HandleCallback(ctx, "mock", state, code)
+	assert.ErrorIs(t, err, ErrOIDCEmailNotVerified)
+	linked, err := identities.GetIdentitiesByUserID(ctx, existing.Id)
+	require.NoError(t, err)
+	assert.Empty(t, linked, "unverified emails are never linked")
+}
+
+func TestOIDCLoginSendsStoredPKCEVerifier(t *testing.T) {
+	service, issuer, identities, _ := newTestOIDCService(t)
+	ctx := context.Background()
+
+	authURL, err := service.AuthorizationURL(ctx, "mock")
+	require.NoError(t, err)
+	state, code := issuer.authorize(t, authURL, "ada", verifiedEmail)
+	// The verifier doesn't match the challenge in the URL any more, so the issuer
+	// refuses the code.
+	identities.updateAuthRequest(state, func(authRequest *model.OIDCAuthRequest) {
+		authRequest.CodeVerifier = oauth2.GenerateVerifier()
+	})
+
+	_, err = service.HandleCallback(ctx, "mock", state, code)
+	assert.ErrorIs(t, err, ErrOIDCLoginFailed)
+}
+
+func TestOIDCLoginRejectsNonceMismatch(t *testing.T) {
+	service, issuer, _, users := newTestOIDCService(t)
+	ctx := context.Background()
+
+	authURL, err := service.AuthorizationURL(ctx, "mock")
+	require.NoError(t, err)
+	state, code := issuer.authorize(t, authURL, "ada", jwt.MapClaims{"email": "ada@example.com", "email_verified": true, "nonce": "replayed"})
+
+	_, err = service.HandleCallback(ctx, "mock", state, code)
+	assert.ErrorIs(t, err, ErrOIDCLoginFailed)
+	assert.Equal(t, 0, users.count())
+}
+
+func TestOIDCLoginStateIsSingleUse(t *testing.T) {
+	service, issuer, _, _ := newTestOIDCService(t)
+	ctx := context.Background()
+
+	authURL, err := service.AuthorizationURL(ctx, "mock")
+	require.NoError(t, err)
+	state, code := issuer.authorize(t, authURL, "ada", verifiedEmail)
+
+	_, err = service.HandleCallback(ctx, "mock", state, code)
+	require.NoError(t, err)
+	_, err = service.HandleCallback(ctx, "mock", state, code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+	_, err = service.HandleCallback(ctx, "mock", "unknown-state", code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+}
+
+func TestOIDCLoginRejectsExpiredState(t *testing.T) {
+	service, issuer, identities, _ := newTestOIDCService(t)
+	ctx := context.Background()
+
+	authURL, err := service.AuthorizationURL(ctx, "mock")
+	require.NoError(t, err)
+	state, code := issuer.authorize(t, authURL, "ada", verifiedEmail)
+	identities.updateAuthRequest(state, func(authRequest *model.OIDCAuthRequest) {
+		authRequest.ExpiresAt = time.Now().Add(-time.Second)
+	})
+
+	_, err = service.HandleCallback(ctx, "mock", state, code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+}
+
+func TestOIDCLoginRejectsStateOfOtherProvider(t *testing.T) {
+	service, issuer, _, _ := newTestOIDCService(t)
+	ctx := context.Background()
+
+	authURL, err := service.AuthorizationURL(ctx, "mock")
+	require.NoError(t, err)
+	state, code := issuer.authorize(t, authURL, "ada", verifiedEmail)
+
+	_, err = service.HandleCallback(ctx, "other", state, code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+}
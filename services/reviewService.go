@@ -2,79 +2,106 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt" // Added for fmt.Errorf
+	"go-rest-api/apperrors"
+	"go-rest-api/metrics"
 	"go-rest-api/model"
 	"go-rest-api/repository"
-	"log"
+	"go-rest-api/worker"
 
 	"github.com/google/uuid"
 )
 
+var ErrAlreadyReviewed = apperrors.Conflict("already_reviewed", "you have already reviewed this event")
+
 type ReviewService interface {
 	CreateReview(ctx context.Context, review *model.Review, userID uuid.UUID) error
 	GetReviewsForEvent(ctx context.Context, eventID uuid.UUID) ([]model.Review, error)
 	// CheckIfUserRegisteredForEvent(ctx context.Context, eventID uuid.UUID, userID uuid.UUID) (bool, error)
 }
 
+// JobUpdateAverageRating recalculates an event's average rating after a new review.
+const JobUpdateAverageRating = "reviews.update_average_rating"
+
 type reviewService struct {
 	reviewRepo repository.ReviewRepository
 	eventRepo  repository.EventRepository // To check if user is registered for the event
+	jobs       *worker.Group
 }
 
-func NewReviewService(reviewRepo repository.ReviewRepository, eventRepo repository.EventRepository) ReviewService {
-	return &reviewService{
+func NewReviewService(reviewRepo repository.ReviewRepository, eventRepo repository.EventRepository, jobs *worker.Group) ReviewService {
+	s := &reviewService{
 		reviewRepo: reviewRepo,
 		eventRepo:  eventRepo,
+		jobs:       jobs,
 	}
+	jobs.Handle(JobUpdateAverageRating, s.updateAverageRatingJob)
+	return s
 }
 
-func (s *reviewService) CreateReview(ctx context.Context, review *model.Review, userID uuid.UUID) error {
+func (s *reviewService) CreateReview(ctx context.Context, review *model.Review, userID uuid.UUID) (err error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.CreateReview")
+	defer span.End()
+
+	defer func() { metrics.ReviewsCreated.WithLabelValues(metrics.Result(err)).Inc() }()
+
 	// Validate event exists
-	_, err := s.eventRepo.GetEventById(ctx, review.EventID)
+	_, err = getEvent(ctx, s.eventRepo, review.EventID)
 	if err != nil {
-		log.Printf("Error finding event %d for review: %v", review.EventID, err)
-		return errors.New("event not found")
+		return err
 	}
 
 	// Check if the user has already reviewed this event
 	existingReview, err := s.reviewRepo.GetReviewByEventAndUser(ctx, review.EventID, userID)
 	if err != nil {
 		// This is an actual error during DB query, not "not found"
-		log.Printf("Error checking existing review for event %d by user %d: %v", review.EventID, userID, err)
-		return errors.New("failed to check for existing reviews")
+		return fmt.Errorf("failed to check for existing reviews: %w", err)
 	}
 	if existingReview != nil {
-		return errors.New("you have already reviewed this event")
+		return ErrAlreadyReviewed
 	}
 
 	review.UserID = userID // Ensure the review is associated with the authenticated user
 	err = s.reviewRepo.SaveReview(ctx, review)
+	if errors.Is(err, apperrors.ErrAlreadyExists) {
+		return ErrAlreadyReviewed
+	}
 	if err != nil {
 		return err
 	}
 
 	// After saving a new review, recalculate and update the event's average rating
-	go func() { // Run in a goroutine so it doesn't block the response
-		err := s.recalculateAndUpdateAverageRating(context.Background(), review.EventID)
-		if err != nil {
-			log.Printf("Error updating average rating for event %d after new review: %v", review.EventID, err)
-		}
-	}()
+	// Run in the background so it doesn't block the response
+	s.jobs.Submit(ctx, JobUpdateAverageRating, eventJobPayload{EventID: review.EventID})
 
 	return nil
 }
 
 func (s *reviewService) GetReviewsForEvent(ctx context.Context, eventID uuid.UUID) ([]model.Review, error) {
+	ctx, span := tracer.Start(ctx, "ReviewService.GetReviewsForEvent")
+	defer span.End()
+
 	// Validate event exists (optional, as above)
-	_, err := s.eventRepo.GetEventById(ctx, eventID)
+	_, err := getEvent(ctx, s.eventRepo, eventID)
 	if err != nil {
-		log.Printf("Error finding event %d when fetching reviews: %v", eventID, err)
-		return nil, errors.New("event not found")
+		return nil, err
 	}
 	return s.reviewRepo.GetReviewsByEventID(ctx, eventID)
 }
 
+func (s *reviewService) updateAverageRatingJob(ctx context.Context, payload json.RawMessage) error {
+	var job eventJobPayload
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return fmt.Errorf("invalid average rating job payload: %w", err)
+	}
+	if err := s.recalculateAndUpdateAverageRating(ctx, job.EventID); err != nil {
+		return fmt.Errorf("failed to update average rating after new review: %w", err)
+	}
+	return nil
+}
+
 func (s *reviewService) recalculateAndUpdateAverageRating(ctx context.Context, eventID uuid.UUID) error {
 	reviews, err := s.reviewRepo.GetReviewsByEventID(ctx, eventID)
 	if err != nil {
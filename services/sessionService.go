@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+
+	"github.com/google/uuid"
+)
+
+var ErrSessionNotFound = apperrors.NotFound("session_not_found", "session not found")
+var ErrInvalidSessionTime = apperrors.BadRequest("invalid_session_time", "session must end after it starts, and take place during its event")
+var ErrSessionCapacityTooSmall = apperrors.Conflict("session_capacity_too_small", "more attendees are registered for the session than the new capacity")
+var ErrNotRegisteredForEvent = apperrors.Forbidden("not_registered_for_event", "register for the event before registering for its sessions")
+var ErrAlreadyRegisteredForSession = apperrors.Conflict("already_registered_for_session", "user is already registered for this session")
+var ErrNotRegisteredForSession = apperrors.NotFound("not_registered_for_session", "user is not registered for this session")
+var ErrSessionFull = apperrors.Conflict("session_full", "session is full")
+var ErrSessionOverlap = apperrors.Conflict("session_overlap", "session overlaps another session you registered for")
+var ErrSessionUnavailable = apperrors.Conflict("session_unavailable", "session is full or overlaps another session you registered for")
+
+type SessionService interface {
+	// GetSessions returns the agenda of the event.
+	GetSessions(ctx context.Context, eventID uuid.UUID) ([]model.Session, error)
+	// CreateSession adds a session to the event. Only the event's owner or an admin may.
+	CreateSession(ctx context.Context, session *model.Session, userID uuid.UUID, userRole string) error
+	// UpdateSession changes the fields of the session that are set.
+	UpdateSession(ctx context.Context, session *model.Session, userID uuid.UUID, userRole string) error
+	DeleteSession(ctx context.Context, eventID, sessionID uuid.UUID, userID uuid.UUID, userRole string) error
+	// RegisterForSession registers an attendee of the event for one of its sessions,
+	// unless it is full or overlaps a session they already registered for.
+	RegisterForSession(ctx context.Context, eventID, sessionID, userID uuid.UUID) error
+	CancelSessionRegistration(ctx context.Context, eventID, sessionID, userID uuid.UUID) error
+	// GetRegisteredSessions returns the user's sessions across events, by start time.
+	GetRegisteredSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error)
+}
+
+type sessionService struct {
+	sessionRepository repository.SessionRepository
+	eventRepository   repository.EventRepository
+	speakerRepository repository.SpeakerRepository
+}
+
+func NewSessionService(sessionRepository repository.SessionRepository, eventRepository repository.EventRepository, speakerRepository repository.SpeakerRepository) SessionService {
+	return &sessionService{sessionRepository: sessionRepository, eventRepository: eventRepository, speakerRepository: speakerRepository}
+}
+
+// getSession returns the session if it belongs to the event.
+func (s *sessionService) getSession(ctx context.Context, eventID, sessionID uuid.UUID) (*model.Session, error) {
+	session, err := s.sessionRepository.GetByID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	if session.EventID != eventID {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// ownedEvent returns the event if the user may change its agenda.
+func (s *sessionService) ownedEvent(ctx context.Context, eventID uuid.UUID, userID uuid.UUID, userRole string) (*model.Event, error) {
+	event, err := getEvent(ctx, s.eventRepository, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event.UserIds != userID && userRole != "admin" {
+		return nil, ErrNotEventOwner
+	}
+	return event, nil
+}
+
+// checkSessionTime checks that the session ends after it starts, not before its
+// event starts and, if the event has an end, not after it ends.
+func checkSessionTime(session *model.Session, event *model.Event) error {
+	if !session.EndTime.After(*session.StartTime) {
+		return ErrInvalidSessionTime
+	}
+	if event.Date != nil && session.StartTime.Before(*event.Date) {
+		return ErrInvalidSessionTime
+	}
+	if event.EndDate != nil && session.EndTime.After(*event.EndDate) {
+		return ErrInvalidSessionTime
+	}
+	return nil
+}
+
+func (s *sessionService) GetSessions(ctx context.Context, eventID uuid.UUID) ([]model.Session, error) {
+	ctx, span := tracer.Start(ctx, "SessionService.GetSessions")
+	defer span.End()
+
+	if _, err := getEvent(ctx, s.eventRepository, eventID); err != nil {
+		return nil, err
+	}
+	return s.sessionRepository.GetByEvent(ctx, eventID)
+}
+
+func (s *sessionService) CreateSession(ctx context.Context, session *model.Session, userID uuid.UUID, userRole string) error {
+	ctx, span := tracer.Start(ctx, "SessionService.CreateSession")
+	defer span.End()
+
+	event, err := s.ownedEvent(ctx, session.EventID, userID, userRole)
+	if err != nil {
+		return err
+	}
+	if err := checkSessionTime(session, event); err != nil {
+		return err
+	}
+	if session.SpeakerIDs, err = checkSpeakers(ctx, s.speakerRepository, session.SpeakerIDs); err != nil {
+		return err
+	}
+	return s.sessionRepository.Create(ctx, session)
+}
+
+func (s *sessionService) UpdateSession(ctx context.Context, session *model.Session, userID uuid.UUID, userRole string) error {
+	ctx, span := tracer.Start(ctx, "SessionService.UpdateSession")
+	defer span.End()
+
+	event, err := s.ownedEvent(ctx, session.EventID, userID, userRole)
+	if err != nil {
+		return err
+	}
+	existingSession, err := s.getSession(ctx, session.EventID, session.Id)
+	if err != nil {
+		return err
+	}
+
+	if session.Title != nil {
+		existingSession.Title = session.Title
+	}
+	if session.Track != nil {
+		existingSession.Track = session.Track
+	}
+	if session.Speaker != nil {
+		existingSession.Speaker = session.Speaker
+	}
+	if session.Room != nil {
+		existingSession.Room = session.Room
+	}
+	if session.StartTime != nil {
+		existingSession.StartTime = session.StartTime
+	}
+	if session.EndTime != nil {
+		existingSession.EndTime = session.EndTime
+	}
+	if session.Capacity != nil {
+		if *session.Capacity < existingSession.Registered {
+			return ErrSessionCapacityTooSmall.WithDetails(map[string]interface{}{"registered": existingSession.Registered})
+		}
+		existingSession.Capacity = session.Capacity
+	}
+	if err := checkSessionTime(existingSession, event); err != nil {
+		return err
+	}
+	// nil keeps the speakers
+	if existingSession.SpeakerIDs, err = checkSpeakers(ctx, s.speakerRepository, session.SpeakerIDs); err != nil {
+		return err
+	}
+
+	if err := s.sessionRepository.Update(ctx, existingSession); err != nil {
+		return err
+	}
+	*session = *existingSession
+	return nil
+}
+
+func (s *sessionService) DeleteSession(ctx context.Context, eventID, sessionID uuid.UUID, userID uuid.UUID, userRole string) error {
+	ctx, span := tracer.Start(ctx, "SessionService.DeleteSession")
+	defer span.End()
+
+	if _, err := s.ownedEvent(ctx, eventID, userID, userRole); err != nil {
+		return err
+	}
+	if _, err := s.getSession(ctx, eventID, sessionID); err != nil {
+		return err
+	}
+	return s.sessionRepository.Delete(ctx, sessionID)
+}
+
+func (s *sessionService) RegisterForSession(ctx context.Context, eventID, sessionID, userID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "SessionService.RegisterForSession")
+	defer span.End()
+
+	session, err := s.getSession(ctx, eventID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	isRegistered, err := s.eventRepository.IsUserRegistered(ctx, eventID, userID)
+	if err != nil {
+		return err
+	}
+	if !isRegistered {
+		return ErrNotRegisteredForEvent
+	}
+
+	overlapping, err := s.sessionRepository.GetOverlapping(ctx, userID, *session.StartTime, *session.EndTime, session.Id)
+	if err != nil {
+		return err
+	}
+	if len(overlapping) > 0 {
+		return ErrSessionOverlap.WithDetails(map[string]interface{}{"conflicting_sessions": overlapping})
+	}
+	if session.Capacity != nil && session.Registered >= *session.Capacity {
+		return ErrSessionFull
+	}
+
+	err = s.sessionRepository.Register(ctx, session, userID)
+	switch {
+	case errors.Is(err, apperrors.ErrAlreadyExists):
+		return ErrAlreadyRegisteredForSession
+	case errors.Is(err, apperrors.ErrNotFound):
+		// The event registration or the session was removed after the checks above
+		return ErrNotRegisteredForEvent
+	case errors.Is(err, apperrors.ErrConflict):
+		// A concurrent request took the last seat or picked an overlapping session
+		return ErrSessionUnavailable
+	}
+	return err
+}
+
+func (s *sessionService) CancelSessionRegistration(ctx context.Context, eventID, sessionID, userID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "SessionService.CancelSessionRegistration")
+	defer span.End()
+
+	if _, err := s.getSession(ctx, eventID, sessionID); err != nil {
+		return err
+	}
+	err := s.sessionRepository.CancelRegistration(ctx, sessionID, userID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return ErrNotRegisteredForSession
+	}
+	return err
+}
+
+func (s *sessionService) GetRegisteredSessions(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	ctx, span := tracer.Start(ctx, "SessionService.GetRegisteredSessions")
+	defer span.End()
+
+	return s.sessionRepository.GetByUser(ctx, userID)
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrSpeakerNotFound = apperrors.NotFound("speaker_not_found", "speaker not found")
+var ErrNotSpeakerOwner = apperrors.Forbidden("not_speaker_owner", "you don't have permission to modify this speaker")
+var ErrUnknownSpeaker = apperrors.BadRequest("unknown_speaker", "speaker doesn't exist, see GET /speakers")
+
+type SpeakerService interface {
+	CreateSpeaker(ctx context.Context, speaker *model.Speaker) error
+	// GetSpeakers returns the speakers by name, only those whose name contains name
+	// if it isn't empty.
+	GetSpeakers(ctx context.Context, name string) ([]model.Speaker, error)
+	GetSpeaker(ctx context.Context, id uuid.UUID) (*model.Speaker, error)
+	// GetSpeakerEvents returns the upcoming events of the speaker, including those
+	// where they only speak in a session.
+	GetSpeakerEvents(ctx context.Context, id uuid.UUID) ([]model.Event, error)
+	// UpdateSpeaker changes the fields of the speaker that are set. Only its creator
+	// or an admin may change it.
+	UpdateSpeaker(ctx context.Context, speaker *model.Speaker, userID uuid.UUID, userRole string) error
+	DeleteSpeaker(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) error
+}
+
+type speakerService struct {
+	speakerRepository repository.SpeakerRepository
+	eventRepository   repository.EventRepository
+}
+
+func NewSpeakerService(speakerRepository repository.SpeakerRepository, eventRepository repository.EventRepository) SpeakerService {
+	return &speakerService{speakerRepository: speakerRepository, eventRepository: eventRepository}
+}
+
+// checkSpeakers returns the speaker IDs without duplicates, or ErrUnknownSpeaker
+// listing the IDs of speakers that don't exist. nil stays nil.
+func checkSpeakers(ctx context.Context, speakerRepo repository.SpeakerRepository, ids []uuid.UUID) ([]uuid.UUID, error) {
+	if len(ids) == 0 {
+		return ids, nil
+	}
+	unique := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	speakers, err := speakerRepo.GetByIDs(ctx, unique)
+	if err != nil {
+		return nil, err
+	}
+	if len(speakers) == len(unique) {
+		return unique, nil
+	}
+	for _, speaker := range speakers {
+		delete(seen, speaker.Id)
+	}
+	unknown := make([]uuid.UUID, 0, len(seen))
+	for _, id := range unique {
+		if seen[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	return nil, ErrUnknownSpeaker.WithDetails(map[string]interface{}{"unknown_speakers": unknown})
+}
+
+func (s *speakerService) CreateSpeaker(ctx context.Context, speaker *model.Speaker) error {
+	ctx, span := tracer.Start(ctx, "SpeakerService.CreateSpeaker")
+	defer span.End()
+
+	return s.speakerRepository.Create(ctx, speaker)
+}
+
+func (s *speakerService) GetSpeakers(ctx context.Context, name string) ([]model.Speaker, error) {
+	ctx, span := tracer.Start(ctx, "SpeakerService.GetSpeakers")
+	defer span.End()
+
+	return s.speakerRepository.GetAll(ctx, strings.TrimSpace(name))
+}
+
+func (s *speakerService) GetSpeaker(ctx context.Context, id uuid.UUID) (*model.Speaker, error) {
+	ctx, span := tracer.Start(ctx, "SpeakerService.GetSpeaker")
+	defer span.End()
+
+	speaker, err := s.speakerRepository.GetByID(ctx, id)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, ErrSpeakerNotFound
+	}
+	return speaker, err
+}
+
+func (s *speakerService) GetSpeakerEvents(ctx context.Context, id uuid.UUID) ([]model.Event, error) {
+	ctx, span := tracer.Start(ctx, "SpeakerService.GetSpeakerEvents")
+	defer span.End()
+
+	if _, err := s.GetSpeaker(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.eventRepository.GetUpcomingEventsBySpeaker(ctx, id)
+}
+
+func (s *speakerService) UpdateSpeaker(ctx context.Context, speaker *model.Speaker, userID uuid.UUID, userRole string) error {
+	ctx, span := tracer.Start(ctx, "SpeakerService.UpdateSpeaker")
+	defer span.End()
+
+	existingSpeaker, err := s.GetSpeaker(ctx, speaker.Id)
+	if err != nil {
+		return err
+	}
+	if existingSpeaker.UserID != userID && userRole != "admin" {
+		return ErrNotSpeakerOwner
+	}
+
+	if speaker.Name != nil {
+		existingSpeaker.Name = speaker.Name
+	}
+	if speaker.Bio != nil {
+		existingSpeaker.Bio = speaker.Bio
+	}
+	if speaker.PhotoURL != nil {
+		existingSpeaker.PhotoURL = speaker.PhotoURL
+	}
+	if speaker.Links != nil {
+		existingSpeaker.Links = speaker.Links
+	}
+
+	if err := s.speakerRepository.Update(ctx, existingSpeaker); err != nil {
+		return err
+	}
+	*speaker = *existingSpeaker
+	return nil
+}
+
+func (s *speakerService) DeleteSpeaker(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) error {
+	ctx, span := tracer.Start(ctx, "SpeakerService.DeleteSpeaker")
+	defer span.End()
+
+	speaker, err := s.GetSpeaker(ctx, id)
+	if err != nil {
+		return err
+	}
+	if speaker.UserID != userID && userRole != "admin" {
+		return ErrNotSpeakerOwner
+	}
+	return s.speakerRepository.Delete(ctx, id)
+}
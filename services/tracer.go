@@ -0,0 +1,6 @@
+package services
+
+import "go.opentelemetry.io/otel"
+
+// tracer starts a span for each service method, named "<Service>.<Method>".
+var tracer = otel.Tracer("go-rest-api/services")
@@ -2,68 +2,301 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"go-rest-api/apperrors"
 	"go-rest-api/model"
 	"go-rest-api/repository"
+	"go-rest-api/utils"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+var ErrInvalidVerificationToken = apperrors.BadRequest("invalid_verification_token", "invalid or expired verification token")
+var ErrUserNotFound = apperrors.NotFound("user_not_found", "user not found")
+var ErrEmailTaken = apperrors.Conflict("email_taken", "email already registered")
+var ErrInvalidCredentials = apperrors.Unauthorized("invalid_credentials", "invalid credentials")
+var ErrIncorrectPassword = apperrors.Unauthorized("incorrect_password", "password is incorrect")
+var ErrPasswordRequired = apperrors.Unauthorized("password_required", "password is required; accounts linked to an identity provider can sign in with it again instead")
+var ErrInvalidRole = apperrors.BadRequest("invalid_role", "role must be user or admin")
+
+const emailVerificationTTL = 24 * time.Hour
+
+// reauthWindow is how recently a user with a linked identity must have signed in with
+// it to change their account without a password.
+const reauthWindow = 5 * time.Minute
+
+// Credentials prove that the caller of an account change is the account's owner.
+type Credentials struct {
+	// Password is the user's current password. It may be empty for accounts linked to
+	// an identity provider, if they signed in with it within reauthWindow.
+	Password string
+	ClientIP string
+	// AuthTime is when the caller's token was issued, zero if unknown.
+	AuthTime time.Time
+	// AuthMethods are how the caller signed in, from the token's amr claim.
+	AuthMethods []string
+}
+
 type UserService interface {
 	CreateUser(ctx context.Context, user *model.User) error
-	ValidateUser(ctx context.Context, user *model.User) error
+	ValidateUser(ctx context.Context, user *model.User, clientIP string) error
 	GetAllUsers(ctx context.Context) ([]model.User, error)
 	GetUserByID(ctx context.Context, id uuid.UUID) (*model.User, error)
 	UpdateUser(ctx context.Context, user *model.User) error
 	DeleteUser(ctx context.Context, id uuid.UUID) error
+	UpdateProfile(ctx context.Context, user *model.User) (*model.User, error)
+	// ChangePassword, RequestEmailChange and DeleteAccount check the current password
+	// and are throttled like logins. Users with a linked identity, who may never have
+	// had a password, can instead sign in with their provider again shortly before.
+	ChangePassword(ctx context.Context, id uuid.UUID, creds Credentials, newPassword string) error
+	RequestEmailChange(ctx context.Context, id uuid.UUID, newEmail string, creds Credentials) error
+	ConfirmEmailChange(ctx context.Context, id uuid.UUID, token string) (*model.User, error)
+	DeleteAccount(ctx context.Context, id uuid.UUID, creds Credentials) error
 }
 
 type userService struct {
 	userRepository repository.UserRepository
+	emailSender    EmailSender
+	loginGuard     LoginGuardService
+	identityRepo   repository.IdentityRepository
 }
 
-func NewUserService(userRepository repository.UserRepository) UserService {
+func NewUserService(userRepository repository.UserRepository, emailSender EmailSender, loginGuard LoginGuardService, identityRepo repository.IdentityRepository) UserService {
 	return &userService{
 		userRepository: userRepository,
+		emailSender:    emailSender,
+		loginGuard:     loginGuard,
+		identityRepo:   identityRepo,
 	}
 }
 
 func (e *userService) CreateUser(ctx context.Context, user *model.User) error {
-	if user.Email == "" {
-		return apperrors.ErrInvalidInput
-	}
-	if user.Password == "" {
-		return apperrors.ErrInvalidInput
+	ctx, span := tracer.Start(ctx, "UserService.CreateUser")
+	defer span.End()
+
+	if user.Email == "" || user.Password == "" {
+		return apperrors.ErrInvalidInput.WithMessage("email and password are required")
 	}
 
-	// The check for existing user is now handled by the repository,
+	// The check for existing user is handled by the repository,
 	// which returns apperrors.ErrAlreadyExists.
-	return e.userRepository.Create(ctx, user)
+	return userError(e.userRepository.Create(ctx, user))
 }
 
 func (e *userService) GetAllUsers(ctx context.Context) ([]model.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetAllUsers")
+	defer span.End()
+
 	return e.userRepository.GetAll(ctx)
 }
 
 func (e *userService) GetUserByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
-	// The repository now correctly returns apperrors.ErrNotFound.
-	return e.userRepository.GetById(ctx, id)
+	ctx, span := tracer.Start(ctx, "UserService.GetUserByID")
+	defer span.End()
+
+	user, err := e.userRepository.GetById(ctx, id)
+	return user, userError(err)
 }
 
 func (e *userService) UpdateUser(ctx context.Context, user *model.User) error {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateUser")
+	defer span.End()
+
 	if user.Role != "user" && user.Role != "admin" {
-		return apperrors.ErrInvalidInput
+		return ErrInvalidRole
 	}
 
 	// The check for an existing user is handled by the repository,
 	// which returns apperrors.ErrNotFound.
-	return e.userRepository.Update(ctx, user)
+	return userError(e.userRepository.Update(ctx, user))
 }
 
 func (e *userService) DeleteUser(ctx context.Context, id uuid.UUID) error {
-	return e.userRepository.Delete(ctx, id)
+	ctx, span := tracer.Start(ctx, "UserService.DeleteUser")
+	defer span.End()
+
+	return userError(e.userRepository.Delete(ctx, id))
 }
 
-func (e *userService) ValidateUser(ctx context.Context, user *model.User) error {
-	return e.userRepository.Validate(ctx, user)
+// ValidateUser checks the user's credentials. Repeated failures from the same account or
+// client IP are delayed and eventually locked out, see LoginGuardService.
+func (e *userService) ValidateUser(ctx context.Context, user *model.User, clientIP string) error {
+	ctx, span := tracer.Start(ctx, "UserService.ValidateUser")
+	defer span.End()
+
+	email := user.Email
+	if err := e.loginGuard.Check(ctx, email, clientIP); err != nil {
+		return err
+	}
+
+	err := e.userRepository.Validate(ctx, user)
+	if errors.Is(err, apperrors.ErrUnauthorized) || errors.Is(err, apperrors.ErrNotFound) {
+		if recordErr := e.loginGuard.RecordFailure(ctx, email, clientIP); recordErr != nil {
+			return recordErr
+		}
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return err
+	}
+
+	return e.loginGuard.RecordSuccess(ctx, email)
+}
+
+// UpdateProfile applies the non-nil profile fields of user to the stored account.
+func (e *userService) UpdateProfile(ctx context.Context, user *model.User) (*model.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateProfile")
+	defer span.End()
+
+	existingUser, err := e.userRepository.GetById(ctx, user.Id)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	if user.DisplayName != nil {
+		existingUser.DisplayName = user.DisplayName
+	}
+	if user.AvatarURL != nil {
+		existingUser.AvatarURL = user.AvatarURL
+	}
+	if user.Preferences != nil {
+		existingUser.Preferences = user.Preferences
+	}
+
+	err = e.userRepository.UpdateProfile(ctx, existingUser)
+	if err != nil {
+		return nil, userError(err)
+	}
+	return existingUser, nil
+}
+
+func (e *userService) ChangePassword(ctx context.Context, id uuid.UUID, creds Credentials, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+
+	if err := e.checkCredentials(ctx, id, creds); err != nil {
+		return err
+	}
+	return userError(e.userRepository.UpdatePassword(ctx, id, newPassword))
+}
+
+// RequestEmailChange stores newEmail as pending and sends a verification token to it.
+// The address only changes once ConfirmEmailChange is called with that token.
+func (e *userService) RequestEmailChange(ctx context.Context, id uuid.UUID, newEmail string, creds Credentials) error {
+	ctx, span := tracer.Start(ctx, "UserService.RequestEmailChange")
+	defer span.End()
+
+	if err := e.checkCredentials(ctx, id, creds); err != nil {
+		return err
+	}
+
+	_, err := e.userRepository.GetByEmail(ctx, newEmail)
+	if err == nil {
+		return ErrEmailTaken
+	}
+	if !errors.Is(err, apperrors.ErrNotFound) {
+		return err
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	err = e.userRepository.SetPendingEmail(ctx, id, newEmail, utils.HashToken(token), time.Now().Add(emailVerificationTTL))
+	if err != nil {
+		return userError(err)
+	}
+
+	return e.emailSender.SendEmailVerification(ctx, newEmail, token)
+}
+
+func (e *userService) ConfirmEmailChange(ctx context.Context, id uuid.UUID, token string) (*model.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.ConfirmEmailChange")
+	defer span.End()
+
+	err := e.userRepository.ConfirmPendingEmail(ctx, id, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, userError(err)
+	}
+	return e.GetUserByID(ctx, id)
+}
+
+func (e *userService) DeleteAccount(ctx context.Context, id uuid.UUID, creds Credentials) error {
+	ctx, span := tracer.Start(ctx, "UserService.DeleteAccount")
+	defer span.End()
+
+	if err := e.checkCredentials(ctx, id, creds); err != nil {
+		return err
+	}
+	return userError(e.userRepository.Delete(ctx, id))
+}
+
+// checkCredentials returns ErrIncorrectPassword if the password does not belong to the
+// user. Failures count towards the same lockout as failed logins, so a stolen token
+// can't be used to guess the password.
+//
+// Without a password it returns ErrPasswordRequired, unless the user has a linked
+// identity and signed in with a provider within reauthWindow: OIDC users get a random
+// password they don't know, so signing in with their provider again stands in for it.
+func (e *userService) checkCredentials(ctx context.Context, id uuid.UUID, creds Credentials) error {
+	user, err := e.userRepository.GetById(ctx, id)
+	if err != nil {
+		return userError(err)
+	}
+	if creds.Password == "" {
+		return e.checkRecentSignIn(ctx, id, creds)
+	}
+	if err := e.loginGuard.Check(ctx, user.Email, creds.ClientIP); err != nil {
+		return err
+	}
+
+	err = e.userRepository.Validate(ctx, &model.User{Email: user.Email, Password: creds.Password})
+	if errors.Is(err, apperrors.ErrUnauthorized) {
+		if recordErr := e.loginGuard.RecordFailure(ctx, user.Email, creds.ClientIP); recordErr != nil {
+			return recordErr
+		}
+		return ErrIncorrectPassword
+	}
+	if err != nil {
+		return err
+	}
+	return e.loginGuard.RecordSuccess(ctx, user.Email)
+}
+
+// checkRecentSignIn allows a change without a password if the user has a linked
+// identity and their token was issued for a sign-in with a provider within reauthWindow.
+// Tokens from password logins never qualify.
+func (e *userService) checkRecentSignIn(ctx context.Context, id uuid.UUID, creds Credentials) error {
+	if !slices.Contains(creds.AuthMethods, utils.AuthMethodOIDC) {
+		return ErrPasswordRequired
+	}
+	if creds.AuthTime.IsZero() || time.Since(creds.AuthTime) > reauthWindow {
+		return ErrPasswordRequired
+	}
+	identities, err := e.identityRepo.GetIdentitiesByUserID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(identities) == 0 {
+		return ErrPasswordRequired
+	}
+	return nil
+}
+
+// userError replaces the generic repository errors with user specific ones.
+func userError(err error) error {
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	if errors.Is(err, apperrors.ErrAlreadyExists) {
+		return ErrEmailTaken
+	}
+	return err
 }
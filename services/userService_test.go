@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"go-rest-api/apperrors"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUserRepository keeps users in memory, with plain text passwords. Methods the
+// tests don't use panic through the nil embedded interface.
+type fakeUserRepository struct {
+	repository.UserRepository
+	mu    sync.Mutex
+	users map[uuid.UUID]*model.User
+}
+
+func newFakeUserRepository(users ...*model.User) *fakeUserRepository {
+	r := &fakeUserRepository{users: map[uuid.UUID]*model.User{}}
+	for _, user := range users {
+		r.users[user.Id] = user
+	}
+	return r
+}
+
+func (r *fakeUserRepository) Create(ctx context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.Id = uuid.New()
+	if user.Role == "" {
+		user.Role = "user"
+	}
+	stored := *user
+	r.users[user.Id] = &stored
+	return nil
+}
+
+func (r *fakeUserRepository) Validate(ctx context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, stored := range r.users {
+		if stored.Email == user.Email {
+			if stored.Password != user.Password {
+				return apperrors.ErrUnauthorized
+			}
+			user.Id, user.Role = stored.Id, stored.Role
+			return nil
+		}
+	}
+	return apperrors.ErrNotFound
+}
+
+func (r *fakeUserRepository) GetById(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, apperrors.ErrNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+func (r *fakeUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, apperrors.ErrNotFound
+}
+
+func (r *fakeUserRepository) UpdateProfile(ctx context.Context, user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[user.Id]
+	if !ok {
+		return apperrors.ErrNotFound
+	}
+	stored.DisplayName, stored.AvatarURL = user.DisplayName, user.AvatarURL
+	return nil
+}
+
+func (r *fakeUserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, password string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[id]
+	if !ok {
+		return apperrors.ErrNotFound
+	}
+	stored.Password = password
+	return nil
+}
+
+func (r *fakeUserRepository) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.users)
+}
+
+// fakeLoginGuard never throttles and counts the recorded failures.
+type fakeLoginGuard struct {
+	LoginGuardService
+	failures int
+}
+
+func (g *fakeLoginGuard) Check(ctx context.Context, email string, ip string) error {
+	return nil
+}
+
+func (g *fakeLoginGuard) RecordFailure(ctx context.Context, email string, ip string) error {
+	g.failures++
+	return nil
+}
+
+func (g *fakeLoginGuard) RecordSuccess(ctx context.Context, email string) error {
+	return nil
+}
+
+func newTestUserService(t *testing.T, linked bool) (UserService, *fakeUserRepository, *fakeLoginGuard, uuid.UUID) {
+	t.Helper()
+	user := &model.User{Id: uuid.New(), Email: "ada@example.com", Password: "password123", Role: "user"}
+	users := newFakeUserRepository(user)
+	identities := newFakeIdentityRepository()
+	if linked {
+		require.NoError(t, identities.CreateIdentity(context.Background(), &model.UserIdentity{UserID: user.Id, Provider: "mock", Subject: "ada"}))
+	}
+	guard := &fakeLoginGuard{}
+	return NewUserService(users, nil, guard, identities), users, guard, user.Id
+}
+
+func TestChangePasswordWithPassword(t *testing.T) {
+	service, users, guard, id := newTestUserService(t, false)
+	ctx := context.Background()
+
+	err := service.ChangePassword(ctx, id, Credentials{Password: "wrong"}, "newpassword456")
+	assert.ErrorIs(t, err, ErrIncorrectPassword)
+	assert.Equal(t, 1, guard.failures, "wrong passwords count as failed logins")
+
+	require.NoError(t, service.ChangePassword(ctx, id, Credentials{Password: "password123"}, "newpassword456"))
+	user, err := users.GetById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "newpassword456", user.Password)
+}
+
+func TestChangePasswordAfterProviderSignIn(t *testing.T) {
+	service, users, _, id := newTestUserService(t, true)
+	ctx := context.Background()
+
+	creds := Credentials{AuthTime: time.Now().Add(-time.Minute), AuthMethods: []string{utils.AuthMethodOIDC}}
+	require.NoError(t, service.ChangePassword(ctx, id, creds, "newpassword456"))
+	user, err := users.GetById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "newpassword456", user.Password)
+}
+
+func TestChangesWithoutPasswordNeedRecentSignIn(t *testing.T) {
+	service, _, _, id := newTestUserService(t, true)
+	ctx := context.Background()
+
+	oidc := []string{utils.AuthMethodOIDC}
+	err := service.ChangePassword(ctx, id, Credentials{AuthTime: time.Now().Add(-reauthWindow - time.Minute), AuthMethods: oidc}, "newpassword456")
+	assert.ErrorIs(t, err, ErrPasswordRequired, "signed in too long ago")
+	err = service.DeleteAccount(ctx, id, Credentials{AuthMethods: oidc})
+	assert.ErrorIs(t, err, ErrPasswordRequired, "sign-in time unknown")
+}
+
+func TestChangesWithoutPasswordNeedProviderSignIn(t *testing.T) {
+	service, _, _, id := newTestUserService(t, true)
+	ctx := context.Background()
+
+	err := service.ChangePassword(ctx, id, Credentials{AuthTime: time.Now(), AuthMethods: []string{utils.AuthMethodPassword}}, "newpassword456")
+	assert.ErrorIs(t, err, ErrPasswordRequired, "token from a password login")
+	err = service.DeleteAccount(ctx, id, Credentials{AuthTime: time.Now()})
+	assert.ErrorIs(t, err, ErrPasswordRequired, "token without amr")
+}
+
+func TestChangesWithoutPasswordNeedLinkedIdentity(t *testing.T) {
+	service, _, _, id := newTestUserService(t, false)
+	ctx := context.Background()
+
+	creds := Credentials{AuthTime: time.Now(), AuthMethods: []string{utils.AuthMethodOIDC}}
+	err := service.RequestEmailChange(ctx, id, "new@example.com", creds)
+	assert.ErrorIs(t, err, ErrPasswordRequired)
+	err = service.DeleteAccount(ctx, id, creds)
+	assert.ErrorIs(t, err, ErrPasswordRequired)
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/apperrors"
+	"go-rest-api/geo"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+// DefaultVenueTimeZone is the time zone of venues created without one.
+const DefaultVenueTimeZone = "UTC"
+
+var ErrVenueNotFound = apperrors.NotFound("venue_not_found", "venue not found")
+var ErrNotVenueOwner = apperrors.Forbidden("not_venue_owner", "you don't have permission to modify this venue")
+var ErrVenueCapacityTooSmall = apperrors.Conflict("venue_capacity_too_small", "upcoming events at this venue have a larger capacity")
+
+type VenueService interface {
+	CreateVenue(ctx context.Context, venue *model.Venue) error
+	GetVenues(ctx context.Context) ([]model.Venue, error)
+	GetVenue(ctx context.Context, id uuid.UUID) (*model.Venue, error)
+	// UpdateVenue changes the fields of the venue that are set. Only its creator or
+	// an admin may change it.
+	UpdateVenue(ctx context.Context, venue *model.Venue, userID uuid.UUID, userRole string) error
+	DeleteVenue(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) error
+}
+
+type venueService struct {
+	venueRepository repository.VenueRepository
+	eventRepository repository.EventRepository
+	geocoder        geo.Geocoder
+}
+
+// NewVenueService returns a VenueService. geocoder may be nil, then venues only
+// have coordinates if they are given.
+func NewVenueService(venueRepository repository.VenueRepository, eventRepository repository.EventRepository, geocoder geo.Geocoder) VenueService {
+	return &venueService{venueRepository: venueRepository, eventRepository: eventRepository, geocoder: geocoder}
+}
+
+func (s *venueService) CreateVenue(ctx context.Context, venue *model.Venue) error {
+	ctx, span := tracer.Start(ctx, "VenueService.CreateVenue")
+	defer span.End()
+
+	if venue.TimeZone == nil || *venue.TimeZone == "" {
+		timeZone := DefaultVenueTimeZone
+		venue.TimeZone = &timeZone
+	}
+	if venue.Latitude == nil {
+		s.locate(ctx, venue)
+	}
+	return s.venueRepository.Create(ctx, venue)
+}
+
+// locate sets the venue's coordinates from its address if the geocoder knows it.
+func (s *venueService) locate(ctx context.Context, venue *model.Venue) {
+	venue.Latitude, venue.Longitude = nil, nil
+	if s.geocoder == nil || venue.Address == nil {
+		return
+	}
+	point, err := s.geocoder.Geocode(ctx, *venue.Address)
+	if err != nil {
+		if !errors.Is(err, geo.ErrNotFound) {
+			slog.WarnContext(ctx, "failed to geocode venue address", "error", err)
+		}
+		return
+	}
+	venue.Latitude, venue.Longitude = &point.Latitude, &point.Longitude
+}
+
+func (s *venueService) GetVenues(ctx context.Context) ([]model.Venue, error) {
+	ctx, span := tracer.Start(ctx, "VenueService.GetVenues")
+	defer span.End()
+
+	return s.venueRepository.GetAll(ctx)
+}
+
+func (s *venueService) GetVenue(ctx context.Context, id uuid.UUID) (*model.Venue, error) {
+	ctx, span := tracer.Start(ctx, "VenueService.GetVenue")
+	defer span.End()
+
+	venue, err := s.venueRepository.GetByID(ctx, id)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, ErrVenueNotFound
+	}
+	return venue, err
+}
+
+func (s *venueService) UpdateVenue(ctx context.Context, venue *model.Venue, userID uuid.UUID, userRole string) error {
+	ctx, span := tracer.Start(ctx, "VenueService.UpdateVenue")
+	defer span.End()
+
+	existingVenue, err := s.GetVenue(ctx, venue.Id)
+	if err != nil {
+		return err
+	}
+	if existingVenue.UserID != userID && userRole != "admin" {
+		return ErrNotVenueOwner
+	}
+
+	if venue.Name != nil {
+		existingVenue.Name = venue.Name
+	}
+	if venue.Address != nil && *venue.Address != *existingVenue.Address {
+		existingVenue.Address = venue.Address
+		if venue.Latitude == nil {
+			s.locate(ctx, existingVenue)
+		}
+	}
+	if venue.Latitude != nil {
+		existingVenue.Latitude, existingVenue.Longitude = venue.Latitude, venue.Longitude
+	}
+	if venue.Capacity != nil {
+		// Events already booked at the venue must still fit
+		events, err := s.eventRepository.GetVenueEventsOverCapacity(ctx, existingVenue.Id, *venue.Capacity)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			return ErrVenueCapacityTooSmall.WithDetails(map[string]interface{}{"events": events})
+		}
+		existingVenue.Capacity = venue.Capacity
+	}
+	if venue.Accessibility != nil {
+		existingVenue.Accessibility = venue.Accessibility
+	}
+	if venue.TimeZone != nil {
+		existingVenue.TimeZone = venue.TimeZone
+	}
+
+	if err := s.venueRepository.Update(ctx, existingVenue); err != nil {
+		return err
+	}
+	*venue = *existingVenue
+	return nil
+}
+
+func (s *venueService) DeleteVenue(ctx context.Context, id uuid.UUID, userID uuid.UUID, userRole string) error {
+	ctx, span := tracer.Start(ctx, "VenueService.DeleteVenue")
+	defer span.End()
+
+	venue, err := s.GetVenue(ctx, id)
+	if err != nil {
+		return err
+	}
+	if venue.UserID != userID && userRole != "admin" {
+		return ErrNotVenueOwner
+	}
+	return s.venueRepository.Delete(ctx, id)
+}
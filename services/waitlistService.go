@@ -4,20 +4,33 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"go-rest-api/apperrors"
+	"go-rest-api/metrics"
 	"go-rest-api/model"
 	"go-rest-api/repository"
-	"log"
-	"sync"
+	"log/slog"
 
 	"github.com/google/uuid"
 )
 
-var ErrEventNotFull = errors.New("event is not full, cannot join waitlist")
-var ErrAlreadyRegistered = errors.New("user is already registered for this event")
-var ErrAlreadyOnWaitlist = errors.New("user is already on the waitlist for this event")
-var ErrEventNotFound = errors.New("event not found")
-var ErrUserNotOnWaitlist = errors.New("user is not on the waitlist for this event")
-var ErrWaitlistNotEnabled = errors.New("waitlist not enabled for this event (capacity is 0 or not set)")
+var ErrEventNotFull = apperrors.Conflict("event_not_full", "event is not full, cannot join waitlist")
+var ErrAlreadyRegistered = apperrors.Conflict("already_registered", "user is already registered for this event")
+var ErrAlreadyOnWaitlist = apperrors.Conflict("already_on_waitlist", "user is already on the waitlist for this event")
+var ErrEventNotFound = apperrors.NotFound("event_not_found", "event not found")
+var ErrUserNotOnWaitlist = apperrors.NotFound("not_on_waitlist", "user is not on the waitlist for this event")
+var ErrWaitlistNotEnabled = apperrors.Conflict("waitlist_not_enabled", "waitlist not enabled for this event (capacity is 0 or not set)")
+
+// getEvent loads an event, returning ErrEventNotFound if it doesn't exist.
+func getEvent(ctx context.Context, eventRepo repository.EventRepository, eventID uuid.UUID) (*model.Event, error) {
+	event, err := eventRepo.GetEventById(ctx, eventID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, ErrEventNotFound
+		}
+		return nil, err
+	}
+	return event, nil
+}
 
 type WaitlistService interface {
 	JoinWaitlist(ctx context.Context, eventID uuid.UUID, userID uuid.UUID) (*model.WaitlistEntry, error)
@@ -31,8 +44,6 @@ type waitlistService struct {
 	eventRepo    repository.EventRepository
 	userRepo     repository.UserRepository // For fetching user details for notification (future)
 	// notificationService NotificationService // For actual notifications (future)
-	eventMutex map[uuid.UUID]*sync.Mutex
-	mapMutex   sync.RWMutex // Protects eventMutex map
 }
 
 func NewWaitlistService(
@@ -49,11 +60,15 @@ func NewWaitlistService(
 	}
 }
 
-func (s *waitlistService) JoinWaitlist(ctx context.Context, eventID uuid.UUID, userID uuid.UUID) (*model.WaitlistEntry, error) {
-	event, err := s.eventRepo.GetEventById(ctx, eventID)
+func (s *waitlistService) JoinWaitlist(ctx context.Context, eventID uuid.UUID, userID uuid.UUID) (entry *model.WaitlistEntry, err error) {
+	ctx, span := tracer.Start(ctx, "WaitlistService.JoinWaitlist")
+	defer span.End()
+
+	defer func() { metrics.WaitlistJoins.WithLabelValues(metrics.Result(err)).Inc() }()
+
+	event, err := getEvent(ctx, s.eventRepo, eventID)
 	if err != nil {
-		log.Printf("Error fetching event %d for waitlist join: %v", eventID, err)
-		return nil, ErrEventNotFound
+		return nil, err
 	}
 
 	if event.Capacity == nil || *event.Capacity <= 0 {
@@ -62,7 +77,6 @@ func (s *waitlistService) JoinWaitlist(ctx context.Context, eventID uuid.UUID, u
 
 	registeredCount, err := s.eventRepo.GetRegistrationCount(ctx, eventID)
 	if err != nil {
-		log.Printf("Error fetching registration count for event %d: %v", eventID, err)
 		return nil, fmt.Errorf("could not verify event registration count: %w", err)
 	}
 
@@ -72,7 +86,6 @@ func (s *waitlistService) JoinWaitlist(ctx context.Context, eventID uuid.UUID, u
 
 	isRegistered, err := s.eventRepo.IsUserRegistered(ctx, eventID, userID)
 	if err != nil {
-		log.Printf("Error checking if user %d is registered for event %d: %v", userID, eventID, err)
 		return nil, fmt.Errorf("could not verify event registration status: %w", err)
 	}
 	if isRegistered {
@@ -81,21 +94,27 @@ func (s *waitlistService) JoinWaitlist(ctx context.Context, eventID uuid.UUID, u
 
 	isOnWaitlist, err := s.waitlistRepo.IsUserOnWaitlist(ctx, eventID, userID)
 	if err != nil {
-		log.Printf("Error checking if user %d is on waitlist for event %d: %v", userID, eventID, err)
 		return nil, fmt.Errorf("could not verify waitlist status: %w", err)
 	}
 	if isOnWaitlist {
 		return nil, ErrAlreadyOnWaitlist
 	}
 
-	return s.waitlistRepo.AddUserToWaitlist(ctx, eventID, userID)
+	entry, err = s.waitlistRepo.AddUserToWaitlist(ctx, eventID, userID)
+	if errors.Is(err, apperrors.ErrAlreadyExists) {
+		return nil, ErrAlreadyOnWaitlist
+	}
+	return entry, err
 }
 
 func (s *waitlistService) LeaveWaitlist(ctx context.Context, eventID uuid.UUID, userID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "WaitlistService.LeaveWaitlist")
+	defer span.End()
+
 	// Check if event exists
-	_, err := s.eventRepo.GetEventById(ctx, eventID)
+	_, err := getEvent(ctx, s.eventRepo, eventID)
 	if err != nil {
-		return ErrEventNotFound
+		return err
 	}
 
 	isOnWaitlist, err := s.waitlistRepo.IsUserOnWaitlist(ctx, eventID, userID)
@@ -106,13 +125,20 @@ func (s *waitlistService) LeaveWaitlist(ctx context.Context, eventID uuid.UUID,
 		return ErrUserNotOnWaitlist
 	}
 
-	return s.waitlistRepo.RemoveUserFromWaitlist(ctx, eventID, userID)
+	err = s.waitlistRepo.RemoveUserFromWaitlist(ctx, eventID, userID)
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return ErrUserNotOnWaitlist
+	}
+	return err
 }
 
 func (s *waitlistService) GetWaitlistForEvent(ctx context.Context, eventID uuid.UUID) ([]model.WaitlistEntry, error) {
-	_, err := s.eventRepo.GetEventById(ctx, eventID)
+	ctx, span := tracer.Start(ctx, "WaitlistService.GetWaitlistForEvent")
+	defer span.End()
+
+	_, err := getEvent(ctx, s.eventRepo, eventID)
 	if err != nil {
-		return nil, ErrEventNotFound
+		return nil, err
 	}
 	return s.waitlistRepo.GetWaitlistForEvent(ctx, eventID)
 }
@@ -123,51 +149,48 @@ func (s *waitlistService) GetWaitlistForEvent(ctx context.Context, eventID uuid.
 // - Send a notification with a time limit to register.
 // - Handle cases where the next user is no longer interested.
 func (s *waitlistService) ProcessNextOnWaitlist(ctx context.Context, eventID uuid.UUID) (*model.User, error) {
+	ctx, span := tracer.Start(ctx, "WaitlistService.ProcessNextOnWaitlist")
+	defer span.End()
 
-	s.mapMutex.Lock()
-	mu, ok := s.eventMutex[eventID]
-	if !ok {
-		mu = &sync.Mutex{}
-		s.eventMutex[eventID] = mu
-	}
-	s.mapMutex.Unlock()
-
-	mu.Lock()
-	defer mu.Unlock()
-
-	log.Printf("Processing next on waitlist for event ID %s", eventID)
+	slog.DebugContext(ctx, "processing next user on waitlist", "event_id", eventID)
 	nextEntry, err := s.waitlistRepo.GetNextUserFromWaitlist(ctx, eventID)
 	if err != nil {
-		log.Printf("Error getting next user from waitlist for event %d: %v", eventID, err)
 		return nil, fmt.Errorf("failed to get next user from waitlist: %w", err)
 	}
 
 	if nextEntry == nil {
-		log.Printf("No users on waitlist for event %d", eventID)
 		return nil, nil // No one to process
 	}
 
-	err = s.eventRepo.RegisterEvent(ctx, eventID, nextEntry.UserID)
-	if err != nil {
-
-		log.Printf("Failed to auto-register user %d from waitlist for event %d: %v", nextEntry.UserID, eventID, err)
+	// The seat is checked again here: the job may run twice, for example when it was
+	// saved on shutdown after promoting someone, and the seat may be taken by then.
+	// The event row lock also serializes concurrent promotions, across instances.
+	err = s.eventRepo.RegisterEventIfSeatFree(ctx, eventID, nextEntry.UserID)
+	switch {
+	case errors.Is(err, apperrors.ErrConflict):
+		slog.InfoContext(ctx, "no seat free, waitlist left unchanged", "event_id", eventID)
+		return nil, nil
+	case errors.Is(err, apperrors.ErrAlreadyExists):
+		// Registered some other way in the meantime; just take them off the waitlist
+		slog.InfoContext(ctx, "user on waitlist is already registered", "event_id", eventID, "user_id", nextEntry.UserID)
+	case err != nil:
+		metrics.WaitlistPromotions.WithLabelValues(metrics.ResultFailed).Inc()
 		return nil, fmt.Errorf("failed to register user from waitlist: %w", err)
+	default:
+		metrics.WaitlistPromotions.WithLabelValues(metrics.ResultSuccess).Inc()
 	}
 
 	// If registration was successful, remove them from the waitlist.
 	err = s.waitlistRepo.RemoveUserFromWaitlist(ctx, eventID, nextEntry.UserID)
 	if err != nil {
-
-		log.Printf("CRITICAL: User %d registered from waitlist for event %d but failed to remove from waitlist: %v", nextEntry.UserID, eventID, err)
-
+		slog.ErrorContext(ctx, "user registered from waitlist but not removed from it", "event_id", eventID, "user_id", nextEntry.UserID, "error", err)
 	}
 
-	log.Printf("User %d successfully registered from waitlist for event %d.", nextEntry.UserID, eventID)
+	slog.InfoContext(ctx, "registered user from waitlist", "event_id", eventID, "user_id", nextEntry.UserID)
 
 	promotedUser, userErr := s.userRepo.GetById(ctx, nextEntry.UserID)
 	if userErr != nil {
-		log.Printf("Failed to fetch details for promoted user %d: %v", nextEntry.UserID, userErr)
-
+		slog.WarnContext(ctx, "failed to fetch promoted user", "user_id", nextEntry.UserID, "error", userErr)
 		return nil, nil
 	}
 
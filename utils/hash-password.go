@@ -2,9 +2,13 @@ package utils
 
 import "golang.org/x/crypto/bcrypt"
 
+// BcryptCost is the work factor of new password hashes. Existing hashes keep
+// the cost they were created with.
+var BcryptCost = 14
+
 func HashPassword(password string) string {
 
-	hashPassword, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	hashPassword, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
 	if err != nil {
 		panic(err)
 	}
@@ -1,47 +1,410 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateToken(email string, userId string, role string, secretKey string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Purposes of short-lived tokens issued between the password and two-factor login steps.
+const (
+	TokenPurposeMFAVerify = "mfa_verify"
+	TokenPurposeMFAEnroll = "mfa_enroll"
+)
+
+// Authentication methods recorded in the amr claim of issued tokens (RFC 8176).
+const (
+	AuthMethodPassword = "pwd"
+	// AuthMethodOIDC is a sign-in with an external OpenID Connect provider.
+	AuthMethodOIDC = "oidc"
+)
+
+// TokenTTL is how long issued access tokens stay valid.
+var TokenTTL = 2 * time.Hour
+
+// MFATokenTTL is how long a user has to complete the two-factor step after their password.
+var MFATokenTTL = 5 * time.Minute
+
+// SigningKey is a key used to sign and verify tokens. Keys start signing at ActivatesAt
+// and remain valid for verification until ExpiresAt.
+type SigningKey struct {
+	Kid         string
+	Algorithm   string
+	PrivateKey  crypto.PrivateKey // *rsa.PrivateKey, ed25519.PrivateKey or []byte for HS256
+	ActivatesAt time.Time
+	ExpiresAt   time.Time
+}
+
+// KeySet holds the keys currently in use. It is safe for concurrent use and
+// can be swapped atomically when keys are rotated.
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []SigningKey
+}
+
+func NewKeySet(keys ...SigningKey) *KeySet {
+	k := &KeySet{}
+	k.SetKeys(keys)
+	return k
+}
+
+// NewHMACKey returns the HS256 key for the shared secret. A zero expiresAt never expires.
+func NewHMACKey(secret string, expiresAt time.Time) SigningKey {
+	return SigningKey{
+		Kid:        "default",
+		Algorithm:  AlgorithmHS256,
+		PrivateKey: []byte(secret),
+		ExpiresAt:  expiresAt,
+	}
+}
+
+// NewHMACKeySet returns a key set with a single non-expiring HS256 key, the legacy shared-secret mode.
+func NewHMACKeySet(secret string) *KeySet {
+	return NewKeySet(NewHMACKey(secret, time.Time{}))
+}
+
+// SetKeys replaces the keys in the set.
+func (k *KeySet) SetKeys(keys []SigningKey) {
+	sorted := make([]SigningKey, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ActivatesAt.After(sorted[j].ActivatesAt)
+	})
+
+	k.mu.Lock()
+	k.keys = sorted
+	k.mu.Unlock()
+}
+
+// signingKey returns the most recently activated key that has not expired.
+func (k *KeySet) signingKey() (SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range k.keys {
+		if !key.ActivatesAt.After(now) && !isExpired(key, now) {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+func (k *KeySet) lookup(kid string) (SigningKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if key.Kid == kid && !isExpired(key, time.Now()) {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+func isExpired(key SigningKey, now time.Time) bool {
+	return !key.ExpiresAt.IsZero() && now.After(key.ExpiresAt)
+}
+
+// JSONWebKey is the public part of a signing key in RFC 7517 format.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys of all unexpired asymmetric keys, including keys that
+// are not active yet so verifiers can fetch them before they are used.
+func (k *KeySet) JWKS() JSONWebKeySet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	now := time.Now()
+	for _, key := range k.keys {
+		if isExpired(key, now) {
+			continue
+		}
+		switch private := key.PrivateKey.(type) {
+		case *rsa.PrivateKey:
+			set.Keys = append(set.Keys, JSONWebKey{
+				Kty: "RSA",
+				Kid: key.Kid,
+				Use: "sig",
+				Alg: key.Algorithm,
+				N:   base64.RawURLEncoding.EncodeToString(private.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(private.E)).Bytes()),
+			})
+		case ed25519.PrivateKey:
+			set.Keys = append(set.Keys, JSONWebKey{
+				Kty: "OKP",
+				Kid: key.Kid,
+				Use: "sig",
+				Alg: key.Algorithm,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(private.Public().(ed25519.PublicKey)),
+			})
+		}
+	}
+	return set
+}
+
+// GenerateSigningKey creates a new RS256 or EdDSA key with a random kid.
+func GenerateSigningKey(algorithm string, activatesAt time.Time, expiresAt time.Time) (SigningKey, error) {
+	var private crypto.PrivateKey
+	switch algorithm {
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		private = key
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		private = key
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	kid, err := GenerateRandomToken(12)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{
+		Kid:         kid,
+		Algorithm:   algorithm,
+		PrivateKey:  private,
+		ActivatesAt: activatesAt,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// EncodePrivateKey returns the key as a PKCS#8 PEM block.
+func EncodePrivateKey(key crypto.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParsePrivateKey parses a PKCS#8 PEM block produced by EncodePrivateKey.
+func ParsePrivateKey(data string) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case AlgorithmHS256:
+		return jwt.SigningMethodHS256, nil
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+}
+
+func verificationKey(key SigningKey) interface{} {
+	switch private := key.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		return &private.PublicKey
+	case ed25519.PrivateKey:
+		return private.Public()
+	}
+	return key.PrivateKey
+}
+
+// AccessTokenClaims are the claims of a token issued by GenerateToken.
+type AccessTokenClaims struct {
+	UserID string
+	Role   string
+	// IssuedAt is when the user signed in, since tokens are only issued at login. It is
+	// zero for tokens issued before it was recorded.
+	IssuedAt time.Time
+	// AuthMethods are how the user signed in, such as AuthMethodOIDC.
+	AuthMethods []string
+}
+
+// GenerateToken issues an access token. authMethods are how the user signed in.
+func GenerateToken(email string, userId string, role string, authMethods []string, keys *KeySet) (string, error) {
+	now := time.Now()
+	return signToken(jwt.MapClaims{
 		"email":  email,
 		"userId": userId,
 		"role":   role,
-		"exp":    time.Now().Add(time.Hour * 2).Unix(),
-	})
-	tokenString, err := token.SignedString([]byte(secretKey))
+		"amr":    authMethods,
+		"iat":    now.Unix(),
+		"exp":    now.Add(TokenTTL).Unix(),
+	}, keys)
+}
+
+// MFATokenClaims are the claims of a token issued by GenerateMFAToken.
+type MFATokenClaims struct {
+	UserID string
+	// ID (jti) lets the token be used only once.
+	ID        string
+	ExpiresAt time.Time
+	// AuthMethods are how the user passed the first step, carried over to the access token.
+	AuthMethods []string
+}
+
+// GenerateMFAToken issues a short-lived token that only proves the first login step for
+// purpose. It is rejected by ValidateToken and cannot be used as an access token.
+func GenerateMFAToken(userId string, purpose string, authMethods []string, keys *KeySet) (string, error) {
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	return signToken(jwt.MapClaims{
+		"userId":  userId,
+		"purpose": purpose,
+		"amr":     authMethods,
+		"jti":     jti,
+		"exp":     time.Now().Add(MFATokenTTL).Unix(),
+	}, keys)
+}
+
+func signToken(claims jwt.MapClaims, keys *KeySet) (string, error) {
+	key, ok := keys.signingKey()
+	if !ok {
+		return "", errors.New("no active signing key")
+	}
+	method, err := signingMethod(key.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.Kid
+	tokenString, err := token.SignedString(key.PrivateKey)
 	if err != nil {
 		return "", err
 	}
 	return tokenString, nil
 }
 
-func ValidateToken(token string, secretKey string) (string, string, error) {
+func parseToken(token string, keys *KeySet) (jwt.MapClaims, error) {
 	parse, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
+		// Tokens issued before key rotation was introduced have no kid.
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = "default"
+		}
+		key, ok := keys.lookup(kid)
 		if !ok {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != key.Algorithm {
 			return nil, errors.New("invalid signing method")
 		}
-		return []byte(secretKey), nil
+		return verificationKey(key), nil
 	})
 	if err != nil {
-		return "", "", errors.New("cant parse token")
+		return nil, errors.New("cant parse token")
 	}
 	isValid := parse.Valid
 	if !isValid {
-		return "", "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 	claims, ok := parse.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", "", errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
+	}
+	return claims, nil
+}
+
+func ValidateToken(token string, keys *KeySet) (*AccessTokenClaims, error) {
+	claims, err := parseToken(token, keys)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := claims["purpose"]; ok {
+		return nil, errors.New("token cannot be used for authentication")
 	}
 	//email := claims["email"].(string)
-	userId := claims["userId"].(string)
-	role := claims["role"].(string)
-	return userId, role, nil
+	userId, ok := claims["userId"].(string)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	role, ok := claims["role"].(string)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	accessClaims := &AccessTokenClaims{UserID: userId, Role: role, AuthMethods: stringsClaim(claims, "amr")}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		accessClaims.IssuedAt = iat.Time
+	}
+	return accessClaims, nil
+}
+
+// ValidateMFAToken returns the claims of a token issued by GenerateMFAToken for purpose.
+// Callers must still check that the token ID wasn't used before.
+func ValidateMFAToken(token string, purpose string, keys *KeySet) (*MFATokenClaims, error) {
+	claims, err := parseToken(token, keys)
+	if err != nil {
+		return nil, err
+	}
+	if tokenPurpose, _ := claims["purpose"].(string); tokenPurpose != purpose {
+		return nil, errors.New("invalid token purpose")
+	}
+	userId, ok := claims["userId"].(string)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return nil, errors.New("invalid token claims")
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, errors.New("invalid token claims")
+	}
+	return &MFATokenClaims{UserID: userId, ID: jti, ExpiresAt: exp.Time, AuthMethods: stringsClaim(claims, "amr")}, nil
+}
+
+// stringsClaim returns the strings in the array claim name, ignoring other values.
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	values, _ := claims[name].([]interface{})
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
 }
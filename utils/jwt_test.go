@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMFATokenClaims(t *testing.T) {
+	keys := NewHMACKeySet("secret")
+
+	token, err := GenerateMFAToken("user-1", TokenPurposeMFAVerify, []string{AuthMethodOIDC}, keys)
+	require.NoError(t, err)
+	other, err := GenerateMFAToken("user-1", TokenPurposeMFAVerify, []string{AuthMethodPassword}, keys)
+	require.NoError(t, err)
+
+	claims, err := ValidateMFAToken(token, TokenPurposeMFAVerify, keys)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.NotEmpty(t, claims.ID)
+	assert.Equal(t, []string{AuthMethodOIDC}, claims.AuthMethods)
+	assert.WithinDuration(t, time.Now().Add(MFATokenTTL), claims.ExpiresAt, 2*time.Second)
+
+	otherClaims, err := ValidateMFAToken(other, TokenPurposeMFAVerify, keys)
+	require.NoError(t, err)
+	assert.NotEqual(t, claims.ID, otherClaims.ID, "each token has its own ID")
+
+	_, err = ValidateMFAToken(token, TokenPurposeMFAEnroll, keys)
+	assert.Error(t, err, "other purpose")
+	_, err = ValidateToken(token, keys)
+	assert.Error(t, err, "not an access token")
+}
+
+func TestAccessTokenClaims(t *testing.T) {
+	keys := NewHMACKeySet("secret")
+
+	token, err := GenerateToken("ada@example.com", "user-1", "admin", []string{AuthMethodOIDC}, keys)
+	require.NoError(t, err)
+
+	claims, err := ValidateToken(token, keys)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, "admin", claims.Role)
+	assert.WithinDuration(t, time.Now(), claims.IssuedAt, 2*time.Second, "issued at sign-in")
+	assert.Equal(t, []string{AuthMethodOIDC}, claims.AuthMethods)
+}
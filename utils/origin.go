@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// OriginPattern is an allowed CORS origin: an exact origin such as
+// https://app.example.com, a wildcard subdomain such as https://*.example.com,
+// or "*" for any origin.
+type OriginPattern struct {
+	any    bool
+	scheme string
+	host   string // for wildcards, the parent domain without the "*."
+	port   string
+	wild   bool
+}
+
+// ParseOriginPattern validates an allowed origin. Origins have no path, query or
+// trailing slash, and a wildcard may only stand for the leftmost subdomain labels.
+func ParseOriginPattern(s string) (OriginPattern, error) {
+	if s == "*" {
+		return OriginPattern{any: true}, nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return OriginPattern{}, fmt.Errorf("%q is not an origin like https://app.example.com", s)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return OriginPattern{}, fmt.Errorf("%q must use http or https", s)
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return OriginPattern{}, fmt.Errorf("%q must not have a path, query or credentials", s)
+	}
+
+	pattern := OriginPattern{scheme: u.Scheme, host: strings.ToLower(u.Hostname()), port: u.Port()}
+	if strings.HasPrefix(pattern.host, "*.") {
+		pattern.wild = true
+		pattern.host = strings.TrimPrefix(pattern.host, "*.")
+	}
+	if strings.Contains(pattern.host, "*") {
+		return OriginPattern{}, fmt.Errorf("%q may only use a wildcard as the leftmost label, e.g. https://*.example.com", s)
+	}
+	if pattern.wild && !strings.Contains(pattern.host, ".") {
+		return OriginPattern{}, fmt.Errorf("%q would match subdomains of a top-level domain", s)
+	}
+	return pattern, nil
+}
+
+// Any reports whether the pattern is "*".
+func (p OriginPattern) Any() bool {
+	return p.any
+}
+
+// Match reports whether the Origin header value origin is allowed by the pattern.
+// A wildcard matches subdomains at any depth but not the parent domain itself.
+func (p OriginPattern) Match(origin string) bool {
+	if p.any {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme != p.scheme || u.Port() != p.port || u.Path != "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if p.wild {
+		return strings.HasSuffix(host, "."+p.host)
+	}
+	return host == p.host
+}
@@ -0,0 +1,14 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slugify lower-cases s and joins its ASCII letters and digits with hyphens, so
+// "Arts & Culture" becomes "arts-culture". It returns "" if s has none.
+func Slugify(s string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The SHA1 secret of RFC 6238 appendix B, "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The SHA1 test vectors of RFC 6238 appendix B, truncated to our 6 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	require.NoError(t, err)
+
+	for _, v := range rfc6238Vectors {
+		assert.Equal(t, v.code, totpCode(key, v.unix/totpPeriod), "time %d", v.unix)
+	}
+}
+
+func TestValidateTOTPRFC6238Vectors(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		step, ok := ValidateTOTP(rfc6238Secret, v.code, time.Unix(v.unix, 0))
+		assert.True(t, ok, "time %d", v.unix)
+		assert.Equal(t, v.unix/totpPeriod, step, "time %d", v.unix)
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	require.NoError(t, err)
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		step, ok := ValidateTOTP(rfc6238Secret, totpCode(key, current+offset), now)
+		assert.True(t, ok, "offset %d", offset)
+		assert.Equal(t, current+offset, step, "offset %d", offset)
+	}
+	for _, offset := range []int64{-totpSkew - 1, totpSkew + 1} {
+		_, ok := ValidateTOTP(rfc6238Secret, totpCode(key, current+offset), now)
+		assert.False(t, ok, "offset %d", offset)
+	}
+}
+
+func TestValidateTOTPInput(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	_, ok := ValidateTOTP(rfc6238Secret, " 287 082 ", now)
+	assert.True(t, ok, "spaces are ignored")
+	_, ok = ValidateTOTP(rfc6238Secret, "94287082", now)
+	assert.False(t, ok, "8 digits")
+	_, ok = ValidateTOTP(rfc6238Secret, "287083", now)
+	assert.False(t, ok, "wrong code")
+	_, ok = ValidateTOTP("not base32!", "287082", now)
+	assert.False(t, ok, "invalid secret")
+}
@@ -19,8 +19,14 @@ func formatValidationErrors(errs validator.ValidationErrors) []ValidationError {
 		switch err.Tag() {
 		case "required":
 			validationErrors = append(validationErrors, ValidationError{Field: field, Message: "This field is required"})
+		case "required_with":
+			validationErrors = append(validationErrors, ValidationError{Field: field, Message: fmt.Sprintf("This field is required when %s is set", strings.ToLower(err.Param()))})
 		case "email":
 			validationErrors = append(validationErrors, ValidationError{Field: field, Message: "Invalid email format"})
+		case "url":
+			validationErrors = append(validationErrors, ValidationError{Field: field, Message: "Invalid URL format"})
+		case "http_url":
+			validationErrors = append(validationErrors, ValidationError{Field: field, Message: "Must be an http or https URL"})
 		case "min":
 			validationErrors = append(validationErrors, ValidationError{Field: field, Message: fmt.Sprintf("Must be at least %s characters long", err.Param())})
 		case "max":
@@ -31,6 +37,8 @@ func formatValidationErrors(errs validator.ValidationErrors) []ValidationError {
 			validationErrors = append(validationErrors, ValidationError{Field: field, Message: fmt.Sprintf("Must be less than or equal to %s", err.Param())})
 		case "oneof":
 			validationErrors = append(validationErrors, ValidationError{Field: field, Message: fmt.Sprintf("Must be one of: %s", err.Param())})
+		case "timezone":
+			validationErrors = append(validationErrors, ValidationError{Field: field, Message: "Must be a time zone name such as Europe/Berlin"})
 		default:
 			validationErrors = append(validationErrors, ValidationError{Field: field, Message: "Invalid value"})
 		}
@@ -0,0 +1,191 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-rest-api/logging"
+	"go-rest-api/model"
+	"go-rest-api/repository"
+	"go-rest-api/tracing"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("go-rest-api/worker")
+
+// Handler runs one job. Jobs can be interrupted at shutdown and run again after a
+// restart, so handlers must be safe to repeat.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// Group runs background jobs started by requests and keeps track of them so
+// shutdown can wait for them. Jobs still running when the shutdown deadline
+// passes, or submitted after shutdown began, are saved and resumed on the next
+// start.
+type Group struct {
+	jobRepo  repository.JobRepository
+	handlers map[string]Handler
+
+	// ctx is passed to running jobs and cancelled when the shutdown deadline passes.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	closed   bool
+	running  map[*model.PendingJob]struct{}
+	finished sync.WaitGroup
+}
+
+func NewGroup(jobRepo repository.JobRepository) *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{
+		jobRepo:  jobRepo,
+		handlers: make(map[string]Handler),
+		ctx:      ctx,
+		cancel:   cancel,
+		running:  make(map[*model.PendingJob]struct{}),
+	}
+}
+
+// Handle registers the handler for a job type. It must be called before jobs of
+// that type are submitted or resumed.
+func (g *Group) Handle(jobType string, handler Handler) {
+	g.handlers[jobType] = handler
+}
+
+// Submit runs a job in the background. ctx is the context of the request that
+// started it: the job keeps its request ID and its trace is linked to the request's
+// span, but it isn't cancelled when the request ends.
+func (g *Group) Submit(ctx context.Context, jobType string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to encode background job", "job_type", jobType, "error", err)
+		return
+	}
+	g.start(ctx, &model.PendingJob{Type: jobType, Payload: data, RequestID: logging.RequestID(ctx)})
+}
+
+func (g *Group) start(ctx context.Context, job *model.PendingJob) {
+	handler, ok := g.handlers[job.Type]
+	if !ok {
+		slog.ErrorContext(ctx, "no handler for background job", "job_type", job.Type)
+		return
+	}
+
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		g.persist(ctx, job)
+		return
+	}
+	g.running[job] = struct{}{}
+	g.finished.Add(1)
+	g.mu.Unlock()
+
+	jobCtx, span := tracing.Detach(ctx, tracer, "job "+job.Type)
+	// Cancel the job if the shutdown deadline passes.
+	jobCtx, stop := context.WithCancel(jobCtx)
+	unlink := context.AfterFunc(g.ctx, stop)
+
+	go func() {
+		defer g.finished.Done()
+		defer span.End()
+		defer stop()
+		defer unlink()
+
+		err := run(jobCtx, handler, job.Payload)
+
+		g.mu.Lock()
+		_, stillTracked := g.running[job]
+		delete(g.running, job)
+		g.mu.Unlock()
+
+		if err != nil && stillTracked {
+			slog.ErrorContext(jobCtx, "background job failed", "job_type", job.Type, "error", err)
+		}
+	}()
+}
+
+// run calls handler, turning a panic into an error so that a broken job doesn't take
+// down the server.
+func run(ctx context.Context, handler Handler, payload json.RawMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "background job panicked", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return handler(ctx, payload)
+}
+
+// Resume takes the jobs saved by a previous shutdown and runs them.
+func (g *Group) Resume(ctx context.Context) error {
+	jobs, err := g.jobRepo.TakeAll(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		job := &jobs[i]
+		jobCtx := ctx
+		if job.RequestID != "" {
+			jobCtx = logging.WithRequestID(ctx, job.RequestID)
+		}
+		slog.InfoContext(jobCtx, "resuming background job", "job_type", job.Type, "queued_at", job.CreatedAt)
+		g.start(jobCtx, job)
+	}
+	return nil
+}
+
+// Shutdown stops running new jobs and waits for running ones until ctx is done.
+// Jobs submitted from then on, and jobs still running at the deadline, are saved
+// to be resumed on the next start.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		g.finished.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Take the unfinished jobs first so their handlers, which now see a cancelled
+	// context, don't also report them as failed.
+	g.mu.Lock()
+	unfinished := make([]*model.PendingJob, 0, len(g.running))
+	for job := range g.running {
+		unfinished = append(unfinished, job)
+		delete(g.running, job)
+	}
+	g.mu.Unlock()
+	g.cancel()
+
+	for _, job := range unfinished {
+		g.persist(context.Background(), job)
+	}
+	return fmt.Errorf("%d background jobs did not finish before the shutdown deadline and were saved", len(unfinished))
+}
+
+func (g *Group) persist(ctx context.Context, job *model.PendingJob) {
+	// Saving must not be cut short by the cancelled shutdown context.
+	ctx = context.WithoutCancel(ctx)
+	if err := g.jobRepo.Save(ctx, job); err != nil {
+		slog.ErrorContext(ctx, "failed to save background job, it will not be resumed",
+			"job_type", job.Type,
+			"payload", string(job.Payload),
+			"error", err,
+		)
+		return
+	}
+	slog.InfoContext(ctx, "saved background job to resume on next start", "job_type", job.Type)
+}
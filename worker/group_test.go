@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPanickingJobDoesNotStopGroup(t *testing.T) {
+	group := NewGroup(nil)
+	var ran atomic.Bool
+	group.Handle("panics", func(ctx context.Context, payload json.RawMessage) error {
+		panic("boom")
+	})
+	group.Handle("works", func(ctx context.Context, payload json.RawMessage) error {
+		ran.Store(true)
+		return nil
+	})
+
+	ctx := context.Background()
+	group.Submit(ctx, "panics", nil)
+	group.Submit(ctx, "works", nil)
+	require.NoError(t, group.Shutdown(ctx))
+	assert.True(t, ran.Load())
+}
+
+func TestRunReturnsPanicAsError(t *testing.T) {
+	err := run(context.Background(), func(ctx context.Context, payload json.RawMessage) error {
+		panic("boom")
+	}, nil)
+	assert.ErrorContains(t, err, "boom")
+}